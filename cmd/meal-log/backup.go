@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"mcp-meal-log/internal/backup"
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/storage"
+)
+
+// runBackupCommand writes a consistent copy of the database with a
+// checksum beside it, either to -out or as a new snapshot in -dir, where
+// older snapshots are rotated out. When an encryption key is configured the
+// whole file is encrypted with it; -decrypt turns such a backup back into a
+// plain SQLite file.
+func runBackupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	out := fs.String("out", "", "Backup file to write")
+	dir := fs.String("dir", "", "Directory to write a rotated snapshot to instead of -out")
+	keep := fs.Int("keep", 7, "Snapshots kept in -dir (0 keeps all)")
+	maxAge := fs.Duration("max-age", 0, "Remove snapshots in -dir older than this (0 keeps them)")
+	plain := fs.Bool("plain", false, "Do not encrypt the backup file even if a key is configured")
+	decryptFile := fs.String("decrypt", "", "Decrypt this encrypted backup to -out instead of backing up")
+	fs.Parse(args)
+
+	if (*out == "") == (*dir == "") {
+		return fmt.Errorf("one of -out or -dir is required")
+	}
+
+	cipher, err := encryption.LoadCipher(*storeFlags.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if *decryptFile != "" {
+		if cipher == nil {
+			return fmt.Errorf("an encryption key is required to decrypt a backup")
+		}
+		if *out == "" {
+			return fmt.Errorf("-decrypt requires -out")
+		}
+		return decryptBackup(cipher, *decryptFile, *out)
+	}
+
+	store, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	stor, ok := store.(*storage.SQLiteStorage)
+	if !ok {
+		return fmt.Errorf("backup only supports SQLite databases; use pg_dump for PostgreSQL")
+	}
+
+	if *plain {
+		cipher = nil
+	}
+
+	if *dir != "" {
+		manager := backup.NewManager(*dir, stor, cipher, backup.Policy{Keep: *keep, MaxAge: *maxAge})
+		snapshot, removed, err := manager.Create()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot written to %s (sha256 %s)\n", snapshot.Path, snapshot.SHA256)
+		for _, name := range removed {
+			fmt.Printf("Removed old snapshot %s\n", name)
+		}
+		return nil
+	}
+
+	snapshot, err := backup.Write(stor, cipher, *out)
+	if err != nil {
+		return err
+	}
+	if cipher != nil {
+		fmt.Printf("Encrypted backup written to %s (key %s, sha256 %s)\n", snapshot.Path, cipher.KeyID(), snapshot.SHA256)
+	} else {
+		fmt.Printf("Backup written to %s (sha256 %s)\n", snapshot.Path, snapshot.SHA256)
+	}
+	return nil
+}
+
+func decryptBackup(cipher *encryption.FieldCipher, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := cipher.DecryptStream(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted backup written to %s\n", dst)
+	return nil
+}
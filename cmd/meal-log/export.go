@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+// runExportCommand streams a profile's meals to a file or stdout.
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	profile := fs.String("profile", models.DefaultProfileID, "Profile to export")
+	format := fs.String("format", string(export.FormatCSV), "Output format: csv, json or ndjson")
+	rows := fs.String("rows", string(export.RowsMeal), "One row per meal or per food: meal or food")
+	startDate := fs.String("start-date", "", "First day to export (YYYY-MM-DD)")
+	endDate := fs.String("end-date", "", "Last day to export (YYYY-MM-DD)")
+	includeDeleted := fs.Bool("include-deleted", false, "Include meals in the trash")
+	out := fs.String("out", "", "File to write (default stdout)")
+	fs.Parse(args)
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	settings, err := stor.GetSettings(*profile)
+	if err != nil {
+		return err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+
+	var dest io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dest = f
+	}
+
+	w, err := export.NewWriter(dest, export.Options{
+		Format:   export.Format(*format),
+		Rows:     export.Rows(*rows),
+		Location: loc,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := storage.MealQuery{
+		StartDate:      *startDate,
+		EndDate:        *endDate,
+		IncludeDeleted: *includeDeleted,
+	}
+	if err := stor.StreamMeals(*profile, query, w.WriteMeal); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d rows to %s\n", w.Count(), *out)
+	}
+	return nil
+}
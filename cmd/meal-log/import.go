@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/importer"
+	"mcp-meal-log/internal/models"
+)
+
+// runImportCommand loads meals from a file in the export format, from
+// another tracker's CSV with -map, or from a supported tracker's own export
+// with -source. Use -dry-run to preview the result.
+func runImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	profile := fs.String("profile", models.DefaultProfileID, "Profile to import into")
+	in := fs.String("in", "", "File to import (- for stdin)")
+	source := fs.String("source", "", "Tracker the file comes from: "+strings.Join(importer.Sources, ", ")+" (default the meal-log export format)")
+	format := fs.String("format", "", "Input format: csv, json or ndjson (default from the file name)")
+	mapping := fs.String("map", "", "Column mapping as field=column pairs, e.g. description=Meal,timestamp=Date")
+	var layouts []string
+	fs.Func("layout", "Extra timestamp layout in Go time format (repeatable)", func(layout string) error {
+		layouts = append(layouts, layout)
+		return nil
+	})
+	dryRun := fs.Bool("dry-run", false, "Report what would be inserted or skipped without saving")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "Meals saved per transaction")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	columns, err := parseMapping(*mapping)
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+	if *format == "" {
+		*format = string(importer.DetectFormat(*in))
+	}
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	settings, err := stor.GetSettings(*profile)
+	if err != nil {
+		return err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+
+	origin := "import"
+	if *source != "" {
+		origin = *source
+	}
+
+	report, err := importer.Import(stor, src, importer.Options{
+		ProfileID:        *profile,
+		Source:           *source,
+		Format:           export.Format(*format),
+		Mapping:          columns,
+		TimestampLayouts: layouts,
+		Location:         loc,
+		DryRun:           *dryRun,
+		BatchSize:        *batchSize,
+		Change:           models.ChangeSource{Tool: "import", Origin: origin, Actor: "cli"},
+	})
+	if report != nil {
+		printImportReport(report, loc)
+	}
+	return err
+}
+
+func parseMapping(value string) (map[string]string, error) {
+	columns := map[string]string{}
+	if value == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok || field == "" || column == "" {
+			return nil, fmt.Errorf("invalid mapping %q (want field=column)", pair)
+		}
+		columns[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	}
+	return columns, nil
+}
+
+// printImportReport lists every meal in a dry run and only the skipped ones
+// otherwise, followed by the totals.
+func printImportReport(report *importer.Report, loc *time.Location) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tACTION\tTIMESTAMP\tDESCRIPTION\tREASON")
+	for _, e := range report.Entries {
+		if !report.DryRun && e.Action != importer.ActionSkip {
+			continue
+		}
+		timestamp := "-"
+		if e.Timestamp != nil {
+			timestamp = e.Timestamp.In(loc).Format(time.RFC3339)
+		}
+		description := strings.Join(strings.Fields(e.Description), " ")
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.Row, e.Action, timestamp, description, e.Reason)
+	}
+	w.Flush()
+
+	verb := "Inserted"
+	if report.DryRun {
+		verb = "Would insert"
+	}
+	fmt.Printf("\nRead %d meals. %s %d, skipped %d.\n", report.Read, verb, report.Inserted, report.Skipped)
+}
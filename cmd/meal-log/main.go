@@ -8,21 +8,81 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/backup"
 	"mcp-meal-log/internal/server"
+	"mcp-meal-log/internal/storage"
 )
 
+const defaultDBPath = "/data/meal-log.db"
+
 var (
-	transport = flag.String("transport", "http", "Transport mode: http")
-	port      = flag.Int("port", 8011, "Port for HTTP transport")
-	host      = flag.String("host", "0.0.0.0", "Host address")
-	address   = flag.String("address", "", "Address (alias for host)")
-	dbPath    = flag.String("db-path", "/data/meal-log.db", "Database path")
-	version   = flag.Bool("version", false, "Show version")
+	transport       = flag.String("transport", "http", "Transport mode: http")
+	port            = flag.Int("port", 8011, "Port for HTTP transport")
+	host            = flag.String("host", "0.0.0.0", "Host address")
+	address         = flag.String("address", "", "Address (alias for host)")
+	dbPath          = flag.String("db-path", defaultDBPath, "Database path")
+	databaseURL     = flag.String("database-url", "", "PostgreSQL connection string, used instead of the SQLite database at -db-path")
+	authMode        = flag.String("auth", server.AuthModeToken, "Authentication mode: token or none")
+	corsOrigins     = flag.String("cors-origins", "", "Comma-separated browser origins allowed by CORS (\"*\" for any)")
+	jwksFile        = flag.String("jwks-file", "", "JWKS file for validating OAuth access tokens (enables JWT auth)")
+	jwtIssuer       = flag.String("jwt-issuer", "", "Required issuer (iss) of OAuth access tokens")
+	jwtAudience     = flag.String("jwt-audience", "", "Required audience (aud) of OAuth access tokens; mandatory with -jwks-file")
+	jwtProfileClaim = flag.String("jwt-profile-claim", "profile", "Access token claim naming the profile")
+	resourceURL     = flag.String("resource-url", "", "Public URL of this server for OAuth resource metadata")
+	trashRetention  = flag.Duration("trash-retention", 30*24*time.Hour, "How long deleted meals can be restored before they are purged (0 keeps them)")
+	pdfCommand      = flag.String("pdf-command", "", "HTML to PDF converter for reports, with {in} and {out} placeholders (default wkhtmltopdf or Chromium on PATH)")
+	keyFile         = flag.String("encryption-key-file", "", "File holding the database encryption key (default $MEAL_LOG_ENCRYPTION_KEY)")
+	backupDir       = flag.String("backup-dir", "", "Directory for database snapshots (enables scheduled snapshots and the create_snapshot tool)")
+	backupInterval  = flag.Duration("backup-interval", 24*time.Hour, "How often to write a snapshot to -backup-dir (0 only on request)")
+	backupKeep      = flag.Int("backup-keep", 7, "Snapshots kept in -backup-dir (0 keeps all)")
+	backupMaxAge    = flag.Duration("backup-max-age", 0, "Remove snapshots older than this from -backup-dir (0 keeps them)")
+	maxConns        = flag.Int("sqlite-max-conns", storage.DefaultMaxOpenConns, "Maximum open database connections")
+	version         = flag.Bool("version", false, "Show version")
 )
 
+// commands are the subcommands run instead of the server, e.g.
+// "meal-log token create".
+var commands = map[string]func(args []string) error{
+	"token":    runTokenCommand,
+	"rekey":    runRekeyCommand,
+	"backup":   runBackupCommand,
+	"restore":  runRestoreCommand,
+	"export":   runExportCommand,
+	"import":   runImportCommand,
+	"report":   runReportCommand,
+	"products": runProductsCommand,
+}
+
+// pragmas collects -sqlite-pragma flags.
+var pragmas []storage.Pragma
+
+func init() {
+	flag.Func("sqlite-pragma", "SQLite pragma applied to every connection as name=value, e.g. synchronous=NORMAL (repeatable)", func(s string) error {
+		p, err := storage.ParsePragma(s)
+		if err != nil {
+			return err
+		}
+		pragmas = append(pragmas, p)
+		return nil
+	})
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "meal-log %s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if *version {
@@ -37,10 +97,32 @@ func main() {
 	}
 
 	config := &server.Config{
-		Transport: *transport,
-		Host:      hostAddr,
-		Port:      *port,
-		DBPath:    *dbPath,
+		Transport:   *transport,
+		Host:        hostAddr,
+		Port:        *port,
+		DBPath:      *dbPath,
+		DatabaseURL: *databaseURL,
+		AuthMode:    *authMode,
+		JWT: auth.JWTConfig{
+			JWKSFile:     *jwksFile,
+			Issuer:       *jwtIssuer,
+			Audience:     *jwtAudience,
+			ProfileClaim: *jwtProfileClaim,
+		},
+		ResourceURL:       *resourceURL,
+		EncryptionKeyFile: *keyFile,
+		TrashRetention:    *trashRetention,
+		PDFCommand:        *pdfCommand,
+		Pragmas:           pragmas,
+		MaxOpenConns:      *maxConns,
+		BackupDir:         *backupDir,
+		BackupInterval:    *backupInterval,
+		BackupPolicy:      backup.Policy{Keep: *backupKeep, MaxAge: *backupMaxAge},
+	}
+	if *corsOrigins != "" {
+		for _, origin := range strings.Split(*corsOrigins, ",") {
+			config.CORSOrigins = append(config.CORSOrigins, strings.TrimSpace(origin))
+		}
 	}
 
 	// Create server
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"mcp-meal-log/internal/products"
+)
+
+func runProductsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: meal-log products import [flags]")
+	}
+
+	switch args[0] {
+	case "import":
+		return runProductsImport(args[1:])
+	default:
+		return fmt.Errorf("unknown products command: %s", args[0])
+	}
+}
+
+// runProductsImport loads products for barcode lookups from an Open Food
+// Facts dump: the CSV export or the JSONL dump, optionally gzipped.
+func runProductsImport(args []string) error {
+	fs := flag.NewFlagSet("products import", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	in := fs.String("in", "", "Open Food Facts dump to import (- for stdin)")
+	batchSize := fs.Int("batch-size", products.DefaultBatchSize, "Products saved per transaction")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	var src io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	report, err := products.ImportOpenFoodFacts(stor, src, *batchSize)
+	if report != nil {
+		fmt.Printf("Read %d products. Imported %d, skipped %d without a barcode, name or carbohydrates.\n",
+			report.Read, report.Imported, report.Skipped)
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"mcp-meal-log/internal/encryption"
+)
+
+// runRekeyCommand re-encrypts the database with a new key. With -decrypt it
+// removes encryption instead.
+func runRekeyCommand(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	newKeyFile := fs.String("new-key-file", "", "File holding the new encryption key")
+	decrypt := fs.Bool("decrypt", false, "Decrypt the database instead of switching keys")
+	fs.Parse(args)
+
+	if *newKeyFile == "" && !*decrypt {
+		return fmt.Errorf("either -new-key-file or -decrypt is required")
+	}
+
+	var newCipher *encryption.FieldCipher
+	if !*decrypt {
+		key, err := encryption.LoadKey(*newKeyFile)
+		if err != nil {
+			return err
+		}
+		if newCipher, err = encryption.NewFieldCipher(key); err != nil {
+			return err
+		}
+	}
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	if err := stor.Rekey(newCipher); err != nil {
+		return err
+	}
+
+	if newCipher == nil {
+		fmt.Println("Database decrypted")
+	} else {
+		fmt.Printf("Database re-encrypted with key %s\n", newCipher.KeyID())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/report"
+)
+
+// runReportCommand writes an HTML or PDF report for a date range. The
+// format follows the -out file extension.
+func runReportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	profile := fs.String("profile", models.DefaultProfileID, "Profile to report on")
+	startDate := fs.String("start-date", "", fmt.Sprintf("First day of the report (YYYY-MM-DD, default %d days before the end)", report.DefaultDays-1))
+	endDate := fs.String("end-date", "", "Last day of the report (YYYY-MM-DD, default today)")
+	topFoods := fs.Int("top-foods", report.DefaultTopFoods, "Number of foods to list")
+	out := fs.String("out", "", "Report file to write, ending in .html or .pdf")
+	pdfCommand := fs.String("pdf-command", "", "HTML to PDF converter with {in} and {out} placeholders (default wkhtmltopdf or Chromium on PATH)")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+	pdf := strings.HasSuffix(strings.ToLower(*out), ".pdf")
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	settings, err := stor.GetSettings(*profile)
+	if err != nil {
+		return err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+	start, end, err := report.DateRange(*startDate, *endDate, loc)
+	if err != nil {
+		return err
+	}
+
+	summary, err := stor.Summarize(*profile, start, end, *topFoods)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := report.Render(&buf, summary); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if pdf {
+		if data, err = report.ConvertPDF(context.Background(), data, *pdfCommand); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Report for %s to %s written to %s\n", start, end, *out)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/backup"
+	"mcp-meal-log/internal/encryption"
+)
+
+// runRestoreCommand replaces the database with a backup, either a file
+// given with -from or the newest snapshot in -dir taken at or before -at.
+// The server must be stopped first.
+func runRestoreCommand(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	from := fs.String("from", "", "Backup file to restore")
+	dir := fs.String("dir", "", "Snapshot directory to restore from instead of -from")
+	at := fs.String("at", "", "With -dir, restore the newest snapshot taken at or before this RFC 3339 time (default newest)")
+	fs.Parse(args)
+
+	if (*from == "") == (*dir == "") {
+		return fmt.Errorf("one of -from or -dir is required")
+	}
+	if *storeFlags.databaseURL != "" {
+		return fmt.Errorf("restore only supports SQLite databases; use pg_restore for PostgreSQL")
+	}
+
+	cipher, err := encryption.LoadCipher(*storeFlags.keyFile)
+	if err != nil {
+		return err
+	}
+
+	path := *from
+	if *dir != "" {
+		if path, err = findSnapshot(*dir, *at); err != nil {
+			return err
+		}
+	}
+
+	aside, err := backup.Restore(path, *storeFlags.dbPath, cipher)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s to %s\n", path, *storeFlags.dbPath)
+	if aside != "" {
+		fmt.Printf("The previous database was kept as %s\n", aside)
+	}
+	return nil
+}
+
+func findSnapshot(dir, at string) (string, error) {
+	before := time.Now()
+	if at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return "", fmt.Errorf("invalid -at time: %w", err)
+		}
+		before = t
+	}
+
+	snapshots, err := backup.NewManager(dir, nil, nil, backup.Policy{}).List()
+	if err != nil {
+		return "", err
+	}
+	for _, snapshot := range snapshots {
+		if !snapshot.CreatedAt.After(before) {
+			return snapshot.Path, nil
+		}
+	}
+	return "", fmt.Errorf("no snapshot in %s taken at or before %s", dir, before.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/storage"
+)
+
+// storageFlags are the flags every subcommand needs to open the database.
+type storageFlags struct {
+	dbPath      *string
+	databaseURL *string
+	keyFile     *string
+}
+
+func addStorageFlags(fs *flag.FlagSet) *storageFlags {
+	return &storageFlags{
+		dbPath:      fs.String("db-path", defaultDBPath, "Database path"),
+		databaseURL: fs.String("database-url", "", "PostgreSQL connection string, used instead of -db-path"),
+		keyFile:     fs.String("encryption-key-file", "", "File holding the database encryption key (default $"+encryption.KeyEnvVar+")"),
+	}
+}
+
+func (f *storageFlags) open() (storage.Store, error) {
+	cipher, err := encryption.LoadCipher(*f.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(*f.dbPath, *f.databaseURL, storage.WithCipher(cipher))
+}
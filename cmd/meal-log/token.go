@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/models"
+)
+
+func runTokenCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: meal-log token create|list|revoke [flags]")
+	}
+
+	switch args[0] {
+	case "create":
+		return runTokenCreate(args[1:])
+	case "list":
+		return runTokenList(args[1:])
+	case "revoke":
+		return runTokenRevoke(args[1:])
+	default:
+		return fmt.Errorf("unknown token command: %s", args[0])
+	}
+}
+
+func runTokenCreate(args []string) error {
+	fs := flag.NewFlagSet("token create", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	name := fs.String("name", "", "Name describing who uses the token")
+	profile := fs.String("profile", models.DefaultProfileID, "Profile the token is limited to")
+	allProfiles := fs.Bool("all-profiles", false, "Allow the token to access every profile")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	token := &models.APIToken{
+		Name:      *name,
+		ProfileID: *profile,
+		CreatedAt: time.Now(),
+	}
+	if *allProfiles {
+		token.ProfileID = ""
+	} else if _, err := stor.GetProfile(token.ProfileID); err != nil {
+		return err
+	}
+
+	id, secret, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	token.ID = id
+	if err := stor.CreateToken(token, auth.HashToken(secret)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Created token %s. Store the secret now; it cannot be shown again.\n", id)
+	fmt.Println(secret)
+	return nil
+}
+
+func runTokenList(args []string) error {
+	fs := flag.NewFlagSet("token list", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	fs.Parse(args)
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	tokens, err := stor.ListTokens()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPROFILE\tCREATED\tLAST USED\tSTATUS")
+	for _, t := range tokens {
+		profile := t.ProfileID
+		if profile == "" {
+			profile = "*"
+		}
+		lastUsed := "never"
+		if t.LastUsedAt != nil {
+			lastUsed = t.LastUsedAt.Local().Format(time.RFC3339)
+		}
+		status := "active"
+		if t.RevokedAt != nil {
+			status = "revoked " + t.RevokedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.Name, profile, t.CreatedAt.Local().Format(time.RFC3339), lastUsed, status)
+	}
+	return tw.Flush()
+}
+
+func runTokenRevoke(args []string) error {
+	fs := flag.NewFlagSet("token revoke", flag.ExitOnError)
+	storeFlags := addStorageFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: meal-log token revoke [flags] TOKEN_ID")
+	}
+
+	stor, err := storeFlags.open()
+	if err != nil {
+		return err
+	}
+	defer stor.Close()
+
+	if err := stor.RevokeToken(fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked token %s\n", fs.Arg(0))
+	return nil
+}
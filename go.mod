@@ -3,7 +3,10 @@ module mcp-meal-log
 
 go 1.23
 
-require modernc.org/sqlite v1.28.0
+require (
+	github.com/lib/pq v1.10.9
+	modernc.org/sqlite v1.28.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestJWKS writes a JWKS file holding the public half of a new P-256
+// key and returns the file and the private key.
+func newTestJWKS(t *testing.T) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]interface{}{"keys": []jwk{{
+		Kty: "EC",
+		Kid: "test",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims Claims) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"test"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTValidatorRequiresAudience(t *testing.T) {
+	path, _ := newTestJWKS(t)
+	if _, err := NewJWTValidator(JWTConfig{JWKSFile: path}); err == nil {
+		t.Fatal("NewJWTValidator accepted a configuration without an audience")
+	}
+}
+
+func TestJWTValidatorChecksAudience(t *testing.T) {
+	path, key := newTestJWKS(t)
+	v, err := NewJWTValidator(JWTConfig{JWKSFile: path, Audience: "https://meals.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	tests := []struct {
+		name string
+		aud  interface{}
+		ok   bool
+	}{
+		{"matching", "https://meals.example", true},
+		{"in list", []interface{}{"https://other.example", "https://meals.example"}, true},
+		{"other resource", "https://other.example", false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := Claims{"sub": "alice", "exp": exp}
+			if tt.aud != nil {
+				claims["aud"] = tt.aud
+			}
+			_, err := v.Validate(signES256(t, key, claims))
+			if tt.ok && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Validate error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// tokenPrefix marks secrets issued by this server so they are easy to spot
+// in configuration files and logs.
+const tokenPrefix = "mlt_"
+
+// NewToken returns a token ID and a fresh random secret.
+func NewToken() (id, secret string, err error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate token secret: %w", err)
+	}
+	return "tok_" + hex.EncodeToString(idBytes), tokenPrefix + base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+// HashToken is the value stored in the database for a token secret. Secrets
+// are 256 random bits, so a plain SHA-256 is sufficient.
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksLikeAPIToken reports whether a bearer credential is one of our opaque
+// tokens rather than a JWT.
+func LooksLikeAPIToken(credential string) bool {
+	return strings.HasPrefix(credential, tokenPrefix)
+}
+
+// BearerToken extracts the credential from an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
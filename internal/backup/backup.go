@@ -0,0 +1,389 @@
+// Package backup writes snapshots of the SQLite database, each with a
+// SHA-256 checksum beside it, rotates them according to a retention policy
+// and restores them after checking their integrity.
+package backup
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/storage"
+)
+
+// ErrNoChecksum is returned by Verify for a file without a checksum.
+var ErrNoChecksum = errors.New("backup has no checksum file")
+
+// Source copies the live database to a file; *storage.SQLiteStorage is one.
+type Source interface {
+	BackupTo(path string) error
+}
+
+// Snapshot is a backup file and its checksum.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// checksumPath is the sidecar holding a file's checksum, in the format of
+// sha256sum so "sha256sum -c" can check it too.
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
+// Write copies the database to path, encrypting the copy when cipher is
+// set, and writes its checksum beside it. The file only appears under path
+// once it is complete.
+func Write(source Source, cipher *encryption.FieldCipher, path string) (*Snapshot, error) {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	os.Remove(tmp)
+	if err := source.BackupTo(tmp); err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	if cipher != nil {
+		plain := tmp
+		tmp = plain + ".enc"
+		defer os.Remove(tmp)
+		if err := encryptFile(cipher, plain, tmp); err != nil {
+			return nil, err
+		}
+	}
+
+	sum, size, err := checksum(tmp)
+	if err != nil {
+		return nil, err
+	}
+	line := sum + "  " + filepath.Base(path) + "\n"
+	if err := os.WriteFile(checksumPath(path), []byte(line), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write checksum: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(checksumPath(path))
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return &Snapshot{
+		Name:      filepath.Base(path),
+		Path:      path,
+		Size:      size,
+		SHA256:    sum,
+		CreatedAt: time.Now().UTC(),
+		Encrypted: cipher != nil,
+	}, nil
+}
+
+func encryptFile(cipher *encryption.FieldCipher, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := cipher.EncryptStream(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	return out.Close()
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// Verify checks a backup file against its checksum.
+func Verify(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(checksumPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoChecksum
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum: %w", err)
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+
+	sum, size, err := checksum(path)
+	if err != nil {
+		return nil, err
+	}
+	if sum != want {
+		return nil, fmt.Errorf("%s is corrupted: checksum %s does not match %s", path, sum, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := isEncrypted(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Name:      filepath.Base(path),
+		Path:      path,
+		Size:      size,
+		SHA256:    sum,
+		CreatedAt: info.ModTime().UTC(),
+		Encrypted: encrypted,
+	}, nil
+}
+
+func isEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return encryption.IsEncryptedFile(bufio.NewReader(f)), nil
+}
+
+// Policy decides which snapshots are kept. The newest snapshot is always
+// kept.
+type Policy struct {
+	// Keep is the number of snapshots kept; zero keeps any number.
+	Keep int
+	// MaxAge removes snapshots older than this; zero keeps them forever.
+	MaxAge time.Duration
+}
+
+const (
+	snapshotPrefix = "meal-log-"
+	snapshotTime   = "20060102T150405.000Z"
+)
+
+// Manager keeps rotating snapshots in a directory.
+type Manager struct {
+	dir    string
+	source Source
+	cipher *encryption.FieldCipher
+	policy Policy
+
+	// mu serializes snapshots so pruning never races a new one.
+	mu sync.Mutex
+}
+
+func NewManager(dir string, source Source, cipher *encryption.FieldCipher, policy Policy) *Manager {
+	return &Manager{dir: dir, source: source, cipher: cipher, policy: policy}
+}
+
+// Create writes a new snapshot and then removes the snapshots the policy
+// no longer keeps, returning their names.
+func (m *Manager) Create() (*Snapshot, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	now := time.Now().UTC()
+	name := snapshotPrefix + now.Format(snapshotTime) + ".db"
+	if m.cipher != nil {
+		name += ".enc"
+	}
+	snapshot, err := Write(m.source, m.cipher, filepath.Join(m.dir, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot.CreatedAt = now
+
+	removed, err := m.prune(now)
+	return snapshot, removed, err
+}
+
+// List returns the snapshots in the directory, newest first. Snapshots are
+// not verified; use Verify before relying on one.
+func (m *Manager) List() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, snapshotPrefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		encrypted := strings.HasSuffix(stamp, ".db.enc")
+		stamp, ok = strings.CutSuffix(strings.TrimSuffix(stamp, ".enc"), ".db")
+		if !ok {
+			continue
+		}
+		createdAt, err := time.Parse(snapshotTime, stamp)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Name:      name,
+			Path:      filepath.Join(m.dir, name),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+			Encrypted: encrypted,
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Latest returns the newest snapshot, or nil if there is none.
+func (m *Manager) Latest() (*Snapshot, error) {
+	snapshots, err := m.List()
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+func (m *Manager) prune(now time.Time) ([]string, error) {
+	snapshots, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i, snapshot := range snapshots {
+		if i == 0 {
+			continue
+		}
+		tooMany := m.policy.Keep > 0 && i >= m.policy.Keep
+		tooOld := m.policy.MaxAge > 0 && now.Sub(snapshot.CreatedAt) > m.policy.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove old snapshot: %w", err)
+		}
+		os.Remove(checksumPath(snapshot.Path))
+		removed = append(removed, snapshot.Name)
+	}
+	return removed, nil
+}
+
+// Restore replaces the database at dbPath with a backup. The backup is
+// checked against its checksum when it has one, decrypted if needed and
+// checked with SQLite's integrity_check before it is swapped in. The
+// current database is kept beside it and its path returned. The server
+// must not be running.
+func Restore(path, dbPath string, cipher *encryption.FieldCipher) (string, error) {
+	if _, err := Verify(path); err != nil && !errors.Is(err, ErrNoChecksum) {
+		return "", err
+	}
+
+	tmp := filepath.Join(filepath.Dir(dbPath), "."+filepath.Base(dbPath)+".restore")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+	if err := copyBackup(path, tmp, cipher); err != nil {
+		return "", err
+	}
+	if err := storage.VerifyFile(tmp); err != nil {
+		return "", fmt.Errorf("refusing to restore %s: %w", path, err)
+	}
+
+	// The write-ahead log belongs to the current database, so it moves
+	// with it
+	aside := ""
+	var moved []string
+	if _, err := os.Stat(dbPath); err == nil {
+		aside = dbPath + ".pre-restore-" + time.Now().UTC().Format(snapshotTime)
+		if _, err := os.Stat(aside); err == nil {
+			return "", fmt.Errorf("%s already exists", aside)
+		}
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			err := os.Rename(dbPath+suffix, aside+suffix)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				putBack(dbPath, aside, moved)
+				return "", fmt.Errorf("failed to move current database aside: %w", err)
+			}
+			moved = append(moved, suffix)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		putBack(dbPath, aside, moved)
+		return "", fmt.Errorf("failed to restore database: %w", err)
+	}
+	return aside, nil
+}
+
+// putBack returns the files Restore moved aside, so a failed restore
+// leaves the current database where it was.
+func putBack(dbPath, aside string, suffixes []string) {
+	for _, suffix := range suffixes {
+		os.Rename(aside+suffix, dbPath+suffix)
+	}
+}
+
+// copyBackup copies a backup to dst, decrypting it if it is encrypted.
+func copyBackup(src, dst string, cipher *encryption.FieldCipher) error {
+	encrypted, err := isEncrypted(src)
+	if err != nil {
+		return err
+	}
+	if encrypted && cipher == nil {
+		return fmt.Errorf("%s is encrypted; configure the encryption key", src)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		err = cipher.DecryptStream(out, in)
+	} else {
+		_, err = io.Copy(out, in)
+	}
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	return out.Close()
+}
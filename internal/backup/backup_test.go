@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/storage"
+)
+
+// fileSource "backs up" fixed contents.
+type fileSource []byte
+
+func (s fileSource) BackupTo(path string) error {
+	return os.WriteFile(path, s, 0600)
+}
+
+func TestWriteAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meal-log.db")
+	written, err := Write(fileSource("not really a database"), nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.SHA256 != written.SHA256 || verified.Size != int64(len("not really a database")) {
+		t.Errorf("Verify = %+v, Write = %+v", verified, written)
+	}
+
+	if err := os.WriteFile(path, []byte("not really a databasE"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(path); err == nil || !strings.Contains(err.Error(), "is corrupted") {
+		t.Errorf("Verify of a changed file = %v", err)
+	}
+
+	os.Remove(checksumPath(path))
+	if _, err := Verify(path); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("Verify without a checksum = %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	ages := []time.Duration{time.Hour, 2 * time.Hour, 10 * time.Hour, 30 * time.Hour, 60 * time.Hour, 100 * time.Hour}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		kept   int
+	}{
+		{"keep any number", Policy{}, 6},
+		{"keep count", Policy{Keep: 4}, 4},
+		{"max age", Policy{MaxAge: 48 * time.Hour}, 4},
+		{"count and age", Policy{Keep: 3, MaxAge: 48 * time.Hour}, 3},
+		{"newest is always kept", Policy{Keep: 1, MaxAge: time.Minute}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var names []string
+			for _, age := range ages {
+				name := snapshotPrefix + now.Add(-age).Format(snapshotTime) + ".db"
+				names = append(names, name)
+				for _, path := range []string{filepath.Join(dir, name), checksumPath(filepath.Join(dir, name))} {
+					if err := os.WriteFile(path, nil, 0600); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			m := NewManager(dir, nil, nil, tt.policy)
+			removed, err := m.prune(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := names[tt.kept:]; len(removed) != len(want) || (len(want) > 0 && !reflect.DeepEqual(removed, want)) {
+				t.Errorf("removed %v, want %v", removed, want)
+			}
+
+			// Checksums go with their snapshots
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left []string
+			for _, entry := range entries {
+				left = append(left, entry.Name())
+			}
+			var want []string
+			for _, name := range names[:tt.kept] {
+				want = append(want, name, name+".sha256")
+			}
+			sort.Strings(want)
+			if !reflect.DeepEqual(left, want) {
+				t.Errorf("left %v, want %v", left, want)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "meals.db")
+	store, err := storage.NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath := filepath.Join(dir, "backup.db")
+	if _, err := Write(store, nil, backupPath); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	live, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("corrupt backup", func(t *testing.T) {
+		data, err := os.ReadFile(backupPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupt := filepath.Join(dir, "corrupt.db")
+		data[len(data)/2] ^= 0xff
+		if err := os.WriteFile(corrupt, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := os.ReadFile(checksumPath(backupPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(checksumPath(corrupt), sum, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Restore(corrupt, dbPath, nil); err == nil || !strings.Contains(err.Error(), "is corrupted") {
+			t.Errorf("Restore = %v, want a checksum mismatch", err)
+		}
+	})
+
+	t.Run("not a database", func(t *testing.T) {
+		other := filepath.Join(dir, "notes.txt")
+		if err := os.WriteFile(other, []byte(strings.Repeat("not sqlite ", 1000)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Restore(other, dbPath, nil); err == nil || !strings.Contains(err.Error(), "refusing to restore") {
+			t.Errorf("Restore = %v, want a refusal", err)
+		}
+	})
+
+	// Neither refusal touched the live database
+	if data, err := os.ReadFile(dbPath); err != nil || !reflect.DeepEqual(data, live) {
+		t.Fatalf("live database changed after a refused restore: %v", err)
+	}
+	if matches, _ := filepath.Glob(dbPath + ".pre-restore-*"); len(matches) != 0 {
+		t.Fatalf("refused restore moved the database aside: %v", matches)
+	}
+
+	aside, err := Restore(backupPath, dbPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(aside); err != nil || !reflect.DeepEqual(data, live) {
+		t.Errorf("previous database not kept at %s: %v", aside, err)
+	}
+	if err := storage.VerifyFile(dbPath); err != nil {
+		t.Errorf("restored database: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package encryption protects sensitive meal data at rest with AES-256-GCM.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyEnvVar holds the key when no key file is configured.
+const KeyEnvVar = "MEAL_LOG_ENCRYPTION_KEY"
+
+// fieldPrefix marks an encrypted column value: enc:v1:<key id>:<base64>.
+const fieldPrefix = "enc:v1:"
+
+var ErrWrongKey = errors.New("value was encrypted with a different key")
+
+// FieldCipher encrypts individual column values. Each value gets a random
+// nonce, so equal plaintexts produce different ciphertexts.
+type FieldCipher struct {
+	aead  cipher.AEAD
+	key   []byte
+	keyID string
+}
+
+func NewFieldCipher(key []byte) (*FieldCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &FieldCipher{aead: aead, key: key, keyID: hex.EncodeToString(sum[:4])}, nil
+}
+
+// KeyID identifies the key without revealing it. It is stored next to every
+// ciphertext so a wrong key is reported instead of producing garbage.
+func (c *FieldCipher) KeyID() string {
+	return c.keyID
+}
+
+func (c *FieldCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(c.keyID))
+	return fieldPrefix + c.keyID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values without the encryption prefix are
+// returned unchanged so rows written before encryption was enabled still
+// read correctly.
+func (c *FieldCipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	rest := strings.TrimPrefix(value, fieldPrefix)
+	keyID, data, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	if keyID != c.keyID {
+		return "", fmt.Errorf("%w (key %s)", ErrWrongKey, keyID)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, fieldPrefix)
+}
+
+// LoadKey reads a 32-byte key from path, or from KeyEnvVar when path is
+// empty. The key may be raw bytes or base64/hex text. It returns nil, nil
+// when neither source is set.
+func LoadKey(path string) ([]byte, error) {
+	var data []byte
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+	} else if env := os.Getenv(KeyEnvVar); env != "" {
+		data = []byte(env)
+	} else {
+		return nil, nil
+	}
+	return ParseKey(data)
+}
+
+func ParseKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 32 bytes, as raw bytes, base64 or hex")
+}
+
+// LoadCipher is LoadKey followed by NewFieldCipher; it returns nil when no
+// key is configured.
+func LoadCipher(path string) (*FieldCipher, error) {
+	key, err := LoadKey(path)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return NewFieldCipher(key)
+}
@@ -0,0 +1,169 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Files are written as a header followed by sealed chunks:
+//
+//	magic | key id (8 bytes) | base nonce (12 bytes)
+//	{ length (4 bytes) | sealed chunk }...
+//
+// Each chunk's nonce is the base nonce XORed with its index, and the
+// additional data records whether it is the last chunk, so reordering or
+// truncating a file is detected on decryption.
+var fileMagic = []byte("MEALLOG-ENC1\n")
+
+const chunkSize = 64 * 1024
+
+var ErrNotEncryptedFile = errors.New("not an encrypted meal-log file")
+
+// EncryptStream copies src to dst encrypted with the cipher's key.
+func (c *FieldCipher) EncryptStream(dst io.Writer, src io.Reader) error {
+	aead, err := c.streamAEAD()
+	if err != nil {
+		return err
+	}
+
+	baseNonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(baseNonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := append(append(append([]byte{}, fileMagic...), c.keyID...), baseNonce...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(src, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	for index := uint64(0); ; index++ {
+		// Read ahead one chunk to know whether this one is the last
+		m, readErr := io.ReadFull(src, next)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return readErr
+		}
+		last := m == 0
+
+		sealed := aead.Seal(nil, chunkNonce(baseNonce, index), buf[:n], chunkAD(index, last))
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+		if _, err := dst.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+		buf, next = next, buf
+		n = m
+	}
+}
+
+// DecryptStream reverses EncryptStream.
+func (c *FieldCipher) DecryptStream(dst io.Writer, src io.Reader) error {
+	aead, err := c.streamAEAD()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, len(fileMagic)+len(c.keyID)+aead.NonceSize())
+	if _, err := io.ReadFull(src, header); err != nil {
+		return ErrNotEncryptedFile
+	}
+	if !bytes.Equal(header[:len(fileMagic)], fileMagic) {
+		return ErrNotEncryptedFile
+	}
+	keyID := string(header[len(fileMagic) : len(fileMagic)+len(c.keyID)])
+	if keyID != c.keyID {
+		return fmt.Errorf("%w (key %s)", ErrWrongKey, keyID)
+	}
+	baseNonce := header[len(fileMagic)+len(c.keyID):]
+
+	sealed := make([]byte, chunkSize+aead.Overhead())
+	for index := uint64(0); ; index++ {
+		var length [4]byte
+		if _, err := io.ReadFull(src, length[:]); err != nil {
+			return fmt.Errorf("encrypted file is truncated")
+		}
+		size := binary.BigEndian.Uint32(length[:])
+		if int(size) > len(sealed) {
+			return fmt.Errorf("encrypted file is corrupt")
+		}
+		if _, err := io.ReadFull(src, sealed[:size]); err != nil {
+			return fmt.Errorf("encrypted file is truncated")
+		}
+
+		// Try the chunk as a middle chunk first, then as the final one
+		nonce := chunkNonce(baseNonce, index)
+		plaintext, err := aead.Open(nil, nonce, sealed[:size], chunkAD(index, false))
+		last := false
+		if err != nil {
+			if plaintext, err = aead.Open(nil, nonce, sealed[:size], chunkAD(index, true)); err != nil {
+				return fmt.Errorf("failed to decrypt file: %w", err)
+			}
+			last = true
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return err
+		}
+
+		if last {
+			if n, _ := src.Read(length[:1]); n != 0 {
+				return fmt.Errorf("encrypted file has trailing data")
+			}
+			return nil
+		}
+	}
+}
+
+func (c *FieldCipher) streamAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := append([]byte{}, base...)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := range counter {
+		nonce[len(nonce)-8+i] ^= counter[i]
+	}
+	return nonce
+}
+
+func chunkAD(index uint64, last bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, index)
+	if last {
+		ad[8] = 1
+	}
+	return ad
+}
+
+// IsEncryptedFile reports whether r starts with the encrypted file header.
+func IsEncryptedFile(r io.Reader) bool {
+	header := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, fileMagic)
+}
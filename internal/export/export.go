@@ -0,0 +1,317 @@
+// Package export writes meals as CSV, JSON or NDJSON for spreadsheets and
+// other tools. Meals are written one at a time so exports can be streamed
+// straight from storage.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Rows selects whether each row is a meal or one food of a meal.
+type Rows string
+
+const (
+	RowsMeal Rows = "meal"
+	RowsFood Rows = "food"
+)
+
+type Options struct {
+	Format Format
+	Rows   Rows
+	// Location is the timezone timestamps are written in. Defaults to UTC.
+	Location *time.Location
+}
+
+// column is one field of an exported row. food is nil for per-meal rows and
+// for the single row written for a meal without foods in per-food mode.
+type column struct {
+	name  string
+	value func(w *Writer, meal *models.Meal, food *models.Food, index int) interface{}
+}
+
+// mealColumns come first in both row modes, in models.Meal field order.
+// Columns added later go in trailingColumns so existing columns keep their
+// position.
+var mealColumns = []column{
+	{"meal_id", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.ID }},
+	{"profile_id", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.ProfileID }},
+	{"description", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Description }},
+	{"timestamp", func(w *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return w.formatTime(m.Timestamp) }},
+	{"total_carbs", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.TotalCarbs }},
+	{"confidence", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return string(m.Confidence) }},
+	{"created_at", func(w *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return w.formatTime(m.CreatedAt) }},
+	{"updated_at", func(w *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return w.formatTime(m.UpdatedAt) }},
+	{"source", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Source }},
+	{"deleted_at", func(w *Writer, m *models.Meal, _ *models.Food, _ int) interface{} {
+		if m.DeletedAt == nil {
+			return nil
+		}
+		return w.formatTime(*m.DeletedAt)
+	}},
+}
+
+// trailingColumns end every row, after the food columns, in the order they
+// were added. New columns are appended here.
+var trailingColumns = []column{
+	{"slot", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Slot }},
+	{"tags", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} {
+		if m.Tags == nil {
+			return []string{}
+		}
+		return m.Tags
+	}},
+	{"notes", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Notes }},
+	{"location", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Location }},
+	{"restaurant", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.Restaurant }},
+	{"exercise_within_2h", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} {
+		if m.Exercise == nil {
+			return nil
+		}
+		return *m.Exercise
+	}},
+	{"photo_sha256", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} { return m.PhotoSHA256 }},
+}
+
+// perMealColumns embed the foods as a JSON array, which CSV writes as text.
+var perMealColumns = rowColumns(
+	column{"foods", func(_ *Writer, m *models.Meal, _ *models.Food, _ int) interface{} {
+		if m.Foods == nil {
+			return []models.Food{}
+		}
+		return m.Foods
+	}},
+)
+
+// perFoodColumns flatten one food per row, in models.Food field order.
+var perFoodColumns = rowColumns(
+	column{"food_index", foodValue(func(_ *models.Food, i int) interface{} { return i })},
+	column{"food_name", foodValue(func(f *models.Food, _ int) interface{} { return f.Name })},
+	column{"food_quantity", foodValue(func(f *models.Food, _ int) interface{} { return f.Quantity })},
+	column{"food_carbs_per_100g", foodValue(func(f *models.Food, _ int) interface{} { return f.CarbsPer100g })},
+	column{"food_estimated_carbs", foodValue(func(f *models.Food, _ int) interface{} { return f.EstimatedCarbs })},
+	column{"food_confidence", foodValue(func(f *models.Food, _ int) interface{} { return string(f.Confidence) })},
+)
+
+// rowColumns places the columns of a row mode between mealColumns and
+// trailingColumns.
+func rowColumns(middle ...column) []column {
+	columns := append([]column{}, mealColumns...)
+	columns = append(columns, middle...)
+	return append(columns, trailingColumns...)
+}
+
+func foodValue(fn func(food *models.Food, index int) interface{}) func(*Writer, *models.Meal, *models.Food, int) interface{} {
+	return func(_ *Writer, _ *models.Meal, food *models.Food, index int) interface{} {
+		if food == nil {
+			return nil
+		}
+		return fn(food, index)
+	}
+}
+
+// Writer writes meals in the configured format. Close must be called to
+// finish the document and flush buffered output.
+type Writer struct {
+	out     *bufio.Writer
+	csv     *csv.Writer
+	format  Format
+	rows    Rows
+	columns []column
+	loc     *time.Location
+	started bool
+	count   int
+}
+
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	ew := &Writer{
+		out:    bufio.NewWriter(w),
+		format: opts.Format,
+		rows:   opts.Rows,
+		loc:    opts.Location,
+	}
+	if ew.format == "" {
+		ew.format = FormatCSV
+	}
+	if ew.rows == "" {
+		ew.rows = RowsMeal
+	}
+	if ew.loc == nil {
+		ew.loc = time.UTC
+	}
+
+	switch ew.rows {
+	case RowsMeal:
+		ew.columns = perMealColumns
+	case RowsFood:
+		ew.columns = perFoodColumns
+	default:
+		return nil, fmt.Errorf("unknown row mode %q (want meal or food)", opts.Rows)
+	}
+
+	switch ew.format {
+	case FormatCSV:
+		ew.csv = csv.NewWriter(ew.out)
+		ew.csv.UseCRLF = true // RFC 4180 line endings
+	case FormatJSON, FormatNDJSON:
+	default:
+		return nil, fmt.Errorf("unknown export format %q (want csv, json or ndjson)", opts.Format)
+	}
+	return ew, nil
+}
+
+// Columns returns the column names in the order they are written.
+func (w *Writer) Columns() []string {
+	names := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Count returns the number of rows written so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// WriteMeal writes one row for the meal, or one row per food in per-food
+// mode. A meal without foods still gets a single row with empty food columns.
+func (w *Writer) WriteMeal(meal *models.Meal) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	if w.rows == RowsMeal || len(meal.Foods) == 0 {
+		return w.writeRow(meal, nil, 0)
+	}
+	for i := range meal.Foods {
+		if err := w.writeRow(meal, &meal.Foods[i], i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the document and flushes it to the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	switch w.format {
+	case FormatCSV:
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	case FormatJSON:
+		closing := "]\n"
+		if w.count > 0 {
+			closing = "\n]\n"
+		}
+		if _, err := w.out.WriteString(closing); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// start writes the CSV header or the opening JSON bracket, so even an empty
+// export is a valid document.
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	switch w.format {
+	case FormatCSV:
+		return w.csv.Write(w.Columns())
+	case FormatJSON:
+		_, err := w.out.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) writeRow(meal *models.Meal, food *models.Food, index int) error {
+	if w.format == FormatCSV {
+		record := make([]string, len(w.columns))
+		for i, c := range w.columns {
+			value, err := csvValue(c.value(w, meal, food, index))
+			if err != nil {
+				return err
+			}
+			record[i] = value
+		}
+		w.count++
+		return w.csv.Write(record)
+	}
+
+	if w.format == FormatJSON {
+		separator := "\n  "
+		if w.count > 0 {
+			separator = ",\n  "
+		}
+		if _, err := w.out.WriteString(separator); err != nil {
+			return err
+		}
+	}
+
+	// Objects are written field by field so keys keep the column order
+	w.out.WriteByte('{')
+	for i, c := range w.columns {
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		key, _ := json.Marshal(c.name)
+		value, err := json.Marshal(c.value(w, meal, food, index))
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", c.name, err)
+		}
+		w.out.Write(key)
+		w.out.WriteByte(':')
+		w.out.Write(value)
+	}
+	w.out.WriteByte('}')
+	if w.format == FormatNDJSON {
+		w.out.WriteByte('\n')
+	}
+	w.count++
+	return nil
+}
+
+func (w *Writer) formatTime(t time.Time) string {
+	return t.In(w.loc).Format(time.RFC3339)
+}
+
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+// The header order is part of the export format: spreadsheets and the
+// importer read columns by position or name, so existing columns must not
+// move when new ones are added.
+func TestCSVHeaderOrder(t *testing.T) {
+	meal := []string{
+		"meal_id", "profile_id", "description", "timestamp", "total_carbs", "confidence",
+		"created_at", "updated_at", "source", "deleted_at",
+	}
+	trailing := []string{
+		"slot", "tags", "notes", "location", "restaurant", "exercise_within_2h",
+		"photo_sha256",
+	}
+	tests := []struct {
+		rows   Rows
+		middle []string
+	}{
+		{RowsMeal, []string{"foods"}},
+		{RowsFood, []string{
+			"food_index", "food_name", "food_quantity", "food_carbs_per_100g",
+			"food_estimated_carbs", "food_confidence",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.rows), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, Options{Format: FormatCSV, Rows: tt.rows})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			header, err := csv.NewReader(&buf).Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			want = append(want, meal...)
+			want = append(want, tt.middle...)
+			want = append(want, trailing...)
+			if !reflect.DeepEqual(header, want) {
+				t.Errorf("header = %v\nwant     %v", header, want)
+			}
+		})
+	}
+}
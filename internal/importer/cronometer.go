@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"io"
+
+	"mcp-meal-log/internal/models"
+)
+
+// readCronometer reads the servings.csv export of Cronometer, with one row
+// per food. Foods are grouped into meals by day and diary group.
+func readCronometer(r io.Reader, opts Options) ([]*candidate, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireColumns(records, "Cronometer", []string{"Day"}, []string{"Food Name"}, []string{"Carbs (g)"}); err != nil {
+		return nil, err
+	}
+
+	var rows []diaryRow
+	for _, rec := range records {
+		carbs, err := diaryNumber(rec, "Carbs (g)")
+		rows = append(rows, diaryRow{
+			row:   rec.row,
+			date:  value(rec, "Day"),
+			clock: value(rec, "Time"),
+			group: value(rec, "Group"),
+			food: models.Food{
+				Name:           value(rec, "Food Name"),
+				Quantity:       value(rec, "Amount"),
+				EstimatedCarbs: carbs,
+				Confidence:     models.HighConfidence,
+			},
+			err: err,
+		})
+	}
+	return diaryMeals(rows, SourceCronometer, opts), nil
+}
@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// diaryRow is one line of a food diary export: a food eaten at a meal, or
+// the meal's totals when the export has no food names.
+type diaryRow struct {
+	row   int
+	date  string
+	clock string
+	group string
+	food  models.Food
+	// err is why the row could not be read. It invalidates the whole meal,
+	// which would otherwise be imported with too few carbs.
+	err error
+}
+
+// slotTimes place diary meals without a time of day at a typical hour.
+var slotTimes = map[string]string{
+	"breakfast": "08:00",
+	"lunch":     "12:30",
+	"snack":     "15:00",
+	"snacks":    "15:00",
+	"dinner":    "18:30",
+}
+
+const defaultSlotTime = "12:00"
+
+var (
+	diaryDateLayouts  = []string{"2006-01-02", "01/02/2006", "1/2/2006"}
+	diaryClockLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04:05 PM", "3:04PM"}
+)
+
+// value returns the first of the named columns present in the record,
+// ignoring case, since trackers change header capitalisation between
+// versions.
+func value(rec record, names ...string) string {
+	for _, name := range names {
+		for column, v := range rec.values {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				return strings.TrimSpace(v)
+			}
+		}
+	}
+	return ""
+}
+
+func hasColumn(rec record, names ...string) bool {
+	for _, name := range names {
+		for column := range rec.values {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requireColumns checks the header of an export, using its first record.
+func requireColumns(records []record, app string, columns ...[]string) error {
+	if len(records) == 0 {
+		return nil
+	}
+	for _, names := range columns {
+		if !hasColumn(records[0], names...) {
+			return fmt.Errorf("not a %s export: missing %q column", app, names[0])
+		}
+	}
+	return nil
+}
+
+// diaryMeals turns diary rows into one meal per day and meal group, in the
+// order the groups first appear.
+func diaryMeals(rows []diaryRow, source string, opts Options) []*candidate {
+	var candidates []*candidate
+	groups := map[string]*candidate{}
+	names := map[*candidate][]string{}
+	for _, r := range rows {
+		// Such a row would make a meal without a description
+		if r.group == "" && r.food.Name == "" {
+			candidates = append(candidates, &candidate{row: r.row, err: fmt.Errorf("row %d: no meal group or food name", r.row)})
+			continue
+		}
+		key := r.date + "\x00" + strings.ToLower(r.group)
+		c, ok := groups[key]
+		if !ok {
+			c = &candidate{row: r.row, meal: &models.Meal{
+				Confidence: models.HighConfidence,
+				Source:     source,
+			}}
+			groups[key] = c
+			candidates = append(candidates, c)
+			c.meal.Timestamp, c.err = diaryTime(r, opts.Location)
+			c.meal.Description = r.group
+		}
+		if c.err == nil && r.err != nil {
+			c.err = fmt.Errorf("row %d: %w", r.row, r.err)
+		}
+		if c.err != nil {
+			continue
+		}
+
+		c.meal.TotalCarbs += r.food.EstimatedCarbs
+		if r.food.Name != "" {
+			c.meal.Foods = append(c.meal.Foods, r.food)
+			names[c] = append(names[c], r.food.Name)
+		}
+	}
+
+	for _, c := range candidates {
+		if c.err != nil || len(names[c]) == 0 {
+			continue
+		}
+		if c.meal.Description == "" {
+			c.meal.Description = strings.Join(names[c], ", ")
+		} else {
+			c.meal.Description += ": " + strings.Join(names[c], ", ")
+		}
+	}
+	return candidates
+}
+
+// diaryTime combines a diary date with the row's time of day, or with the
+// usual time of its meal group when the export has none.
+func diaryTime(r diaryRow, loc *time.Location) (time.Time, error) {
+	if r.date == "" {
+		return time.Time{}, errors.New("missing date")
+	}
+	var date time.Time
+	var err error
+	for _, layout := range diaryDateLayouts {
+		if date, err = time.ParseInLocation(layout, r.date, loc); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognized date %q", r.date)
+	}
+
+	clockValue := r.clock
+	if clockValue == "" {
+		clockValue = defaultSlotTime
+		if slot, ok := slotTimes[strings.ToLower(r.group)]; ok {
+			clockValue = slot
+		}
+	}
+	var clock time.Time
+	for _, layout := range diaryClockLayouts {
+		if clock, err = time.Parse(layout, strings.ToUpper(clockValue)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognized time %q", r.clock)
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc), nil
+}
+
+// diaryNumber parses an optional amount, treating an empty cell as zero.
+func diaryNumber(rec record, names ...string) (float64, error) {
+	v := value(rec, names...)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := parseNumber(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", names[0], err)
+	}
+	return n, nil
+}
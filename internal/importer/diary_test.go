@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// importFixture imports a file from testdata into an empty store.
+func importFixture(t *testing.T, name string, opts Options) (*fakeStore, *Report) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	store := &fakeStore{}
+	report, err := Import(store, f, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, report
+}
+
+// wantMeal is what a test checks of an imported meal.
+type wantMeal struct {
+	description string
+	timestamp   time.Time
+	carbs       float64
+	foods       int
+}
+
+func checkMeals(t *testing.T, store *fakeStore, source string, want []wantMeal) {
+	t.Helper()
+	if len(store.meals) != len(want) {
+		for _, meal := range store.meals {
+			t.Logf("%s %s %g", meal.Timestamp, meal.Description, meal.TotalCarbs)
+		}
+		t.Fatalf("imported %d meals, want %d", len(store.meals), len(want))
+	}
+	for i, w := range want {
+		meal := store.meals[i]
+		if meal.Description != w.description || !meal.Timestamp.Equal(w.timestamp) || meal.TotalCarbs != w.carbs || len(meal.Foods) != w.foods {
+			t.Errorf("meal %d = %s %q, %g g, %d foods; want %s %q, %g g, %d foods", i,
+				meal.Timestamp, meal.Description, meal.TotalCarbs, len(meal.Foods),
+				w.timestamp, w.description, w.carbs, w.foods)
+		}
+		if meal.Source != source || meal.Confidence != models.HighConfidence {
+			t.Errorf("meal %d source, confidence = %s, %s", i, meal.Source, meal.Confidence)
+		}
+	}
+}
+
+func checkSkipped(t *testing.T, report *Report, reasons map[int]string) {
+	t.Helper()
+	skipped := map[int]string{}
+	for _, entry := range report.Entries {
+		if entry.Action == ActionSkip {
+			skipped[entry.Row] = entry.Reason
+		}
+	}
+	if len(skipped) != len(reasons) {
+		t.Errorf("skipped rows = %v, want %v", skipped, reasons)
+	}
+	for row, reason := range reasons {
+		if skipped[row] != reason {
+			t.Errorf("row %d skipped for %q, want %q", row, skipped[row], reason)
+		}
+	}
+}
+
+// Rows are grouped by day and meal, and meals without a time of day are
+// placed at the usual hour of their slot.
+func TestImportMyFitnessPal(t *testing.T) {
+	store, report := importFixture(t, "myfitnesspal.csv", Options{Source: SourceMyFitnessPal})
+	day := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, time.UTC) }
+	checkMeals(t, store, SourceMyFitnessPal, []wantMeal{
+		{"Breakfast: Oatmeal, Banana", day(1, 8, 0), 54, 2},
+		{"Lunch: Sandwich", day(1, 13, 15), 40, 1},
+		{"Snacks", day(1, 15, 0), 12, 0},
+		{"Breakfast: Oatmeal", day(2, 8, 0), 27, 1},
+		{"Supper: Toast", day(2, 12, 0), 15, 1},
+		{"Dinner: Pasta", day(2, 18, 30), 86.5, 1},
+	})
+	checkSkipped(t, report, map[int]string{8: "row 8: no meal group or food name"})
+}
+
+func TestImportCronometer(t *testing.T) {
+	cet := time.FixedZone("CET", 60*60)
+	store, report := importFixture(t, "cronometer.csv", Options{Source: SourceCronometer, Location: cet})
+	day := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, cet) }
+	checkMeals(t, store, SourceCronometer, []wantMeal{
+		{"Breakfast: Greek Yogurt, Blueberries", day(1, 8, 0), 27.5, 2},
+		{"Dinner: Rice, Broccoli", day(1, 19, 45), 50.5, 2},
+		{"Apple", day(2, 12, 0), 25, 1},
+	})
+	// One unreadable food skips its whole meal rather than undercounting it
+	checkSkipped(t, report, map[int]string{6: `row 6: invalid Carbs (g): strconv.ParseFloat: parsing "lots": invalid syntax`})
+}
+
+func TestImportDiaryRequiresColumns(t *testing.T) {
+	_, err := Import(&fakeStore{}, strings.NewReader("Date,Food,Carbs\n2026-03-01,Toast,15\n"), Options{Source: SourceMyFitnessPal})
+	if err == nil || err.Error() != `not a MyFitnessPal export: missing "Meal" column` {
+		t.Errorf("error = %v", err)
+	}
+}
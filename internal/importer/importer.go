@@ -0,0 +1,577 @@
+// Package importer loads meals exported by meal-log or other trackers. Input
+// is read in the export format, with configurable column names, and meals
+// that are already in the log are skipped.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+const DefaultBatchSize = 500
+
+// DefaultLayouts are tried after any caller-supplied layouts. Layouts without
+// a zone are read in Options.Location.
+var DefaultLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006 3:04 PM",
+	"2006-01-02",
+}
+
+// Fields are the column names understood by the importer, matching the
+// export columns. Mapping renames them to the columns of another file.
+var Fields = []string{
+	"meal_id", "description", "timestamp", "slot", "total_carbs", "confidence",
+	"tags", "notes", "location", "restaurant", "exercise_within_2h", "source", "deleted_at",
+	"photo_sha256",
+	"foods", "food_name", "food_quantity", "food_carbs_per_100g", "food_estimated_carbs", "food_confidence",
+}
+
+// Store is the part of storage the importer needs.
+type Store interface {
+	StreamMeals(profileID string, q storage.MealQuery, fn func(*models.Meal) error) error
+	SaveMeals(meals []*models.Meal, change models.ChangeSource) error
+}
+
+// Sources are the trackers with a dedicated importer. The empty source is
+// the meal-log export format.
+const (
+	SourceMyFitnessPal = "myfitnesspal"
+	SourceCronometer   = "cronometer"
+	SourceNightscout   = "nightscout"
+)
+
+var Sources = []string{SourceMyFitnessPal, SourceCronometer, SourceNightscout}
+
+type Options struct {
+	ProfileID string
+	// Source selects a tracker-specific importer. Format, Mapping and
+	// TimestampLayouts only apply to the export format.
+	Source string
+	Format export.Format
+	// Mapping maps an importer field to the column holding it, e.g.
+	// "description" -> "Meal". Unmapped fields use their own name.
+	Mapping map[string]string
+	// TimestampLayouts are tried, in order, before DefaultLayouts.
+	TimestampLayouts []string
+	// Location is used for timestamps without a zone. Defaults to UTC.
+	Location *time.Location
+	// DryRun reports what would happen without saving anything.
+	DryRun    bool
+	BatchSize int
+	// Change is recorded in the audit trail of every imported meal.
+	Change models.ChangeSource
+}
+
+const (
+	ActionInsert = "insert"
+	ActionSkip   = "skip"
+)
+
+// Entry describes what happened, or would happen, to one meal of the input.
+// Row is the line of the meal's first row in CSV input, or the position of
+// its first object in JSON input.
+type Entry struct {
+	Row         int        `json:"row"`
+	Action      string     `json:"action"`
+	Reason      string     `json:"reason,omitempty"`
+	MealID      string     `json:"meal_id,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Timestamp   *time.Time `json:"timestamp,omitempty"`
+}
+
+type Report struct {
+	DryRun   bool    `json:"dry_run"`
+	Read     int     `json:"read"`
+	Inserted int     `json:"inserted"`
+	Skipped  int     `json:"skipped"`
+	Entries  []Entry `json:"entries"`
+}
+
+// Import reads meals from r and saves the new ones in batches. When a batch
+// fails the error is returned along with a report of the batches already
+// saved.
+func Import(store Store, r io.Reader, opts Options) (*Report, error) {
+	if opts.ProfileID == "" {
+		opts.ProfileID = models.DefaultProfileID
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	for field := range opts.Mapping {
+		if !knownField(field) {
+			return nil, fmt.Errorf("unknown field %q in column mapping", field)
+		}
+	}
+
+	candidates, err := readCandidates(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	existing, err := existingKeys(store, opts, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var pending []*models.Meal
+	for i, c := range candidates {
+		report.Read++
+		entry := Entry{Row: c.row}
+		if c.meal != nil {
+			entry.Description = c.meal.Description
+			if !c.meal.Timestamp.IsZero() {
+				timestamp := c.meal.Timestamp
+				entry.Timestamp = &timestamp
+			}
+		}
+
+		switch {
+		case c.err != nil:
+			entry.Action, entry.Reason = ActionSkip, c.err.Error()
+		case existing[mealKey(c.meal)]:
+			entry.Action, entry.Reason = ActionSkip, "duplicate of an existing meal"
+		default:
+			// Later rows with the same key are duplicates within the file
+			existing[mealKey(c.meal)] = true
+			c.meal.ID = fmt.Sprintf("meal_%d", now.UnixNano()+int64(i))
+			c.meal.ProfileID = opts.ProfileID
+			c.meal.CreatedAt = now
+			c.meal.UpdatedAt = now
+			entry.Action, entry.MealID = ActionInsert, c.meal.ID
+			pending = append(pending, c.meal)
+		}
+
+		if entry.Action == ActionSkip {
+			report.Skipped++
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	if opts.DryRun {
+		report.Inserted = len(pending)
+		return report, nil
+	}
+
+	for start := 0; start < len(pending); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		if err := store.SaveMeals(pending[start:end], opts.Change); err != nil {
+			return report, fmt.Errorf("failed to save meals %d-%d: %w", start+1, end, err)
+		}
+		report.Inserted = end
+	}
+	return report, nil
+}
+
+func knownField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func readCandidates(r io.Reader, opts Options) ([]*candidate, error) {
+	switch opts.Source {
+	case "":
+		records, err := readRecords(r, opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		return buildMeals(records, opts), nil
+	case SourceMyFitnessPal:
+		return readMyFitnessPal(r, opts)
+	case SourceCronometer:
+		return readCronometer(r, opts)
+	case SourceNightscout:
+		return readNightscout(r, opts)
+	}
+	return nil, fmt.Errorf("unknown import source %q (want %s)", opts.Source, strings.Join(Sources, ", "))
+}
+
+// record is one input row with its values keyed by column name.
+type record struct {
+	row    int
+	values map[string]string
+}
+
+// DetectFormat guesses the format from a file name, defaulting to CSV.
+func DetectFormat(name string) export.Format {
+	switch {
+	case strings.HasSuffix(name, ".ndjson"), strings.HasSuffix(name, ".jsonl"):
+		return export.FormatNDJSON
+	case strings.HasSuffix(name, ".json"):
+		return export.FormatJSON
+	}
+	return export.FormatCSV
+}
+
+func readRecords(r io.Reader, format export.Format) ([]record, error) {
+	switch format {
+	case "", export.FormatCSV:
+		return readCSV(r)
+	case export.FormatJSON, export.FormatNDJSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q (want csv, json or ndjson)", format)
+}
+
+func readCSV(r io.Reader) ([]record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark from spreadsheet apps
+	}
+
+	var records []record
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		rec := record{row: line, values: map[string]string{}}
+		for i, name := range header {
+			if i < len(fields) {
+				rec.values[strings.TrimSpace(name)] = fields[i]
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+// readJSON accepts both a JSON array and NDJSON, since a decoder reads
+// either as a sequence of values. Non-string values are converted to the
+// text the CSV reader would have produced.
+func readJSON(r io.Reader) ([]record, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var records []record
+	add := func(obj map[string]interface{}) error {
+		rec := record{row: len(records) + 1, values: map[string]string{}}
+		for name, value := range obj {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				rec.values[name] = v
+			case json.Number:
+				rec.values[name] = v.String()
+			case bool:
+				rec.values[name] = strconv.FormatBool(v)
+			default:
+				data, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				rec.values[name] = string(data)
+			}
+		}
+		records = append(records, rec)
+		return nil
+	}
+
+	for {
+		var value interface{}
+		err := dec.Decode(&value)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JSON: %w", err)
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := add(v); err != nil {
+				return nil, err
+			}
+		case []interface{}:
+			for _, item := range v {
+				obj, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("JSON arrays must contain objects")
+				}
+				if err := add(obj); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, errors.New("JSON input must be objects or an array of objects")
+		}
+	}
+}
+
+// candidate is a meal built from one or more rows, or the reason it could
+// not be built.
+type candidate struct {
+	row  int
+	meal *models.Meal
+	err  error
+}
+
+// buildMeals groups rows into meals. Rows sharing a meal_id, or without one
+// but with the same description and timestamp, are the foods of one meal.
+func buildMeals(records []record, opts Options) []*candidate {
+	column := func(field string) string {
+		if name, ok := opts.Mapping[field]; ok {
+			return name
+		}
+		return field
+	}
+	get := func(rec record, field string) string {
+		return strings.TrimSpace(rec.values[column(field)])
+	}
+
+	var candidates []*candidate
+	groups := map[string]*candidate{}
+	carbsGiven := map[*candidate]bool{}
+	for _, rec := range records {
+		key := get(rec, "meal_id")
+		if key == "" {
+			key = get(rec, "description") + "\x00" + get(rec, "timestamp")
+		}
+
+		c, ok := groups[key]
+		if !ok {
+			c = &candidate{row: rec.row}
+			groups[key] = c
+			candidates = append(candidates, c)
+			c.meal, carbsGiven[c], c.err = parseMeal(rec, get, opts)
+		}
+		if c.err != nil {
+			continue
+		}
+		if err := addFoods(c.meal, rec, get); err != nil {
+			c.err = fmt.Errorf("row %d: %w", rec.row, err)
+		}
+	}
+
+	// Meals without a total get the sum of their foods
+	for _, c := range candidates {
+		if c.err == nil && !carbsGiven[c] {
+			for _, food := range c.meal.Foods {
+				c.meal.TotalCarbs += food.EstimatedCarbs
+			}
+		}
+	}
+	return candidates
+}
+
+func parseMeal(rec record, get func(record, string) string, opts Options) (*models.Meal, bool, error) {
+	meal := &models.Meal{
+		Description: get(rec, "description"),
+		Confidence:  models.ConfidenceLevel(strings.ToLower(get(rec, "confidence"))),
+		Source:      get(rec, "source"),
+	}
+	if meal.Description == "" {
+		return meal, false, errors.New("missing description")
+	}
+	if get(rec, "deleted_at") != "" {
+		return meal, false, errors.New("meal is deleted in the source")
+	}
+
+	var err error
+	if meal.Timestamp, err = parseTime(get(rec, "timestamp"), opts); err != nil {
+		return meal, false, err
+	}
+
+	// A meal without a slot gets one from the profile's schedule on save
+	if value := get(rec, "slot"); value != "" {
+		if meal.Slot, err = models.NormalizeSlot(value); err != nil {
+			return meal, false, err
+		}
+	}
+
+	carbsGiven := false
+	if value := get(rec, "total_carbs"); value != "" {
+		if meal.TotalCarbs, err = parseNumber(value); err != nil {
+			return meal, false, fmt.Errorf("invalid total_carbs: %w", err)
+		}
+		carbsGiven = true
+	}
+
+	if value := get(rec, "tags"); value != "" {
+		if meal.Tags, err = parseTags(value); err != nil {
+			return meal, false, err
+		}
+	}
+	meal.Notes = get(rec, "notes")
+	meal.Location = get(rec, "location")
+	meal.Restaurant = get(rec, "restaurant")
+	meal.PhotoSHA256 = get(rec, "photo_sha256")
+	if value := get(rec, "exercise_within_2h"); value != "" {
+		exercise, err := strconv.ParseBool(value)
+		if err != nil {
+			return meal, false, fmt.Errorf("invalid exercise_within_2h %q", value)
+		}
+		meal.Exercise = &exercise
+	}
+
+	if meal.Confidence == "" {
+		meal.Confidence = models.MediumConfidence
+	}
+	if !meal.Confidence.Valid() {
+		return meal, false, fmt.Errorf("invalid confidence %q (want high, medium or low)", get(rec, "confidence"))
+	}
+	if meal.Source == "" {
+		meal.Source = "import"
+	}
+	return meal, carbsGiven, nil
+}
+
+// addFoods adds the foods in a per-meal "foods" JSON column or the single
+// food of a per-food row.
+func addFoods(meal *models.Meal, rec record, get func(record, string) string) error {
+	if value := get(rec, "foods"); value != "" {
+		var foods []models.Food
+		if err := json.Unmarshal([]byte(value), &foods); err != nil {
+			return fmt.Errorf("invalid foods: %w", err)
+		}
+		meal.Foods = append(meal.Foods, foods...)
+		return nil
+	}
+
+	name := get(rec, "food_name")
+	if name == "" {
+		return nil
+	}
+	food := models.Food{
+		Name:       name,
+		Quantity:   get(rec, "food_quantity"),
+		Confidence: models.ConfidenceLevel(strings.ToLower(get(rec, "food_confidence"))),
+	}
+	if food.Confidence != "" && !food.Confidence.Valid() {
+		return fmt.Errorf("invalid food_confidence %q (want high, medium or low)", get(rec, "food_confidence"))
+	}
+	var err error
+	if value := get(rec, "food_carbs_per_100g"); value != "" {
+		if food.CarbsPer100g, err = parseNumber(value); err != nil {
+			return fmt.Errorf("invalid food_carbs_per_100g: %w", err)
+		}
+	}
+	if value := get(rec, "food_estimated_carbs"); value != "" {
+		if food.EstimatedCarbs, err = parseNumber(value); err != nil {
+			return fmt.Errorf("invalid food_estimated_carbs: %w", err)
+		}
+	}
+	meal.Foods = append(meal.Foods, food)
+	return nil
+}
+
+// parseTags reads a JSON array of tags, as exported, or a comma-separated
+// list.
+func parseTags(value string) ([]string, error) {
+	var tags []string
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			return nil, fmt.Errorf("invalid tags: %w", err)
+		}
+	} else {
+		for _, tag := range strings.Split(value, ",") {
+			if strings.TrimSpace(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return models.NormalizeTags(tags)
+}
+
+func parseTime(value string, opts Options) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+	for _, layout := range append(append([]string{}, opts.TimestampLayouts...), DefaultLayouts...) {
+		if t, err := time.ParseInLocation(layout, value, opts.Location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+func parseNumber(value string) (float64, error) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid amount", value)
+	}
+	return n, nil
+}
+
+// mealKey identifies a meal for deduplication: the description, ignoring
+// case and spacing, and the timestamp to the second.
+func mealKey(meal *models.Meal) string {
+	description := strings.ToLower(strings.Join(strings.Fields(meal.Description), " "))
+	return description + "\x00" + strconv.FormatInt(meal.Timestamp.Unix(), 10)
+}
+
+// existingKeys returns the keys of the profile's meals, trashed ones
+// included, over the dates covered by the input. Descriptions may be
+// encrypted, so the comparison happens here rather than in SQL.
+func existingKeys(store Store, opts Options, candidates []*candidate) (map[string]bool, error) {
+	keys := map[string]bool{}
+	var first, last string
+	for _, c := range candidates {
+		if c.err != nil {
+			continue
+		}
+		date := c.meal.Timestamp.In(opts.Location).Format("2006-01-02")
+		if first == "" || date < first {
+			first = date
+		}
+		if date > last {
+			last = date
+		}
+	}
+	if first == "" {
+		return keys, nil
+	}
+
+	query := storage.MealQuery{StartDate: first, EndDate: last, IncludeDeleted: true}
+	err := store.StreamMeals(opts.ProfileID, query, func(meal *models.Meal) error {
+		keys[mealKey(meal)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing meals: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+// fakeStore keeps meals in memory. SaveMeals fails on call number failOn,
+// counting from one, when it is set.
+type fakeStore struct {
+	meals  []*models.Meal
+	saves  int
+	failOn int
+}
+
+func (s *fakeStore) StreamMeals(profileID string, q storage.MealQuery, fn func(*models.Meal) error) error {
+	for _, meal := range s.meals {
+		date := meal.Timestamp.UTC().Format("2006-01-02")
+		if meal.ProfileID != profileID || date < q.StartDate || date > q.EndDate {
+			continue
+		}
+		if err := fn(meal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) SaveMeals(meals []*models.Meal, change models.ChangeSource) error {
+	s.saves++
+	if s.saves == s.failOn {
+		return errors.New("disk full")
+	}
+	s.meals = append(s.meals, meals...)
+	return nil
+}
+
+func importString(t *testing.T, store Store, input string, opts Options) *Report {
+	t.Helper()
+	report, err := Import(store, strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	exercise := true
+	meals := []*models.Meal{
+		{
+			ID:          "meal_1",
+			ProfileID:   "alice",
+			Description: "Porridge with banana",
+			Timestamp:   time.Date(2026, 4, 2, 7, 30, 0, 0, time.UTC),
+			Slot:        "pre-workout",
+			Foods: []models.Food{
+				{Name: "oats", Quantity: "50 g", CarbsPer100g: 60, EstimatedCarbs: 30, Confidence: models.HighConfidence},
+				{Name: "banana", Quantity: "1 small", CarbsPer100g: 23, EstimatedCarbs: 20, Confidence: models.MediumConfidence},
+			},
+			TotalCarbs:  50,
+			Confidence:  models.MediumConfidence,
+			Tags:        []string{"home", "sick-day"},
+			Notes:       "slow morning",
+			Location:    "kitchen",
+			Exercise:    &exercise,
+			Source:      "manual",
+			PhotoSHA256: "ab12",
+		},
+		{
+			ID:          "meal_2",
+			ProfileID:   "alice",
+			Description: "Pizza, shared",
+			Timestamp:   time.Date(2026, 4, 2, 19, 0, 0, 0, time.UTC),
+			Foods:       []models.Food{{Name: "pizza", Quantity: "3 slices", EstimatedCarbs: 90, Confidence: models.LowConfidence}},
+			TotalCarbs:  90,
+			Confidence:  models.LowConfidence,
+			Restaurant:  "Luigi's",
+			Source:      "ai_parsed",
+		},
+	}
+
+	for _, tt := range []struct {
+		format export.Format
+		rows   export.Rows
+	}{
+		{export.FormatCSV, export.RowsFood},
+		{export.FormatCSV, export.RowsMeal},
+		{export.FormatJSON, export.RowsMeal},
+		{export.FormatNDJSON, export.RowsFood},
+	} {
+		t.Run(string(tt.format)+"/"+string(tt.rows), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := export.NewWriter(&buf, export.Options{Format: tt.format, Rows: tt.rows})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, meal := range meals {
+				if err := w.WriteMeal(meal); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store := &fakeStore{}
+			opts := Options{ProfileID: "bob", Format: tt.format}
+			report := importString(t, store, buf.String(), opts)
+			if report.Read != 2 || report.Inserted != 2 || report.Skipped != 0 {
+				t.Fatalf("report = %+v", report)
+			}
+			for i, got := range store.meals {
+				want := *meals[i]
+				want.ID, want.ProfileID = got.ID, "bob"
+				want.CreatedAt, want.UpdatedAt = got.CreatedAt, got.UpdatedAt
+				if !reflect.DeepEqual(*got, want) {
+					t.Errorf("meal %d =\n%+v\nwant\n%+v", i, *got, want)
+				}
+			}
+
+			// Importing the same file again finds only duplicates
+			report = importString(t, store, buf.String(), opts)
+			if report.Inserted != 0 || report.Skipped != 2 || report.Entries[0].Reason != "duplicate of an existing meal" {
+				t.Errorf("second import = %+v", report)
+			}
+		})
+	}
+}
+
+func TestImportMappingAndLayouts(t *testing.T) {
+	input := "Meal,When,Carbs\n" +
+		"Toast,02.04.2026 08:15,30\n" +
+		"Soup,2026-04-02T12:00:00Z,25\n"
+	cest := time.FixedZone("CEST", 2*60*60)
+
+	store := &fakeStore{}
+	importString(t, store, input, Options{
+		Mapping:          map[string]string{"description": "Meal", "timestamp": "When", "total_carbs": "Carbs"},
+		TimestampLayouts: []string{"02.01.2006 15:04"},
+		Location:         cest,
+	})
+	if len(store.meals) != 2 {
+		t.Fatalf("imported %d meals, want 2", len(store.meals))
+	}
+
+	toast, soup := store.meals[0], store.meals[1]
+	if !toast.Timestamp.Equal(time.Date(2026, 4, 2, 6, 15, 0, 0, time.UTC)) {
+		t.Errorf("toast timestamp = %s, want it read in the given location", toast.Timestamp)
+	}
+	if !soup.Timestamp.Equal(time.Date(2026, 4, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("soup timestamp = %s, want its own zone kept", soup.Timestamp)
+	}
+	if toast.ProfileID != models.DefaultProfileID || toast.TotalCarbs != 30 || toast.Confidence != models.MediumConfidence || toast.Source != "import" {
+		t.Errorf("toast = %+v", toast)
+	}
+
+	_, err := Import(store, strings.NewReader(input), Options{Mapping: map[string]string{"carbs": "Carbs"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "carbs"`) {
+		t.Errorf("unknown mapping field error = %v", err)
+	}
+}
+
+func TestImportSkipsAndDryRun(t *testing.T) {
+	existing := &models.Meal{
+		ID:          "meal_old",
+		ProfileID:   models.DefaultProfileID,
+		Description: "Apple",
+		Timestamp:   time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC),
+	}
+	input := `description,timestamp,total_carbs,confidence,deleted_at,food_name,food_estimated_carbs
+apple,2026-04-01T10:00:00Z,15,,,,
+Rice bowl,2026-04-01T12:00:00Z,,high,,rice,40
+Rice bowl,2026-04-01T12:00:00Z,,high,,egg,1
+rice  BOWL,2026-04-01T12:00:00Z,41,,,,
+Cake,2026-04-01T15:00:00Z,50,certain,,,
+Crisps,2026-04-01T16:00:00Z,15,,2026-04-02T00:00:00Z,,
+,2026-04-01T17:00:00Z,10,,,,
+Pear,yesterday,12,,,,
+Dates,2026-04-01T18:00:00Z,-3,,,,
+`
+	store := &fakeStore{meals: []*models.Meal{existing}}
+	report := importString(t, store, input, Options{DryRun: true})
+
+	want := []struct {
+		action, reason string
+	}{
+		{ActionSkip, "duplicate of an existing meal"},
+		{ActionInsert, ""},
+		{ActionSkip, "duplicate of an existing meal"},
+		{ActionSkip, `invalid confidence "certain" (want high, medium or low)`},
+		{ActionSkip, "meal is deleted in the source"},
+		{ActionSkip, "missing description"},
+		{ActionSkip, `unrecognized timestamp "yesterday"`},
+		{ActionSkip, "invalid total_carbs"},
+	}
+	if len(report.Entries) != len(want) {
+		t.Fatalf("entries = %+v", report.Entries)
+	}
+	for i, w := range want {
+		entry := report.Entries[i]
+		if entry.Action != w.action || !strings.HasPrefix(entry.Reason, w.reason) {
+			t.Errorf("entry %d = %s %q, want %s %q", i, entry.Action, entry.Reason, w.action, w.reason)
+		}
+	}
+	if report.Entries[1].Row != 3 || report.Entries[2].Row != 5 {
+		t.Errorf("rows = %d, %d, want the first line of each meal", report.Entries[1].Row, report.Entries[2].Row)
+	}
+	if !report.DryRun || report.Read != 8 || report.Inserted != 1 || report.Skipped != 7 {
+		t.Errorf("report = %+v", report)
+	}
+	if store.saves != 0 || len(store.meals) != 1 {
+		t.Errorf("dry run saved %d batches", store.saves)
+	}
+
+	report = importString(t, store, input, Options{})
+	if report.Inserted != 1 || len(store.meals) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	rice := store.meals[1]
+	if rice.TotalCarbs != 41 || len(rice.Foods) != 2 || rice.Confidence != models.HighConfidence {
+		t.Errorf("rice bowl = %+v, want its foods summed", rice)
+	}
+}
+
+func TestImportBatchFailure(t *testing.T) {
+	input := "description,timestamp,total_carbs\n"
+	for i := 0; i < 5; i++ {
+		input += fmt.Sprintf("Snack %d,2026-04-01T1%d:00:00Z,10\n", i, i)
+	}
+
+	store := &fakeStore{failOn: 2}
+	report, err := Import(store, strings.NewReader(input), Options{BatchSize: 2})
+	if err == nil || !strings.Contains(err.Error(), "failed to save meals 3-4: disk full") {
+		t.Fatalf("error = %v", err)
+	}
+	if report == nil || report.Inserted != 2 || len(store.meals) != 2 {
+		t.Errorf("report = %+v, saved %d meals, want the first batch", report, len(store.meals))
+	}
+}
@@ -0,0 +1,41 @@
+package importer
+
+import (
+	"io"
+
+	"mcp-meal-log/internal/models"
+)
+
+// readMyFitnessPal reads the Nutrition Summary or food diary CSV exported
+// by MyFitnessPal. The summary has one row per meal with only totals; diary
+// exports add a row per food. Either way each day's Breakfast, Lunch, etc.
+// becomes one meal.
+func readMyFitnessPal(r io.Reader, opts Options) ([]*candidate, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	carbColumns := []string{"Carbohydrates (g)", "Carbohydrates", "Carbs (g)", "Carbs"}
+	if err := requireColumns(records, "MyFitnessPal", []string{"Date"}, []string{"Meal"}, carbColumns); err != nil {
+		return nil, err
+	}
+
+	var rows []diaryRow
+	for _, rec := range records {
+		carbs, err := diaryNumber(rec, carbColumns...)
+		rows = append(rows, diaryRow{
+			row:   rec.row,
+			date:  value(rec, "Date"),
+			clock: value(rec, "Time"),
+			group: value(rec, "Meal"),
+			food: models.Food{
+				Name:           value(rec, "Food", "Food Name"),
+				Quantity:       value(rec, "Serving", "Quantity"),
+				EstimatedCarbs: carbs,
+				Confidence:     models.HighConfidence,
+			},
+			err: err,
+		})
+	}
+	return diaryMeals(rows, SourceMyFitnessPal, opts), nil
+}
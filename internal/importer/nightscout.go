@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// readNightscout reads a Nightscout treatments download, as written by
+// Loop, AAPS and other uploaders. Treatments without carbs, such as basal
+// changes and correction boluses, are not meals and are left out.
+func readNightscout(r io.Reader, opts Options) ([]*candidate, error) {
+	records, err := readJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*candidate
+	for _, rec := range records {
+		carbsValue := value(rec, "carbs")
+		if carbsValue == "" {
+			continue
+		}
+		carbs, err := parseNumber(carbsValue)
+		if err != nil {
+			candidates = append(candidates, &candidate{row: rec.row, err: fmt.Errorf("invalid carbs: %w", err)})
+			continue
+		}
+		if carbs == 0 {
+			continue
+		}
+
+		c := &candidate{row: rec.row}
+		candidates = append(candidates, c)
+		c.meal, c.err = nightscoutMeal(rec, carbs)
+	}
+	return candidates, nil
+}
+
+func nightscoutMeal(rec record, carbs float64) (*models.Meal, error) {
+	timestamp, err := nightscoutTime(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loop and AAPS use the food type as a short label, often an emoji
+	name := value(rec, "foodType")
+	description := value(rec, "notes")
+	switch {
+	case description == "" && name != "":
+		description = name
+	case description == "":
+		description = value(rec, "eventType")
+	}
+	if description == "" {
+		description = "Carbs"
+	}
+	if name == "" {
+		name = description
+	}
+
+	// The meal model has no absorption field, so keep it in the description
+	if absorption := value(rec, "absorptionTime"); absorption != "" {
+		minutes, err := parseNumber(absorption)
+		if err != nil {
+			return nil, fmt.Errorf("invalid absorptionTime: %w", err)
+		}
+		description = fmt.Sprintf("%s (absorption %s min)", description, strconv.FormatFloat(minutes, 'f', -1, 64))
+	}
+
+	return &models.Meal{
+		Description: description,
+		Timestamp:   timestamp,
+		TotalCarbs:  carbs,
+		Confidence:  models.HighConfidence,
+		Source:      SourceNightscout,
+		Foods: []models.Food{{
+			Name:           name,
+			EstimatedCarbs: carbs,
+			Confidence:     models.HighConfidence,
+		}},
+	}, nil
+}
+
+// nightscoutTime reads created_at, falling back to the other timestamp
+// fields some uploaders write, which may hold epoch milliseconds.
+func nightscoutTime(rec record) (time.Time, error) {
+	for _, field := range []string{"created_at", "timestamp", "date", "mills"} {
+		v := value(rec, field)
+		if v == "" {
+			continue
+		}
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(ms), nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unrecognized %s %q", field, v)
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("missing created_at")
+}
@@ -0,0 +1,24 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+// Treatments without carbs are left out, and created_at may be RFC 3339 or
+// epoch milliseconds.
+func TestImportNightscout(t *testing.T) {
+	store, report := importFixture(t, "nightscout.json", Options{Source: SourceNightscout})
+	checkMeals(t, store, SourceNightscout, []wantMeal{
+		{"Porridge", time.Date(2026, 3, 1, 7, 30, 0, 0, time.UTC), 45, 1},
+		{"🍎 (absorption 120 min)", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), 15, 1},
+		{"Meal Bolus", time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), 60, 1},
+	})
+	if name := store.meals[1].Foods[0].Name; name != "🍎" {
+		t.Errorf("food name = %q, want the food type", name)
+	}
+	checkSkipped(t, report, map[int]string{7: `unrecognized created_at "yesterday"`})
+	if report.Read != 4 {
+		t.Errorf("read %d treatments, want the 4 with carbs", report.Read)
+	}
+}
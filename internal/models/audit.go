@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+)
+
+// ChangeSource describes who made a change to a meal and how.
+type ChangeSource struct {
+	// Tool is the MCP tool or CLI command that made the change.
+	Tool string `json:"tool"`
+	// Origin is "ai" when the values came from the model, "manual" when
+	// the user supplied them, or the name of an import source.
+	Origin string `json:"origin"`
+	// Actor identifies the authenticated caller.
+	Actor string `json:"actor"`
+}
+
+// MealRevision is one entry of a meal's append-only audit trail. Before is
+// nil for creations and After is nil when a trashed meal is purged.
+type MealRevision struct {
+	ID        int64        `json:"revision_id"`
+	MealID    string       `json:"meal_id"`
+	ProfileID string       `json:"profile_id"`
+	Action    AuditAction  `json:"action"`
+	Before    *Meal        `json:"before,omitempty"`
+	After     *Meal        `json:"after,omitempty"`
+	Source    ChangeSource `json:"source"`
+	CreatedAt time.Time    `json:"created_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Nutrients are the macronutrients of a nutrition label, in grams. Values
+// missing from the label are zero.
+type Nutrients struct {
+	// Carbs is the carbohydrate as labelled: total carbohydrate including
+	// fiber on US labels, available carbohydrate without fiber on EU ones.
+	Carbs   float64 `json:"carbs"`
+	Fiber   float64 `json:"fiber"`
+	Sugars  float64 `json:"sugars"`
+	Protein float64 `json:"protein"`
+	Fat     float64 `json:"fat"`
+}
+
+// NutritionLabel holds the values read from a nutrition facts panel. A label
+// gives its values per serving, per 100 g (or 100 ml) or both; either is nil
+// when the label has no such column and it cannot be worked out.
+type NutritionLabel struct {
+	// ServingSize is the serving as printed, e.g. "2/3 cup (55g)".
+	ServingSize string `json:"serving_size,omitempty"`
+	// ServingGrams and ServingsPerContainer are zero when unknown.
+	ServingGrams         float64    `json:"serving_grams,omitempty"`
+	ServingsPerContainer float64    `json:"servings_per_container,omitempty"`
+	PerServing           *Nutrients `json:"per_serving,omitempty"`
+	Per100g              *Nutrients `json:"per_100g,omitempty"`
+}
+
+// Carbs is the carbohydrate in an amount given in grams or, when grams is
+// zero, in servings. ok is false when the label does not have the values
+// to work it out, such as a per-100 g label without a serving size.
+func (l *NutritionLabel) Carbs(grams, servings float64) (carbs float64, ok bool) {
+	if grams > 0 {
+		switch {
+		case l.Per100g != nil:
+			return l.Per100g.Carbs * grams / 100, true
+		case l.PerServing != nil && l.ServingGrams > 0:
+			return l.PerServing.Carbs * grams / l.ServingGrams, true
+		}
+		return 0, false
+	}
+	switch {
+	case l.PerServing != nil:
+		return l.PerServing.Carbs * servings, true
+	case l.Per100g != nil && l.ServingGrams > 0:
+		return l.Per100g.Carbs * l.ServingGrams * servings / 100, true
+	}
+	return 0, false
+}
+
+// CustomFood is a food a profile saved from its nutrition label, so it can
+// be logged by name.
+type CustomFood struct {
+	ID        string `json:"id"`
+	ProfileID string `json:"profile_id"`
+	Name      string `json:"name"`
+	NutritionLabel
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
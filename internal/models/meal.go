@@ -2,46 +2,103 @@
 package models
 
 import (
-    "time"
+	"time"
 )
 
 type Meal struct {
-    ID          string             `json:"id"`
-    Description string             `json:"description"`
-    Timestamp   time.Time          `json:"timestamp"`
-    Foods       []Food             `json:"foods"`
-    TotalCarbs  float64            `json:"total_carbs"`
-    Confidence  ConfidenceLevel    `json:"confidence"`
-    CreatedAt   time.Time          `json:"created_at"`
-    UpdatedAt   time.Time          `json:"updated_at"`
-    Source      string             `json:"source"` // "manual", "ai_parsed"
+	ID          string    `json:"id"`
+	ProfileID   string    `json:"profile_id"`
+	Description string    `json:"description"`
+	Timestamp   time.Time `json:"timestamp"`
+	// Slot is the meal slot, such as breakfast or a profile's own. When it
+	// is empty on save it is inferred from the timestamp by the profile's
+	// meal schedule.
+	Slot       string          `json:"slot,omitempty"`
+	Foods      []Food          `json:"foods"`
+	TotalCarbs float64         `json:"total_carbs"`
+	Confidence ConfidenceLevel `json:"confidence"`
+	// Tags label the meal, e.g. "ate out" or "sick day"; see NormalizeTag.
+	Tags []string `json:"tags,omitempty"`
+	// Notes, Location and Restaurant are optional free text, encrypted
+	// like the description.
+	Notes      string `json:"notes,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Restaurant string `json:"restaurant,omitempty"`
+	// Exercise records whether there was exercise within two hours of the
+	// meal, before or after; nil when unknown.
+	Exercise  *bool      `json:"exercise_within_2h,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Source    string     `json:"source"`               // "manual", "ai_parsed"
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set while the meal is in the trash
+	// PhotoSHA256 identifies the photo the meal was logged from, if any.
+	PhotoSHA256 string `json:"photo_sha256,omitempty"`
+	// Photo is stored with a new meal. It is not loaded with the meal;
+	// see GetMealPhoto.
+	Photo *MealPhoto `json:"-"`
+}
+
+// MealPhoto is the thumbnail kept of the photo a meal was logged from.
+type MealPhoto struct {
+	SHA256    string
+	MimeType  string
+	Thumbnail []byte
+}
+
+// MealImage is a photo of a meal to analyse.
+type MealImage struct {
+	Data     []byte
+	MimeType string
 }
 
 type Food struct {
-    Name           string          `json:"name"`
-    Quantity       string          `json:"quantity"`
-    CarbsPer100g   float64         `json:"carbs_per_100g"`
-    EstimatedCarbs float64         `json:"estimated_carbs"`
-    Confidence     ConfidenceLevel `json:"confidence"`
+	Name           string          `json:"name"`
+	Quantity       string          `json:"quantity"`
+	CarbsPer100g   float64         `json:"carbs_per_100g"`
+	EstimatedCarbs float64         `json:"estimated_carbs"`
+	Confidence     ConfidenceLevel `json:"confidence"`
 }
 
 type ConfidenceLevel string
 
 const (
-    HighConfidence   ConfidenceLevel = "high"
-    MediumConfidence ConfidenceLevel = "medium"
-    LowConfidence    ConfidenceLevel = "low"
+	HighConfidence   ConfidenceLevel = "high"
+	MediumConfidence ConfidenceLevel = "medium"
+	LowConfidence    ConfidenceLevel = "low"
 )
 
+var confidenceRank = map[ConfidenceLevel]int{LowConfidence: 1, MediumConfidence: 2, HighConfidence: 3}
+
+// Valid reports whether c is one of the confidence levels.
+func (c ConfidenceLevel) Valid() bool {
+	_, ok := confidenceRank[c]
+	return ok
+}
+
+// LowestConfidence is the confidence of a meal made of foods: the lowest
+// level among them. Foods without a known level are ignored; it returns ""
+// when none has one.
+func LowestConfidence(foods []Food) ConfidenceLevel {
+	var lowest ConfidenceLevel
+	for _, food := range foods {
+		rank, ok := confidenceRank[food.Confidence]
+		if ok && (lowest == "" || rank < confidenceRank[lowest]) {
+			lowest = food.Confidence
+		}
+	}
+	return lowest
+}
+
 type CarbCalculationRequest struct {
-    MealDescription   string `json:"meal_description"`
-    AskClarifications bool   `json:"ask_clarifications"`
+	MealDescription   string     `json:"meal_description"`
+	AskClarifications bool       `json:"ask_clarifications"`
+	Image             *MealImage `json:"-"`
 }
 
 type CarbCalculationResponse struct {
-    Foods          []Food          `json:"foods"`
-    TotalCarbs     float64         `json:"total_carbs"`
-    Confidence     ConfidenceLevel `json:"confidence"`
-    Clarifications []string        `json:"clarifications,omitempty"`
-    NeedsMoreInfo  bool            `json:"needs_more_info"`
+	Foods          []Food          `json:"foods"`
+	TotalCarbs     float64         `json:"total_carbs"`
+	Confidence     ConfidenceLevel `json:"confidence"`
+	Clarifications []string        `json:"clarifications,omitempty"`
+	NeedsMoreInfo  bool            `json:"needs_more_info"`
 }
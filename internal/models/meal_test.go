@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestLowestConfidence(t *testing.T) {
+	tests := []struct {
+		name  string
+		foods []Food
+		want  ConfidenceLevel
+	}{
+		{"none", nil, ""},
+		{"unknown levels", []Food{{Confidence: ""}, {Confidence: "sure"}}, ""},
+		{"single", []Food{{Confidence: HighConfidence}}, HighConfidence},
+		{"lowest wins", []Food{{Confidence: HighConfidence}, {Confidence: LowConfidence}, {Confidence: MediumConfidence}}, LowConfidence},
+		{"unknown ignored", []Food{{Confidence: ""}, {Confidence: MediumConfidence}, {Confidence: HighConfidence}}, MediumConfidence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LowestConfidence(tt.foods); got != tt.want {
+				t.Errorf("LowestConfidence = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Product sources.
+const (
+	ProductSourceOpenFoodFacts = "openfoodfacts"
+	ProductSourceManual        = "manual"
+)
+
+// Product is a packaged food identified by its barcode, with the values
+// from its nutrition label.
+type Product struct {
+	// Barcode is the EAN-13 form of the code; see NormalizeBarcode.
+	Barcode      string  `json:"barcode"`
+	Name         string  `json:"name"`
+	Brand        string  `json:"brand,omitempty"`
+	CarbsPer100g float64 `json:"carbs_per_100g"`
+	// ServingSize is the serving as printed on the label, e.g. "2 biscuits (25 g)".
+	ServingSize string `json:"serving_size,omitempty"`
+	// ServingGrams and PackageGrams are zero when unknown.
+	ServingGrams float64   `json:"serving_grams,omitempty"`
+	PackageGrams float64   `json:"package_grams,omitempty"`
+	Source       string    `json:"source"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// DisplayName is the product name with its brand.
+func (p *Product) DisplayName() string {
+	if p.Brand == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(p.Brand)) {
+		return p.Name
+	}
+	return p.Brand + " " + p.Name
+}
+
+// Carbs is the carbohydrate in grams of the product.
+func (p *Product) Carbs(grams float64) float64 {
+	return p.CarbsPer100g * grams / 100
+}
+
+// ServingsPerPackage is how many servings a package holds, or zero when
+// the serving or package size is unknown.
+func (p *Product) ServingsPerPackage() float64 {
+	if p.ServingGrams <= 0 || p.PackageGrams <= 0 {
+		return 0
+	}
+	return p.PackageGrams / p.ServingGrams
+}
+
+// NormalizeBarcode turns a UPC-A, EAN-8, EAN-13 or GTIN-14 code into the
+// form products are stored under: UPC-A codes become EAN-13 with a leading
+// zero and GTIN-14 codes lose their leading zero. Spaces and dashes are
+// ignored. The check digit is not verified; see ValidBarcode.
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("barcode %q must contain only digits", code)
+		}
+	}
+	switch len(code) {
+	case 8, 13:
+		return code, nil
+	case 12:
+		return "0" + code, nil
+	case 14:
+		if code[0] == '0' {
+			return code[1:], nil
+		}
+		return code, nil
+	}
+	return "", fmt.Errorf("barcode %q must have 8, 12, 13 or 14 digits", code)
+}
+
+// ValidBarcode reports whether a normalized barcode's check digit is
+// correct, which catches most mistyped codes.
+func ValidBarcode(code string) bool {
+	if len(code) < 8 {
+		return false
+	}
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		digit := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultProfileID is the profile used when a request does not select one.
+const DefaultProfileID = "default"
+
+type Profile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProfileSettings struct {
+	ProfileID string `json:"profile_id"`
+	// Timezone is an IANA zone name; empty means the server's local zone.
+	Timezone string `json:"timezone,omitempty"`
+	// CarbRatios maps a time of day ("breakfast", "lunch", "dinner",
+	// "default", ...) to grams of carbohydrate covered by one unit of insulin.
+	CarbRatios map[string]float64 `json:"carb_ratios,omitempty"`
+	// MealSchedule assigns meals to slots by their local time of day; the
+	// first window holding a meal's time wins and meals outside every
+	// window are snacks. Empty means DefaultSlotWindows.
+	MealSchedule []SlotWindow `json:"meal_schedule,omitempty"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// Location resolves the configured timezone, falling back to the server's
+// local zone when none is set.
+func (p *ProfileSettings) Location() (*time.Location, error) {
+	if p == nil || p.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(p.Timezone)
+}
+
+// SlotWindows returns the profile's meal schedule.
+func (p *ProfileSettings) SlotWindows() []SlotWindow {
+	if p == nil || len(p.MealSchedule) == 0 {
+		return DefaultSlotWindows
+	}
+	return p.MealSchedule
+}
+
+// MealSlot returns the slot of a meal eaten at t, which should already be
+// in the profile's timezone.
+func (p *ProfileSettings) MealSlot(t time.Time) string {
+	return SlotAt(p.SlotWindows(), MinutesOfDay(t))
+}
+
+// CarbRatio returns the carb ratio for a meal slot, falling back to the
+// "default" ratio. ok is false when neither is set.
+func (p *ProfileSettings) CarbRatio(slot string) (ratio float64, ok bool) {
+	if p == nil {
+		return 0, false
+	}
+	if ratio, ok = p.CarbRatios[slot]; ok && ratio > 0 {
+		return ratio, true
+	}
+	ratio, ok = p.CarbRatios["default"]
+	return ratio, ok && ratio > 0
+}
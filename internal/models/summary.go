@@ -0,0 +1,168 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Meal slots group meals by the time of day they were eaten. The names
+// match the keys used for carb ratios. A profile may add its own slots,
+// such as "pre-workout", through its meal schedule or by naming them when
+// logging a meal.
+const (
+	SlotBreakfast = "breakfast"
+	SlotLunch     = "lunch"
+	SlotDinner    = "dinner"
+	SlotSnack     = "snack"
+)
+
+var MealSlots = []string{SlotBreakfast, SlotLunch, SlotDinner, SlotSnack}
+
+var slotNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// NormalizeSlot lowercases a slot name and checks that it is a single word
+// of letters, digits, dashes and underscores, at most 32 characters long.
+func NormalizeSlot(slot string) (string, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(slot), "-"))
+	if !slotNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid meal slot %q: use a name such as breakfast, snack or pre-workout", slot)
+	}
+	return normalized, nil
+}
+
+// SlotWindow is the time of day a slot covers, in minutes after local
+// midnight. End is exclusive; a window with End before Start wraps past
+// midnight. In JSON, Start and End are HH:MM.
+type SlotWindow struct {
+	Slot  string
+	Start int
+	End   int
+}
+
+type slotWindowJSON struct {
+	Slot  string `json:"slot"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (w SlotWindow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(slotWindowJSON{Slot: w.Slot, Start: clock(w.Start), End: clock(w.End)})
+}
+
+func (w *SlotWindow) UnmarshalJSON(data []byte) error {
+	var v slotWindowJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	start, err := ParseClock(v.Start)
+	if err != nil {
+		return err
+	}
+	end, err := ParseClock(v.End)
+	if err != nil {
+		return err
+	}
+	*w = SlotWindow{Slot: v.Slot, Start: start, End: end}
+	return nil
+}
+
+// Contains reports whether a time of day, in minutes after midnight, falls
+// in the window.
+func (w SlotWindow) Contains(minutes int) bool {
+	if w.Start <= w.End {
+		return minutes >= w.Start && minutes < w.End
+	}
+	return minutes >= w.Start || minutes < w.End
+}
+
+// DefaultSlotWindows are the main meals. Anything outside them is a snack.
+var DefaultSlotWindows = []SlotWindow{
+	{SlotBreakfast, 4 * 60, 10*60 + 30},
+	{SlotLunch, 11 * 60, 14*60 + 30},
+	{SlotDinner, 17 * 60, 21*60 + 30},
+}
+
+// MinutesOfDay returns the minutes after midnight of t, which should
+// already be in the profile's timezone.
+func MinutesOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// ParseClock returns the minutes after midnight of an HH:MM time.
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return MinutesOfDay(t), nil
+}
+
+func clock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60%24, minutes%60)
+}
+
+// SlotAt returns the slot of the first window holding a time of day, or
+// snack when none does.
+func SlotAt(windows []SlotWindow, minutes int) string {
+	for _, w := range windows {
+		if w.Contains(minutes) {
+			return w.Slot
+		}
+	}
+	return SlotSnack
+}
+
+// Summary aggregates a profile's meals over a date range for reports.
+type Summary struct {
+	ProfileID   string    `json:"profile_id"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	Timezone    string    `json:"timezone"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	MealCount  int     `json:"meal_count"`
+	TotalCarbs float64 `json:"total_carbs"`
+	// Days has an entry for every date in the range, including days
+	// without meals.
+	Days       []DailyTotal      `json:"days"`
+	Slots      []SlotAverage     `json:"slots"`
+	Confidence []ConfidenceCount `json:"confidence"`
+	TopFoods   []FoodCount       `json:"top_foods"`
+	// Tags counts the meals carrying each tag, most used first. A meal
+	// with several tags counts towards each.
+	Tags []TagCount `json:"tags"`
+}
+
+type DailyTotal struct {
+	Date  string  `json:"date"`
+	Meals int     `json:"meals"`
+	Carbs float64 `json:"carbs"`
+}
+
+type SlotAverage struct {
+	Slot         string  `json:"slot"`
+	Meals        int     `json:"meals"`
+	AverageCarbs float64 `json:"average_carbs"`
+}
+
+type ConfidenceCount struct {
+	Confidence ConfidenceLevel `json:"confidence"`
+	Meals      int             `json:"meals"`
+}
+
+type FoodCount struct {
+	Name       string  `json:"name"`
+	Count      int     `json:"count"`
+	TotalCarbs float64 `json:"total_carbs"`
+}
+
+// DailyAverage is the mean carbs per day over the whole range.
+func (s *Summary) DailyAverage() float64 {
+	if len(s.Days) == 0 {
+		return 0
+	}
+	return s.TotalCarbs / float64(len(s.Days))
+}
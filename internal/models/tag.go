@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTagLength is the longest tag, in characters.
+const maxTagLength = 32
+
+// NormalizeTag lowercases a tag and collapses its whitespace, so "Ate  Out"
+// and "ate out" are the same tag. Tags may not contain commas.
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	switch {
+	case normalized == "":
+		return "", fmt.Errorf("tag cannot be empty")
+	case utf8.RuneCountInString(normalized) > maxTagLength:
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+	case strings.Contains(normalized, ","):
+		return "", fmt.Errorf("tag %q cannot contain a comma", tag)
+	}
+	return normalized, nil
+}
+
+// NormalizeTags normalizes a meal's tags, dropping duplicates, and sorts
+// them.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// TagCount is how often a tag was used and the average carbs of the meals
+// carrying it.
+type TagCount struct {
+	Tag          string  `json:"tag"`
+	Meals        int     `json:"meals"`
+	AverageCarbs float64 `json:"average_carbs"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// APIToken describes a bearer token accepted by the HTTP transport. Only a
+// hash of the secret is ever stored.
+type APIToken struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// ProfileID pins the token to one profile; empty allows any profile.
+	ProfileID  string     `json:"profile_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,227 @@
+package products
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Nutrition labels are read with a handful of patterns rather than a model,
+// so the same text always gives the same values. The text may be pasted or
+// come from OCR, one nutrient per line or all on one line: every nutrient
+// name starts a segment that runs to the next known term, and the amounts
+// in the segment are its columns.
+
+var (
+	decimalComma = regexp.MustCompile(`(\d),(\d)`)
+
+	servingSizePattern = regexp.MustCompile(`(?i)\bserving\s+size\s*:?\s*([^\n(]*(?:\([^)\n]*\))?)`)
+
+	servingsPattern = regexp.MustCompile(`(?i)` +
+		`(?:about\s+|approx\.?\s+)?(\d+(?:\.\d+)?)\s+(?:servings|portions)\s+per\s+(?:container|package|pack)\b` +
+		`|(?:servings|portions)\s+per\s+(?:container|package|pack)\s*:?\s*(?:about\s+|approx\.?\s+)?(\d+(?:\.\d+)?)` +
+		`|contains\s+(?:about\s+)?(\d+(?:\.\d+)?)\s+(?:servings|portions)\b`)
+
+	// columnPattern finds the column headings, such as "per 100g", "per
+	// serving", "per 30 g portion" or "per biscuit (12.5g)".
+	columnPattern = regexp.MustCompile(`(?i)\bper\s+(\d+(?:\.\d+)?\s*(?:g|ml)\b(?:\s+(?:serving|portion))?` +
+		`|(?:\d+\s+)?[a-z]+(?:\s*\(\s*\d+(?:\.\d+)?\s*(?:g|ml)\s*\))?)`)
+
+	per100Pattern = regexp.MustCompile(`(?i)^100\s*(?:g|ml)\b`)
+
+	// usPattern recognizes a US Nutrition Facts panel, whose values are per
+	// serving when it has no column headings.
+	usPattern = regexp.MustCompile(`(?i)total\s+carbohydrate|daily\s+value|amount\s+per\s+serving|serving\s+size|servings\s+per\s+container`)
+
+	// labelTerm matches the nutrient names and everything else a label
+	// holds amounts for. Only the named groups are read; the rest only end
+	// the segment before them.
+	labelTerm = regexp.MustCompile(`(?i)` +
+		`\b(?:energy|calories|kcal|kj|saturated\s+fat|saturates|trans\s+fat|poly-?unsaturate[sd]?(?:\s+fat)?` +
+		`|mono-?unsaturate[sd]?(?:\s+fat)?|cholesterol|sodium|salt|added\s+sugars|polyols|starch` +
+		`|vitamin\s+[a-z0-9]+|calcium|iron|potassium|daily\s+value|serving\s+size|servings\s+per\s+[a-z]+` +
+		`|amount\s+per\s+serving|nutrition\s+facts|nutrition\s+information|typical\s+values)\b` +
+		`|\b(?P<carbs>total\s+carbohydrates?\b|total\s+carbs?\b\.?|carbohydrates?\b|carbs?\b)` +
+		`|\b(?P<fiber>(?:dietary\s+)?fib(?:er|re)s?)\b` +
+		`|\b(?P<sugars>(?:total\s+)?sugars?)\b` +
+		`|\b(?P<protein>proteins?)\b` +
+		`|\b(?P<fat>(?:total\s+)?fat)\b`)
+
+	amountPattern = regexp.MustCompile(`(?i)(?:<\s*)?(\d+(?:\.\d+)?)\s*(mg|g\b)?(\s*%)?`)
+)
+
+// ParseLabel reads serving size, servings per container and the carbs,
+// fiber, sugars, protein and fat of a nutrition label from its text. It
+// understands US Nutrition Facts panels and EU tables with per 100 g and
+// per serving columns.
+func ParseLabel(text string) (*models.NutritionLabel, error) {
+	text = decimalComma.ReplaceAllString(text, "$1.$2")
+	label := &models.NutritionLabel{}
+
+	terms := labelTerm.FindAllStringSubmatchIndex(text, -1)
+	// The column headings come before the first nutrient
+	header := text
+	for _, term := range terms {
+		if termName(term) != "" {
+			header = text[:term[0]]
+			break
+		}
+	}
+
+	if m := servingSizePattern.FindStringSubmatchIndex(text); m != nil {
+		size := text[m[2]:m[3]]
+		if loc := labelTerm.FindStringIndex(size); loc != nil {
+			size = size[:loc[0]]
+		}
+		label.ServingSize = strings.TrimSpace(size)
+		label.ServingGrams = ParseGrams(label.ServingSize)
+		if m[0] < len(header) {
+			header = header[:m[0]] + " " + header[min(m[1], len(header)):]
+		}
+	}
+	if m := servingsPattern.FindStringSubmatch(text); m != nil {
+		for _, n := range m[1:] {
+			if n != "" {
+				label.ServingsPerContainer, _ = strconv.ParseFloat(n, 64)
+			}
+		}
+		header = servingsPattern.ReplaceAllString(header, " ")
+	}
+
+	columns := labelColumns(label, header, text)
+	values := make([]models.Nutrients, len(columns))
+	found := make([]bool, len(columns))
+	carbs := false
+	seen := map[string]bool{}
+	for i, term := range terms {
+		name := termName(term)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		end := len(text)
+		if i+1 < len(terms) {
+			end = terms[i+1][0]
+		}
+		amounts := labelAmounts(text[term[1]:end])
+		for col := range columns {
+			if col >= len(amounts) {
+				break
+			}
+			setNutrient(&values[col], name, amounts[col])
+			found[col] = true
+			if name == "carbs" {
+				carbs = true
+			}
+		}
+	}
+	if !carbs {
+		return nil, fmt.Errorf("no carbohydrate value found in the label text")
+	}
+
+	for col, per100 := range columns {
+		if !found[col] {
+			continue
+		}
+		nutrients := values[col]
+		if per100 {
+			label.Per100g = &nutrients
+		} else {
+			label.PerServing = &nutrients
+		}
+	}
+	if label.ServingGrams > 0 {
+		switch {
+		case label.Per100g == nil && label.PerServing != nil:
+			label.Per100g = scaleNutrients(label.PerServing, 100/label.ServingGrams)
+		case label.PerServing == nil && label.Per100g != nil:
+			label.PerServing = scaleNutrients(label.Per100g, label.ServingGrams/100)
+		}
+	}
+	return label, nil
+}
+
+// labelColumns returns the value columns of a label in order, true for per
+// 100 g and false for per serving. A serving heading sets the serving size
+// when the label gives none.
+func labelColumns(label *models.NutritionLabel, header, text string) []bool {
+	var columns []bool
+	for _, m := range columnPattern.FindAllStringSubmatch(header, -1) {
+		if per100Pattern.MatchString(m[1]) {
+			columns = append(columns, true)
+			continue
+		}
+		columns = append(columns, false)
+		if label.ServingGrams == 0 {
+			label.ServingGrams = ParseGrams(m[1])
+		}
+		if label.ServingSize == "" {
+			label.ServingSize = strings.TrimSpace(m[1])
+		}
+	}
+	if len(columns) > 0 {
+		return columns
+	}
+	return []bool{!usPattern.MatchString(text)}
+}
+
+// termName returns the nutrient a labelTerm match names, or "" for the
+// terms that are not read.
+func termName(match []int) string {
+	for i, name := range labelTerm.SubexpNames() {
+		if name != "" && match[2*i] >= 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// labelAmounts returns the amounts in grams in a segment of label text,
+// leaving out percentages of daily values.
+func labelAmounts(segment string) []float64 {
+	var amounts []float64
+	for _, m := range amountPattern.FindAllStringSubmatch(segment, -1) {
+		if m[3] != "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(m[2], "mg") {
+			value /= 1000
+		}
+		amounts = append(amounts, value)
+	}
+	return amounts
+}
+
+func setNutrient(n *models.Nutrients, name string, value float64) {
+	switch name {
+	case "carbs":
+		n.Carbs = value
+	case "fiber":
+		n.Fiber = value
+	case "sugars":
+		n.Sugars = value
+	case "protein":
+		n.Protein = value
+	case "fat":
+		n.Fat = value
+	}
+}
+
+func scaleNutrients(n *models.Nutrients, factor float64) *models.Nutrients {
+	round := func(v float64) float64 { return math.Round(v*factor*10) / 10 }
+	return &models.Nutrients{
+		Carbs:   round(n.Carbs),
+		Fiber:   round(n.Fiber),
+		Sugars:  round(n.Sugars),
+		Protein: round(n.Protein),
+		Fat:     round(n.Fat),
+	}
+}
@@ -0,0 +1,111 @@
+package products
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-meal-log/internal/models"
+)
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want models.NutritionLabel
+	}{
+		{
+			name: "US panel with daily values",
+			text: `Nutrition Facts
+8 servings per container
+Serving size 2/3 cup (55g)
+Amount per serving
+Calories 230
+                    % Daily Value*
+Total Fat 8g            10%
+  Saturated Fat 1g       5%
+  Trans Fat 0g
+Cholesterol 0mg          0%
+Sodium 160mg             7%
+Total Carbohydrate 37g  13%
+  Dietary Fiber 4g      14%
+  Total Sugars 12g
+    Includes 10g Added Sugars 20%
+Protein 3g`,
+			want: models.NutritionLabel{
+				ServingSize:          "2/3 cup (55g)",
+				ServingGrams:         55,
+				ServingsPerContainer: 8,
+				PerServing:           &models.Nutrients{Carbs: 37, Fiber: 4, Sugars: 12, Protein: 3, Fat: 8},
+				Per100g:              &models.Nutrients{Carbs: 67.3, Fiber: 7.3, Sugars: 21.8, Protein: 5.5, Fat: 14.5},
+			},
+		},
+		{
+			name: "EU table with decimal commas",
+			text: `Nutrition information
+Typical values   per 100g   per 30g serving
+Energy           1567kJ/372kcal   470kJ/112kcal
+Fat              3,5g       1,1g
+of which saturates 0,6g     0,2g
+Carbohydrate     72,4g      21,7g
+of which sugars  19,0g      5,7g
+Fibre            6,1g       1,8g
+Protein          8,9g       2,7g
+Salt             0,75g      0,23g`,
+			want: models.NutritionLabel{
+				ServingSize:  "30g serving",
+				ServingGrams: 30,
+				Per100g:      &models.Nutrients{Carbs: 72.4, Fiber: 6.1, Sugars: 19, Protein: 8.9, Fat: 3.5},
+				PerServing:   &models.Nutrients{Carbs: 21.7, Fiber: 1.8, Sugars: 5.7, Protein: 2.7, Fat: 1.1},
+			},
+		},
+		{
+			name: "single line of OCR text",
+			text: "NUTRITION per 100g Energy 1890kJ Fat 21g of which saturates 9.8g Carbohydrate 63g of which sugars 30g Fibre 2.5g Protein 6.4g Salt 0.5g",
+			want: models.NutritionLabel{
+				Per100g: &models.Nutrients{Carbs: 63, Fiber: 2.5, Sugars: 30, Protein: 6.4, Fat: 21},
+			},
+		},
+		{
+			name: "less than a gram",
+			text: "per 100ml\nEnergy 180kJ\nFat <0.5g\nCarbohydrate 10.6g\nof which sugars 10.6g\nProtein <1g",
+			want: models.NutritionLabel{
+				Per100g: &models.Nutrients{Carbs: 10.6, Sugars: 10.6, Protein: 1, Fat: 0.5},
+			},
+		},
+		{
+			name: "milligrams",
+			text: "Serving size 1 tablet (2g)\nCalories 5\nTotal Carbohydrate 1.5g\nTotal Sugars 500mg\nProtein 250mg",
+			want: models.NutritionLabel{
+				ServingSize:  "1 tablet (2g)",
+				ServingGrams: 2,
+				PerServing:   &models.Nutrients{Carbs: 1.5, Sugars: 0.5, Protein: 0.25},
+				Per100g:      &models.Nutrients{Carbs: 75, Sugars: 25, Protein: 12.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabel(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseLabel =\n%s\nwant\n%s", formatLabel(got), formatLabel(&tt.want))
+			}
+		})
+	}
+}
+
+func TestParseLabelWithoutCarbs(t *testing.T) {
+	_, err := ParseLabel("Nutrition Facts\nServing size 1 cup (240ml)\nCalories 0\nSodium 10mg\nProtein 0g")
+	if err == nil || !strings.Contains(err.Error(), "no carbohydrate") {
+		t.Errorf("ParseLabel error = %v, want no carbohydrate", err)
+	}
+}
+
+func formatLabel(l *models.NutritionLabel) string {
+	data, _ := json.Marshal(l)
+	return string(data)
+}
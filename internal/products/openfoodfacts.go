@@ -0,0 +1,261 @@
+// Package products reads packaged-food label data: Open Food Facts dumps,
+// imported into the products table used for barcode lookups, and the text
+// of nutrition labels.
+package products
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+const DefaultBatchSize = 1000
+
+// Store is the part of storage the import needs.
+type Store interface {
+	SaveProducts(products []*models.Product) error
+}
+
+// Report counts the products of an import. Products without a usable
+// barcode, name or carbohydrate value are skipped.
+type Report struct {
+	Read     int `json:"read"`
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// offProduct holds the Open Food Facts fields the import uses, from either
+// dump format.
+type offProduct struct {
+	code, name, brands, quantity, servingSize string
+	productQuantity, servingQuantity, carbs   string
+}
+
+// ImportOpenFoodFacts reads an Open Food Facts dump and saves its products
+// in batches. Both the tab-separated CSV export and the JSONL dump are
+// understood, gzip-compressed or not; the format is detected from the
+// content.
+func ImportOpenFoodFacts(store Store, r io.Reader, batchSize int) (*Report, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	br := bufio.NewReaderSize(r, 1<<16)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReaderSize(gz, 1<<16)
+	}
+
+	report := &Report{}
+	now := time.Now().UTC()
+	var batch []*models.Product
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.SaveProducts(batch); err != nil {
+			return err
+		}
+		report.Imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	add := func(p offProduct) error {
+		report.Read++
+		product, ok := p.product(now)
+		if !ok {
+			report.Skipped++
+			return nil
+		}
+		batch = append(batch, product)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	first, err := br.Peek(1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(first) == 1 && first[0] == '{' {
+		err = readJSONL(br, add)
+	} else {
+		err = readCSV(br, add)
+	}
+	if err != nil {
+		return report, err
+	}
+	return report, flush()
+}
+
+func readCSV(r io.Reader, fn func(offProduct) error) error {
+	cr := csv.NewReader(r)
+	cr.Comma = '\t'
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[name] = i
+	}
+	if _, ok := index["code"]; !ok {
+		return fmt.Errorf("not an Open Food Facts CSV export: no code column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read product: %w", err)
+		}
+		err = fn(offProduct{
+			code:            field(record, "code"),
+			name:            field(record, "product_name"),
+			brands:          field(record, "brands"),
+			quantity:        field(record, "quantity"),
+			servingSize:     field(record, "serving_size"),
+			productQuantity: field(record, "product_quantity"),
+			servingQuantity: field(record, "serving_quantity"),
+			carbs:           field(record, "carbohydrates_100g"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONL(r *bufio.Reader, fn func(offProduct) error) error {
+	// Numbers appear both as JSON numbers and as strings in the dump
+	type document struct {
+		Code            json.RawMessage            `json:"code"`
+		ProductName     string                     `json:"product_name"`
+		Brands          string                     `json:"brands"`
+		Quantity        string                     `json:"quantity"`
+		ServingSize     string                     `json:"serving_size"`
+		ProductQuantity json.RawMessage            `json:"product_quantity"`
+		ServingQuantity json.RawMessage            `json:"serving_quantity"`
+		Nutriments      map[string]json.RawMessage `json:"nutriments"`
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var doc document
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read product: %w", err)
+		}
+		err = fn(offProduct{
+			code:            rawString(doc.Code),
+			name:            strings.TrimSpace(doc.ProductName),
+			brands:          strings.TrimSpace(doc.Brands),
+			quantity:        doc.Quantity,
+			servingSize:     strings.TrimSpace(doc.ServingSize),
+			productQuantity: rawString(doc.ProductQuantity),
+			servingQuantity: rawString(doc.ServingQuantity),
+			carbs:           rawString(doc.Nutriments["carbohydrates_100g"]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rawString returns a JSON string or number as text.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func (p offProduct) product(now time.Time) (*models.Product, bool) {
+	barcode, err := models.NormalizeBarcode(p.code)
+	if err != nil || p.name == "" {
+		return nil, false
+	}
+	carbs, err := strconv.ParseFloat(p.carbs, 64)
+	if err != nil || carbs < 0 || carbs > 100 {
+		return nil, false
+	}
+
+	// Brands is a comma-separated list, the main brand first
+	brand, _, _ := strings.Cut(p.brands, ",")
+	product := &models.Product{
+		Barcode:      barcode,
+		Name:         p.name,
+		Brand:        strings.TrimSpace(brand),
+		CarbsPer100g: carbs,
+		ServingSize:  p.servingSize,
+		ServingGrams: grams(p.servingQuantity, p.servingSize),
+		PackageGrams: grams(p.productQuantity, p.quantity),
+		Source:       models.ProductSourceOpenFoodFacts,
+		UpdatedAt:    now,
+	}
+	return product, true
+}
+
+var quantityPattern = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*(kg|g|ml|cl|l)\b`)
+
+// grams reads a quantity in grams, from the normalized number when the dump
+// has one and otherwise from the label text such as "500 g" or "1,5 l".
+// Millilitres count as grams, as they do in label values per 100 ml.
+func grams(number, text string) float64 {
+	if g, err := strconv.ParseFloat(number, 64); err == nil && g > 0 {
+		return g
+	}
+	return ParseGrams(text)
+}
+
+// ParseGrams reads the first weight or volume in a label text, such as
+// "2 biscuits (25 g)", in grams. It returns zero when there is none.
+func ParseGrams(text string) float64 {
+	m := quantityPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(strings.Replace(m[1], ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.ToLower(m[2]) {
+	case "kg", "l":
+		return value * 1000
+	case "cl":
+		return value * 10
+	}
+	return value
+}
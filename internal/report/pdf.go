@@ -0,0 +1,77 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoPDFConverter is returned when no command for converting HTML to PDF
+// is configured or installed.
+var ErrNoPDFConverter = errors.New("no HTML to PDF converter found; install wkhtmltopdf or Chromium, or set a PDF command")
+
+// knownConverters are tried in order when no PDF command is configured.
+// {in} and {out} are replaced with the HTML and PDF file paths.
+var knownConverters = [][]string{
+	{"wkhtmltopdf", "--quiet", "--enable-local-file-access", "{in}", "{out}"},
+	{"chromium", "--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "file://{in}"},
+	{"chromium-browser", "--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "file://{in}"},
+	{"google-chrome", "--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "file://{in}"},
+}
+
+// ConvertPDF turns an HTML report into a PDF with an external converter,
+// since the report must not depend on a PDF library. command is a command
+// line with {in} and {out} placeholders; when empty a known converter on
+// PATH is used.
+func ConvertPDF(ctx context.Context, htmlReport []byte, command string) ([]byte, error) {
+	args, err := converterArgs(command)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "meal-log-report-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "report.html")
+	out := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(in, htmlReport, 0600); err != nil {
+		return nil, err
+	}
+
+	for i, arg := range args {
+		args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(arg)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+
+	pdf, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s did not write a PDF: %w", args[0], err)
+	}
+	return pdf, nil
+}
+
+func converterArgs(command string) ([]string, error) {
+	if command != "" {
+		args := strings.Fields(command)
+		if !strings.Contains(command, "{in}") || !strings.Contains(command, "{out}") {
+			return nil, fmt.Errorf("PDF command %q must contain {in} and {out}", command)
+		}
+		return args, nil
+	}
+	for _, converter := range knownConverters {
+		if _, err := exec.LookPath(converter[0]); err == nil {
+			return append([]string{}, converter...), nil
+		}
+	}
+	return nil, ErrNoPDFConverter
+}
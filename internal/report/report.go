@@ -0,0 +1,285 @@
+// Package report renders meal summaries as a self-contained HTML page for
+// sharing with a care team. Styles and charts are inlined, so the file can
+// be opened, printed or converted to PDF without network access.
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// DefaultTopFoods is how many foods the report lists.
+const DefaultTopFoods = 15
+
+// bar is one value of a bar chart.
+type bar struct {
+	Label string
+	Value float64
+}
+
+var confidenceColors = map[models.ConfidenceLevel]string{
+	models.HighConfidence:   "#2e7d32",
+	models.MediumConfidence: "#f9a825",
+	models.LowConfidence:    "#c62828",
+}
+
+var funcs = template.FuncMap{
+	"grams": func(v float64) string {
+		return fmt.Sprintf("%.0f g", v)
+	},
+	"percent": func(n, total int) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.0f%%", float64(n)*100/float64(total))
+	},
+	"title": func(s string) string {
+		if s == "" {
+			return "Unknown"
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"avg": func(total float64, n int) float64 {
+		if n == 0 {
+			return 0
+		}
+		return total / float64(n)
+	},
+	"weekday": func(date string) string {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return ""
+		}
+		return t.Format("Mon")
+	},
+	"dailyChart":      dailyChart,
+	"slotChart":       slotChart,
+	"confidenceChart": confidenceChart,
+}
+
+var page = template.Must(template.New("report").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Meal log report {{.StartDate}} to {{.EndDate}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #212121; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: 0.2em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: 0.2em; }
+.meta { color: #616161; }
+.cards { display: flex; gap: 1em; flex-wrap: wrap; margin-top: 1.5em; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 0.8em 1.2em; min-width: 8em; }
+.card .value { font-size: 1.5em; font-weight: 600; }
+.card .label { color: #616161; font-size: 0.9em; }
+table { border-collapse: collapse; width: 100%; margin-top: 0.8em; font-size: 0.95em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #eee; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+svg { display: block; max-width: 100%; height: auto; }
+svg text { font-family: inherit; font-size: 11px; fill: #424242; }
+.empty { color: #9e9e9e; }
+@media print { body { margin: 0; } h2 { break-after: avoid; } table, svg { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>Meal log report</h1>
+<div class="meta">{{.StartDate}} to {{.EndDate}} &middot; profile {{.ProfileID}} &middot; times in {{.Timezone}} &middot; generated {{.GeneratedAt.Format "2006-01-02 15:04"}}</div>
+
+<div class="cards">
+<div class="card"><div class="value">{{.MealCount}}</div><div class="label">meals</div></div>
+<div class="card"><div class="value">{{grams .TotalCarbs}}</div><div class="label">total carbs</div></div>
+<div class="card"><div class="value">{{grams .DailyAverage}}</div><div class="label">average per day</div></div>
+</div>
+
+<h2>Daily carb totals</h2>
+{{dailyChart .Days}}
+<table>
+<tr><th>Date</th><th></th><th class="num">Meals</th><th class="num">Carbs</th></tr>
+{{range .Days}}<tr{{if eq .Meals 0}} class="empty"{{end}}><td>{{.Date}}</td><td>{{weekday .Date}}</td><td class="num">{{.Meals}}</td><td class="num">{{grams .Carbs}}</td></tr>
+{{end}}</table>
+
+<h2>Average carbs by meal slot</h2>
+{{slotChart .Slots}}
+<table>
+<tr><th>Slot</th><th class="num">Meals</th><th class="num">Average carbs</th></tr>
+{{range .Slots}}<tr><td>{{title .Slot}}</td><td class="num">{{.Meals}}</td><td class="num">{{grams .AverageCarbs}}</td></tr>
+{{end}}</table>
+
+<h2>Tags</h2>
+{{if .Tags}}<table>
+<tr><th>Tag</th><th class="num">Meals</th><th class="num">Average carbs</th></tr>
+{{range .Tags}}<tr><td>{{.Tag}}</td><td class="num">{{.Meals}}</td><td class="num">{{grams .AverageCarbs}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No tagged meals in this period.</p>{{end}}
+
+<h2>Estimate confidence</h2>
+{{confidenceChart .Confidence .MealCount}}
+<table>
+<tr><th>Confidence</th><th class="num">Meals</th><th class="num">Share</th></tr>
+{{range .Confidence}}<tr><td>{{title (print .Confidence)}}</td><td class="num">{{.Meals}}</td><td class="num">{{percent .Meals $.MealCount}}</td></tr>
+{{end}}</table>
+
+<h2>Most frequent foods</h2>
+{{if .TopFoods}}<table>
+<tr><th>Food</th><th class="num">Times eaten</th><th class="num">Total carbs</th><th class="num">Average carbs</th></tr>
+{{range .TopFoods}}<tr><td>{{.Name}}</td><td class="num">{{.Count}}</td><td class="num">{{grams .TotalCarbs}}</td><td class="num">{{grams (avg .TotalCarbs .Count)}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No foods recorded in this period.</p>{{end}}
+</body>
+</html>
+`))
+
+// Render writes the HTML report for summary to w.
+func Render(w io.Writer, summary *models.Summary) error {
+	return page.Execute(w, summary)
+}
+
+func dailyChart(days []models.DailyTotal) template.HTML {
+	bars := make([]bar, len(days))
+	for i, day := range days {
+		bars[i] = bar{Label: day.Date[5:], Value: day.Carbs}
+	}
+	return barChart(bars, "#1565c0")
+}
+
+func slotChart(slots []models.SlotAverage) template.HTML {
+	bars := make([]bar, len(slots))
+	for i, slot := range slots {
+		bars[i] = bar{Label: slot.Slot, Value: slot.AverageCarbs}
+	}
+	return barChart(bars, "#6a1b9a")
+}
+
+// barChart draws vertical bars with a labelled y axis. Long series only
+// label every few bars so the labels do not overlap.
+func barChart(bars []bar, color string) template.HTML {
+	const width, height = 900.0, 260.0
+	const left, right, top, bottom = 48.0, 8.0, 12.0, 36.0
+	plotWidth, plotHeight := width-left-right, height-top-bottom
+
+	max := 0.0
+	for _, b := range bars {
+		max = math.Max(max, b.Value)
+	}
+	scale := niceMax(max)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" role="img">`, width, height)
+
+	// Grid lines and y axis labels
+	for i := 0; i <= 4; i++ {
+		v := scale * float64(i) / 4
+		y := top + plotHeight - plotHeight*float64(i)/4
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`, left, y, width-right, y)
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" text-anchor="end">%.0f g</text>`, left-6, y+4, v)
+	}
+
+	if len(bars) > 0 {
+		slot := plotWidth / float64(len(bars))
+		every := int(math.Ceil(float64(len(bars)) * 44 / plotWidth))
+		for i, b := range bars {
+			h := 0.0
+			if scale > 0 {
+				h = plotHeight * b.Value / scale
+			}
+			x := left + slot*float64(i)
+			fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.0f g</title></rect>`,
+				x+slot*0.15, top+plotHeight-h, slot*0.7, h, color, html.EscapeString(b.Label), b.Value)
+			if i%every == 0 {
+				fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`,
+					x+slot/2, height-bottom+16, html.EscapeString(b.Label))
+			}
+		}
+	}
+
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// confidenceChart draws a single stacked bar of the confidence shares.
+func confidenceChart(counts []models.ConfidenceCount, total int) template.HTML {
+	const width, height, barHeight = 900.0, 56.0, 28.0
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" role="img">`, width, height)
+	if total == 0 {
+		fmt.Fprintf(&sb, `<rect x="0" y="0" width="%.0f" height="%.0f" fill="#eeeeee"/>`, width, barHeight)
+	}
+
+	x := 0.0
+	for _, c := range counts {
+		if c.Meals == 0 || total == 0 {
+			continue
+		}
+		w := width * float64(c.Meals) / float64(total)
+		color, ok := confidenceColors[c.Confidence]
+		if !ok {
+			color = "#9e9e9e"
+		}
+		label := html.EscapeString(string(c.Confidence))
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="0" width="%.1f" height="%.0f" fill="%s"><title>%s: %d</title></rect>`,
+			x, w, barHeight, color, label, c.Meals)
+		if w > 60 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.0f">%s %.0f%%</text>`, x+4, barHeight+18, label, w*100/width)
+		}
+		x += w
+	}
+
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// niceMax rounds a chart maximum up to 1, 2 or 5 times a power of ten so
+// the axis labels are round numbers.
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 10
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// DefaultDays is the length of a report when no start date is given.
+const DefaultDays = 14
+
+// MaxDays is the longest report, which keeps the daily totals and the
+// chart of a single report to a year.
+const MaxDays = 366
+
+// DateRange fills in a missing end date with today in loc and a missing
+// start date with DefaultDays before the end, and rejects ranges that are
+// backwards or longer than MaxDays.
+func DateRange(startDate, endDate string, loc *time.Location) (string, string, error) {
+	now := time.Now().In(loc)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if endDate != "" {
+		var err error
+		if end, err = time.Parse("2006-01-02", endDate); err != nil {
+			return "", "", fmt.Errorf("invalid end date %q", endDate)
+		}
+	}
+	start := end.AddDate(0, 0, -(DefaultDays - 1))
+	if startDate != "" {
+		var err error
+		if start, err = time.Parse("2006-01-02", startDate); err != nil {
+			return "", "", fmt.Errorf("invalid start date %q", startDate)
+		}
+	}
+
+	switch {
+	case end.Before(start):
+		return "", "", fmt.Errorf("end date %s is before start date %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	case start.AddDate(0, 0, MaxDays-1).Before(end):
+		return "", "", fmt.Errorf("reports cover at most %d days; choose a shorter range", MaxDays)
+	}
+	return start.Format("2006-01-02"), end.Format("2006-01-02"), nil
+}
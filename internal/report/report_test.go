@@ -0,0 +1,39 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		want       [2]string
+		wantErr    bool
+	}{
+		{"default start", "", "2026-05-31", [2]string{"2026-05-18", "2026-05-31"}, false},
+		{"given range", "2026-05-01", "2026-05-31", [2]string{"2026-05-01", "2026-05-31"}, false},
+		{"one day", "2026-05-31", "2026-05-31", [2]string{"2026-05-31", "2026-05-31"}, false},
+		{"longest range", "2025-06-01", "2026-06-01", [2]string{"2025-06-01", "2026-06-01"}, false},
+		{"too long", "2025-05-31", "2026-06-01", [2]string{}, true},
+		{"from year one", "0001-01-01", "2026-05-31", [2]string{}, true},
+		{"backwards", "2026-06-01", "2026-05-31", [2]string{}, true},
+		{"bad start", "May 1", "2026-05-31", [2]string{}, true},
+		{"bad end", "2026-05-01", "tomorrow", [2]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := DateRange(tt.start, tt.end, time.UTC)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("DateRange = %s, %s, want an error", start, end)
+				}
+				return
+			}
+			if err != nil || start != tt.want[0] || end != tt.want[1] {
+				t.Errorf("DateRange = %s, %s, %v, want %s, %s", start, end, err, tt.want[0], tt.want[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/storage"
+)
+
+const (
+	AuthModeToken = "token"
+	AuthModeNone  = "none"
+)
+
+var errUnauthorized = errors.New("authentication required")
+
+// identity is the authenticated caller of a request.
+type identity struct {
+	// Subject is the token ID or the JWT subject.
+	Subject string `json:"subject"`
+	// Method is "token", "jwt" or "anonymous".
+	Method string `json:"method"`
+	// ProfileID pins the caller to a single profile; empty allows any.
+	ProfileID string `json:"profile_id,omitempty"`
+}
+
+var anonymous = &identity{Subject: "anonymous", Method: "anonymous"}
+
+// admin reports whether the caller may use server-wide operations such as
+// reading server files or writing snapshots: an authenticated caller not
+// pinned to a profile. Anonymous callers, allowed when authentication is
+// disabled, never are.
+func (ident *identity) admin() bool {
+	return ident.Method != anonymous.Method && ident.ProfileID == ""
+}
+
+// String identifies the caller in the audit trail, e.g. "token:tok_1234".
+func (ident *identity) String() string {
+	if ident.Method == anonymous.Method {
+		return anonymous.Subject
+	}
+	return ident.Method + ":" + ident.Subject
+}
+
+func (s *MealLogServer) authenticate(r *http.Request) (*identity, error) {
+	return s.authenticateCredential(auth.BearerToken(r))
+}
+
+// authenticateCredential checks an API token or JWT, however the client
+// passed it.
+func (s *MealLogServer) authenticateCredential(credential string) (*identity, error) {
+	if s.config.AuthMode == AuthModeNone {
+		return anonymous, nil
+	}
+
+	if credential == "" {
+		return nil, errUnauthorized
+	}
+
+	if auth.LooksLikeAPIToken(credential) {
+		token, err := s.storage.LookupToken(auth.HashToken(credential))
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown or revoked token", auth.ErrInvalidToken)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &identity{Subject: token.ID, Method: "token", ProfileID: token.ProfileID}, nil
+	}
+
+	if s.jwtValidator == nil {
+		return nil, fmt.Errorf("%w: unrecognised token", auth.ErrInvalidToken)
+	}
+	claims, err := s.jwtValidator.Validate(credential)
+	if err != nil {
+		return nil, err
+	}
+	profileID := claims.String(s.jwtValidator.ProfileClaim())
+	if profileID == "" {
+		return nil, fmt.Errorf("%w: missing %q claim", auth.ErrInvalidToken, s.jwtValidator.ProfileClaim())
+	}
+	return &identity{Subject: claims.Subject(), Method: "jwt", ProfileID: profileID}, nil
+}
+
+// sendUnauthorized answers with 401 and a challenge that points OAuth
+// clients at the protected resource metadata when JWTs are accepted.
+func (s *MealLogServer) sendUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	challenge := `Bearer realm="meal-log"`
+	if !errors.Is(err, errUnauthorized) {
+		challenge += `, error="invalid_token"`
+	}
+	if s.jwtValidator != nil {
+		challenge += fmt.Sprintf(`, resource_metadata="%s/.well-known/oauth-protected-resource"`, s.resourceURL(r))
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// handleProtectedResourceMetadata serves RFC 9728 metadata so OAuth clients
+// can discover which authorization server issues tokens for this server.
+func (s *MealLogServer) handleProtectedResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w, r)
+	metadata := map[string]interface{}{
+		"resource":                 s.resourceURL(r),
+		"bearer_methods_supported": []string{"header"},
+	}
+	if issuer := s.jwtValidator.Issuer(); issuer != "" {
+		metadata["authorization_servers"] = []string{issuer}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(formatJSON(metadata)))
+}
+
+func (s *MealLogServer) resourceURL(r *http.Request) string {
+	if s.config.ResourceURL != "" {
+		return strings.TrimSuffix(s.config.ResourceURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+func (s *MealLogServer) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	allowed := ""
+	for _, o := range s.config.CORSOrigins {
+		if o == "*" || o == origin {
+			allowed = o
+			break
+		}
+	}
+	if allowed == "" {
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+	if allowed != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Mcp-Session-Id, "+profileHeader)
+	w.Header().Set("Access-Control-Expose-Headers", "Mcp-Session-Id, WWW-Authenticate")
+}
+
+func withIdentity(ctx context.Context, ident *identity) context.Context {
+	return context.WithValue(ctx, identityKey, ident)
+}
+
+func identityFromContext(ctx context.Context) *identity {
+	if ident, ok := ctx.Value(identityKey).(*identity); ok {
+		return ident
+	}
+	return anonymous
+}
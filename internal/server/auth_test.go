@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImagePathRequiresAdmin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(path, []byte("not for anonymous callers"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		ident *identity
+		admin bool
+	}{
+		{"anonymous", anonymous, false},
+		{"pinned token", &identity{Subject: "tok_1", Method: "token", ProfileID: "bob"}, false},
+		{"admin token", &identity{Subject: "tok_2", Method: "token"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadImage(withIdentity(context.Background(), tt.ident), "", path)
+			refused := err != nil && strings.Contains(err.Error(), "requires an authenticated token")
+			if refused == tt.admin {
+				t.Errorf("loadImage error = %v, admin = %v", err, tt.admin)
+			}
+		})
+	}
+}
+
+// Products are shared, so only an admin may change the carbs every profile
+// doses from.
+func TestAddProductRequiresAdmin(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name  string
+		ident *identity
+		admin bool
+	}{
+		{"anonymous", anonymous, false},
+		{"pinned token", &identity{Subject: "tok_1", Method: "token", ProfileID: "bob"}, false},
+		{"admin token", &identity{Subject: "tok_2", Method: "token"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{"barcode": "4006381333931", "name": tt.name, "carbs_per_100g": 60.0}
+			_, err := s.addProduct(withIdentity(context.Background(), tt.ident), params)
+			refused := err != nil && strings.Contains(err.Error(), "requires an authenticated token")
+			if refused == tt.admin {
+				t.Errorf("addProduct error = %v, admin = %v", err, tt.admin)
+			}
+		})
+	}
+
+	product, err := s.storage.GetProduct("4006381333931")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "admin token" {
+		t.Errorf("product name = %q, want the admin's entry", product.Name)
+	}
+}
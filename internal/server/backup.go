@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func backupTools() []Tool {
+	return []Tool{
+		{
+			Name:        "create_snapshot",
+			Description: "Write a snapshot of the database to the server's backup directory now, rotating out old snapshots (admin only)",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) createSnapshot(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	if s.backups == nil {
+		return nil, fmt.Errorf("snapshots are not configured on this server")
+	}
+	if !identityFromContext(ctx).admin() {
+		return nil, fmt.Errorf("creating snapshots requires an authenticated token that is not limited to one profile")
+	}
+
+	snapshot, removed, err := s.backups.Create()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	log.Printf("Snapshot %s created by %s", snapshot.Name, identityFromContext(ctx))
+	return map[string]interface{}{
+		"snapshot": snapshot,
+		"removed":  removed,
+	}, nil
+}
+
+// snapshotRetry is how long scheduleSnapshots waits after a failure.
+const snapshotRetry = time.Hour
+
+// scheduleSnapshots writes a snapshot every BackupInterval, counted from
+// the newest snapshot so restarts neither skip nor repeat one.
+func (s *MealLogServer) scheduleSnapshots(ctx context.Context) {
+	for {
+		wait := time.Duration(0)
+		latest, err := s.backups.Latest()
+		switch {
+		case err != nil:
+			// Without the newest snapshot's time, wait as after a failure
+			// rather than taking snapshots back to back
+			log.Printf("Failed to list snapshots: %v", err)
+			wait = snapshotRetry
+		case latest != nil:
+			wait = time.Until(latest.CreatedAt.Add(s.config.BackupInterval))
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		snapshot, removed, err := s.backups.Create()
+		if err != nil {
+			log.Printf("Failed to create snapshot: %v", err)
+			// Retry later rather than immediately
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(snapshotRetry):
+			}
+			continue
+		}
+		log.Printf("Snapshot written to %s", snapshot.Path)
+		for _, name := range removed {
+			log.Printf("Removed old snapshot %s", name)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/products"
+)
+
+type ParseNutritionLabelParams struct {
+	Text string `json:"text"`
+	Name string `json:"name,omitempty"`
+}
+
+type DeleteCustomFoodParams struct {
+	Food string `json:"food"`
+}
+
+// ParsedLabel is the result of parse_nutrition_label: the label values and,
+// when a name was given, the custom food they were saved as.
+type ParsedLabel struct {
+	Label      *models.NutritionLabel `json:"label"`
+	CustomFood *models.CustomFood     `json:"custom_food,omitempty"`
+}
+
+func customFoodTools() []Tool {
+	return []Tool{
+		{
+			Name:        "parse_nutrition_label",
+			Description: "Read the serving size, servings per container and the carbs, fiber, sugars, protein and fat from pasted or OCR'd nutrition label text (US Nutrition Facts or EU per 100 g / per serving tables), without AI. Give a name to save it as a custom food that log_meal can log",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text of the nutrition facts panel",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Save the label as a custom food with this name, replacing one with the same name",
+					},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "list_custom_foods",
+			Description: "List the custom foods saved from nutrition labels",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "delete_custom_food",
+			Description: "Delete a custom food",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"food": map[string]interface{}{
+						"type":        "string",
+						"description": "Name or ID of the custom food",
+					},
+				},
+				"required": []string{"food"},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) parseNutritionLabel(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p ParseNutritionLabelParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if strings.TrimSpace(p.Text) == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+
+	label, err := products.ParseLabel(p.Text)
+	if err != nil {
+		return nil, err
+	}
+	result := &ParsedLabel{Label: label}
+	name := strings.TrimSpace(p.Name)
+	if name == "" {
+		return result, nil
+	}
+
+	profileID := profileFromContext(ctx)
+	now := time.Now().UTC()
+	food, err := s.findCustomFood(profileID, name)
+	if err != nil {
+		return nil, err
+	}
+	if food == nil {
+		food = &models.CustomFood{
+			ID:        fmt.Sprintf("food_%d", time.Now().UnixNano()),
+			ProfileID: profileID,
+			CreatedAt: now,
+		}
+	}
+	food.Name = name
+	food.NutritionLabel = *label
+	food.UpdatedAt = now
+	if err := s.storage.SaveCustomFood(food); err != nil {
+		return nil, err
+	}
+	result.CustomFood = food
+	return result, nil
+}
+
+func (s *MealLogServer) listCustomFoods(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	foods, err := s.storage.ListCustomFoods(profileFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom foods: %w", err)
+	}
+	return map[string]interface{}{"custom_foods": foods}, nil
+}
+
+func (s *MealLogServer) deleteCustomFood(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p DeleteCustomFoodParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	profileID := profileFromContext(ctx)
+	food, err := s.customFood(profileID, p.Food)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.DeleteCustomFood(profileID, food.ID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"deleted": true,
+		"id":      food.ID,
+		"name":    food.Name,
+	}, nil
+}
+
+// findCustomFood returns a profile's custom food by ID or by name, ignoring
+// case, or nil when there is none. Names are encrypted, so the match is
+// made here rather than in SQL.
+func (s *MealLogServer) findCustomFood(profileID, ref string) (*models.CustomFood, error) {
+	foods, err := s.storage.ListCustomFoods(profileID)
+	if err != nil {
+		return nil, err
+	}
+	ref = strings.TrimSpace(ref)
+	for _, food := range foods {
+		if food.ID == ref || strings.EqualFold(food.Name, ref) {
+			return food, nil
+		}
+	}
+	return nil, nil
+}
+
+// customFood is findCustomFood for a food that must exist.
+func (s *MealLogServer) customFood(profileID, ref string) (*models.CustomFood, error) {
+	if strings.TrimSpace(ref) == "" {
+		return nil, fmt.Errorf("custom food name or ID is required")
+	}
+	food, err := s.findCustomFood(profileID, ref)
+	if err != nil {
+		return nil, err
+	}
+	if food == nil {
+		return nil, fmt.Errorf("no custom food %q; save one with parse_nutrition_label and a name", ref)
+	}
+	return food, nil
+}
+
+// logCustomFood logs a custom food from its label values. Without grams or
+// servings it is one serving.
+func (s *MealLogServer) logCustomFood(ctx context.Context, p LogMealParams, timestamp time.Time) (interface{}, error) {
+	food, err := s.customFood(profileFromContext(ctx), p.CustomFood)
+	if err != nil {
+		return nil, err
+	}
+	if p.Grams < 0 || p.Servings < 0 {
+		return nil, fmt.Errorf("amount cannot be negative")
+	}
+
+	servings := p.Servings
+	if p.Grams == 0 && servings == 0 {
+		servings = 1
+	}
+	carbs, ok := food.Carbs(p.Grams, servings)
+	if !ok && p.Grams > 0 {
+		return nil, fmt.Errorf("the serving size of %s is unknown; give the amount in servings", food.Name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("the serving size of %s is unknown; give the amount in grams", food.Name)
+	}
+
+	quantity := fmt.Sprintf("%g g", p.Grams)
+	if p.Grams == 0 {
+		unit := "servings"
+		if servings == 1 {
+			unit = "serving"
+		}
+		quantity = fmt.Sprintf("%g %s", servings, unit)
+		if food.ServingGrams > 0 {
+			quantity += fmt.Sprintf(" (%g g)", roundCarbs(servings*food.ServingGrams))
+		}
+	}
+	var carbsPer100g float64
+	if food.Per100g != nil {
+		carbsPer100g = food.Per100g.Carbs
+	}
+	return s.logLabelledFood(ctx, p, timestamp, models.Food{
+		Name:           food.Name,
+		Quantity:       quantity,
+		CarbsPer100g:   carbsPer100g,
+		EstimatedCarbs: roundCarbs(carbs),
+		Confidence:     models.HighConfidence,
+	}, "custom_food")
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Elicitation lets a tool ask the user for information in the middle of a
+// call. The server sends an elicitation/create request on the call's
+// response stream and waits for the client to post the answer.
+
+// elicitationTimeout bounds how long a tool call waits for the user.
+const elicitationTimeout = 10 * time.Minute
+
+// errElicitationUnavailable means the client cannot be asked, because it
+// did not declare the elicitation capability or does not accept a
+// streamed response.
+var errElicitationUnavailable = errors.New("client does not support elicitation")
+
+// ElicitResult is the client's answer to elicitation/create. Action is
+// "accept", "decline" or "cancel"; Content holds the form's values when the
+// user accepted.
+type ElicitResult struct {
+	Action  string                 `json:"action"`
+	Content map[string]interface{} `json:"content,omitempty"`
+}
+
+// elicit asks the user to fill in a form described by schema, a flat JSON
+// schema object, and waits for the answer.
+func (s *MealLogServer) elicit(ctx context.Context, message string, schema map[string]interface{}) (*ElicitResult, error) {
+	sess := sessionFromContext(ctx)
+	out := responseStreamFromContext(ctx)
+	if sess == nil || out == nil || !sess.supports("elicitation") {
+		return nil, errElicitationUnavailable
+	}
+
+	id, replies := sess.expect()
+	defer sess.forget(id)
+	err := out.send(MCPRequest{
+		Jsonrpc: "2.0",
+		ID:      id,
+		Method:  "elicitation/create",
+		Params: map[string]interface{}{
+			"message":         message,
+			"requestedSchema": schema,
+		},
+	})
+	if errors.Is(err, errStreamUnsupported) {
+		return nil, errElicitationUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to send elicitation request: %w", err)
+	}
+
+	timer := time.NewTimer(elicitationTimeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replies:
+		if reply.Error != nil {
+			return nil, fmt.Errorf("elicitation failed: %s", reply.Error.Message)
+		}
+		var result ElicitResult
+		if err := json.Unmarshal(reply.Result, &result); err != nil {
+			return nil, fmt.Errorf("invalid elicitation result: %w", err)
+		}
+		return &result, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no answer to elicitation within %s", elicitationTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// askClarifications puts the clarifying questions about a meal to the user
+// and returns the description with their answers appended. ok is false
+// when the user could not be asked or did not answer, in which case the
+// questions go back to the model instead.
+func (s *MealLogServer) askClarifications(ctx context.Context, description string, questions []string) (answered string, ok bool, err error) {
+	subject := "\"" + description + "\""
+	if description == "" {
+		description = "Meal in the photo"
+		subject = "the meal in the photo"
+	}
+	result, err := s.elicit(ctx, "A few details would make the carb estimate for "+subject+" more accurate.", clarificationSchema(questions))
+	if ctx.Err() != nil {
+		return "", false, ctx.Err()
+	}
+	if err != nil {
+		if !errors.Is(err, errElicitationUnavailable) {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return "", false, nil
+	}
+	if result.Action != "accept" {
+		return "", false, nil
+	}
+
+	var answers []string
+	for i, question := range questions {
+		value, ok := result.Content[clarificationKey(i)]
+		if !ok || strings.TrimSpace(fmt.Sprint(value)) == "" {
+			continue
+		}
+		answers = append(answers, fmt.Sprintf("%s %v", question, value))
+	}
+	if len(answers) == 0 {
+		return "", false, nil
+	}
+	return description + " (" + strings.Join(answers, "; ") + ")", true, nil
+}
+
+func clarificationKey(i int) string {
+	return fmt.Sprintf("q%d", i+1)
+}
+
+// clarificationSchema builds a form with one optional field per question.
+func clarificationSchema(questions []string) map[string]interface{} {
+	properties := make(map[string]interface{}, len(questions))
+	for i, question := range questions {
+		properties[clarificationKey(i)] = clarificationField(question)
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+var (
+	optionList    = regexp.MustCompile(`\(([^()]+)\)`)
+	optionSplit   = regexp.MustCompile(`\s*,\s*(?:or\s+)?|\s+or\s+`)
+	sizeQuestion  = regexp.MustCompile(`(?i)\b(size|small|medium|large)\b`)
+	gramsQuestion = regexp.MustCompile(`(?i)\b(grams?|weigh|weight|weighed)\b`)
+	countQuestion = regexp.MustCompile(`(?i)\bhow many\b`)
+)
+
+// clarificationField picks a form field for a question: a choice when it
+// lists options, such as "What size was the potato (small, medium,
+// large)?", a number for weights and counts, and free text otherwise.
+func clarificationField(question string) map[string]interface{} {
+	field := map[string]interface{}{"title": question}
+	switch options := questionOptions(question); {
+	case len(options) > 0:
+		field["type"] = "string"
+		field["enum"] = options
+	case sizeQuestion.MatchString(question):
+		field["type"] = "string"
+		field["enum"] = []string{"small", "medium", "large"}
+	case gramsQuestion.MatchString(question):
+		field["type"] = "number"
+		field["description"] = "Grams"
+		field["minimum"] = 0
+	case countQuestion.MatchString(question):
+		field["type"] = "integer"
+		field["minimum"] = 0
+	default:
+		field["type"] = "string"
+	}
+	return field
+}
+
+// questionOptions returns the choices listed in parentheses in a question,
+// or nil when the parentheses hold something else.
+func questionOptions(question string) []string {
+	m := optionList.FindStringSubmatch(question)
+	if m == nil {
+		return nil
+	}
+	// Examples ("e.g. rice, pasta") are not a complete list
+	inner := strings.TrimSpace(m[1])
+	if strings.HasPrefix(inner, "e.g.") {
+		return nil
+	}
+	parts := optionSplit.Split(inner, -1)
+	if len(parts) < 2 || len(parts) > 8 {
+		return nil
+	}
+	options := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" || len(strings.Fields(part)) > 3 {
+			return nil
+		}
+		options = append(options, part)
+	}
+	return options
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+// maxExportRows caps an export made through MCP, which is returned in one
+// response; the meal-log export command streams any number of rows.
+const maxExportRows = 5000
+
+var errExportTooLarge = errors.New("export too large")
+
+type ExportMealsParams struct {
+	Format         string `json:"format,omitempty"`
+	Rows           string `json:"rows,omitempty"`
+	StartDate      string `json:"start_date,omitempty"`
+	EndDate        string `json:"end_date,omitempty"`
+	IncludeDeleted bool   `json:"include_deleted,omitempty"`
+}
+
+func exportTools() []Tool {
+	return []Tool{
+		{
+			Name:        "export_meals",
+			Description: fmt.Sprintf("Export meals over a date range as CSV, JSON or NDJSON, with one row per meal or per food. Timestamps are in the profile's timezone. Exports are limited to %d rows; use the 'meal-log export' command for larger ones", maxExportRows),
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"csv", "json", "ndjson"},
+						"description": "Output format (default csv)",
+					},
+					"rows": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"meal", "food"},
+						"description": "One row per meal, with foods as a JSON array, or one row per food (default meal)",
+					},
+					"start_date": map[string]interface{}{
+						"type":        "string",
+						"description": "Start date (YYYY-MM-DD)",
+					},
+					"end_date": map[string]interface{}{
+						"type":        "string",
+						"description": "End date (YYYY-MM-DD)",
+					},
+					"include_deleted": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include meals in the trash",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) exportMeals(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p ExportMealsParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	profileID := profileFromContext(ctx)
+	settings, err := s.storage.GetSettings(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.Options{
+		Format:   export.Format(p.Format),
+		Rows:     export.Rows(p.Rows),
+		Location: loc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query := storage.MealQuery{
+		StartDate:      p.StartDate,
+		EndDate:        p.EndDate,
+		IncludeDeleted: p.IncludeDeleted,
+	}
+	err = s.storage.StreamMeals(profileID, query, func(meal *models.Meal) error {
+		if w.Count() >= maxExportRows {
+			return errExportTooLarge
+		}
+		return w.WriteMeal(meal)
+	})
+	if errors.Is(err, errExportTooLarge) {
+		return nil, fmt.Errorf("the export has more than %d rows; choose a shorter date range or use the 'meal-log export' command", maxExportRows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export meals: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to export meals: %w", err)
+	}
+
+	return textResult(buf.String()), nil
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+func TestExportMealsRowLimit(t *testing.T) {
+	s := newTestServer(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	meals := make([]*models.Meal, maxExportRows+1)
+	for i := range meals {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		meals[i] = &models.Meal{
+			ID: fmt.Sprintf("meal-%05d", i), ProfileID: models.DefaultProfileID, Description: "apple",
+			Timestamp: ts, TotalCarbs: 15, Confidence: models.HighConfidence, CreatedAt: ts, UpdatedAt: ts, Source: "manual",
+		}
+	}
+	if err := s.storage.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+	token := newTestToken(t, s, "")
+
+	out, ok := callTool(t, s, token, "", "export_meals", nil)
+	if ok || !strings.Contains(out, "meal-log export") {
+		t.Errorf("export of %d meals = %.200s", len(meals), out)
+	}
+
+	// The meals of the last day, 2026-07-28, are left out of this range
+	out, ok = callTool(t, s, token, "", "export_meals", map[string]interface{}{"end_date": "2026-07-27"})
+	if !ok || !strings.Contains(out, "meal-04991") || strings.Contains(out, "meal-04992") {
+		t.Errorf("export of a shorter range = %.200s", out)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+)
+
+type GetMealHistoryParams struct {
+	MealID string `json:"meal_id"`
+}
+
+type RestoreMealRevisionParams struct {
+	MealID     string `json:"meal_id"`
+	RevisionID int64  `json:"revision_id"`
+}
+
+func historyTools() []Tool {
+	return []Tool{
+		{
+			Name:        "get_meal_history",
+			Description: "List every recorded change to a meal: who made it, with which tool, and the meal before and after. Use restore_meal_revision to go back to one of them",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal",
+					},
+				},
+				"required": []string{"meal_id"},
+			},
+		},
+		{
+			Name:        "restore_meal_revision",
+			Description: "Restore a meal to the state recorded by one of its revisions, re-creating it if it was deleted",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal",
+					},
+					"revision_id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Revision to restore, from get_meal_history",
+					},
+				},
+				"required": []string{"meal_id", "revision_id"},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) getMealHistory(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p GetMealHistoryParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" {
+		return nil, fmt.Errorf("meal_id is required")
+	}
+
+	revisions, err := s.storage.GetMealHistory(profileFromContext(ctx), p.MealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meal history: %w", err)
+	}
+
+	return map[string]interface{}{
+		"meal_id":   p.MealID,
+		"revisions": revisions,
+	}, nil
+}
+
+func (s *MealLogServer) restoreMealRevision(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p RestoreMealRevisionParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" || p.RevisionID <= 0 {
+		return nil, fmt.Errorf("meal_id and revision_id are required")
+	}
+
+	profileID := profileFromContext(ctx)
+	before, _ := s.storage.GetMeal(profileID, p.MealID)
+	meal, err := s.storage.RestoreMealRevision(profileID, p.MealID, p.RevisionID,
+		changeSource(ctx, "restore_meal_revision", "manual"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore revision: %w", err)
+	}
+	s.mealsChanged(profileID, before, meal)
+
+	return map[string]interface{}{
+		"restored_revision": p.RevisionID,
+		"meal":              meal,
+	}, nil
+}
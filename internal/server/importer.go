@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mcp-meal-log/internal/export"
+	"mcp-meal-log/internal/importer"
+)
+
+type ImportMealsParams struct {
+	Data             string            `json:"data"`
+	Source           string            `json:"source,omitempty"`
+	Format           string            `json:"format,omitempty"`
+	Mapping          map[string]string `json:"mapping,omitempty"`
+	TimestampLayouts []string          `json:"timestamp_layouts,omitempty"`
+	DryRun           bool              `json:"dry_run,omitempty"`
+}
+
+func importTools() []Tool {
+	return []Tool{
+		{
+			Name:        "import_meals",
+			Description: "Import meals from CSV, JSON or NDJSON in the export_meals format, or from a MyFitnessPal, Cronometer or Nightscout export. Meals with the same description and timestamp as an existing meal are skipped. Run with dry_run first to see what would be inserted",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"data": map[string]interface{}{
+						"type":        "string",
+						"description": "File contents to import",
+					},
+					"source": map[string]interface{}{
+						"type":        "string",
+						"enum":        importer.Sources,
+						"description": "Tracker the data comes from: a MyFitnessPal Nutrition Summary or diary CSV, a Cronometer servings.csv or Nightscout treatments JSON. Omit for the export_meals format",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"csv", "json", "ndjson"},
+						"description": "Input format when no source is given (default csv)",
+					},
+					"mapping": map[string]interface{}{
+						"type":        "object",
+						"description": "Column holding each field when it differs from the export name, e.g. {\"description\": \"Meal\", \"timestamp\": \"Date\"}. Fields: " + strings.Join(importer.Fields, ", "),
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"timestamp_layouts": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Extra Go time layouts to try, e.g. \"02.01.2006 15:04\". Timestamps without a zone are in the profile's timezone",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Report what would be inserted or skipped without saving",
+					},
+				},
+				"required": []string{"data"},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) importMeals(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p ImportMealsParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.Data == "" {
+		return nil, fmt.Errorf("data is required")
+	}
+
+	profileID := profileFromContext(ctx)
+	settings, err := s.storage.GetSettings(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+
+	origin := "import"
+	if p.Source != "" {
+		origin = p.Source
+	}
+
+	store := importStore{Store: s.storage, server: s, profileID: profileID}
+	report, err := importer.Import(store, strings.NewReader(p.Data), importer.Options{
+		ProfileID:        profileID,
+		Source:           p.Source,
+		Format:           export.Format(p.Format),
+		Mapping:          p.Mapping,
+		TimestampLayouts: p.TimestampLayouts,
+		Location:         loc,
+		DryRun:           p.DryRun,
+		Change:           changeSource(ctx, "import_meals", origin),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import meals: %w", err)
+	}
+	return report, nil
+}
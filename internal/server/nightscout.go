@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+// Nightscout treatments API. Looping apps can follow meals logged here the
+// same way they follow carbs entered in Nightscout. The endpoint is read
+// only; each meal is reported as a carb correction.
+const (
+	treatmentsPath      = "/api/v1/treatments"
+	carbCorrectionEvent = "Carb Correction"
+	// defaultTreatmentCount matches Nightscout's default page size.
+	defaultTreatmentCount = 10
+	// nightscoutTimeFormat is how Nightscout writes created_at.
+	nightscoutTimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+type Treatment struct {
+	ID        string  `json:"_id"`
+	EventType string  `json:"eventType"`
+	CreatedAt string  `json:"created_at"`
+	Date      int64   `json:"date"`
+	Mills     int64   `json:"mills"`
+	UTCOffset int     `json:"utcOffset"`
+	Carbs     float64 `json:"carbs"`
+	Notes     string  `json:"notes,omitempty"`
+	EnteredBy string  `json:"enteredBy"`
+}
+
+// findParam matches Nightscout query filters such as find[created_at][$gte].
+var findParam = regexp.MustCompile(`^find\[([^\]]+)\](?:\[\$([a-z]+)\])?$`)
+
+// handleTreatments serves GET /api/v1/treatments.json. Nightscout clients
+// can pass the API token in the token query parameter, since many cannot
+// set an Authorization header.
+func (s *MealLogServer) handleTreatments(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w, r)
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Treatments are read only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	credential := auth.BearerToken(r)
+	if credential == "" {
+		credential = r.URL.Query().Get("token")
+	}
+	ident, err := s.authenticateCredential(credential)
+	if err != nil {
+		s.sendUnauthorized(w, r, err)
+		return
+	}
+
+	profileID, err := s.resolveProfile(r, nil, ident)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	query, carbsOnly, err := treatmentQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	treatments := []Treatment{}
+	if carbsOnly {
+		settings, err := s.storage.GetSettings(profileID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		loc, err := settings.Location()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		page, err := s.storage.GetMeals(profileID, query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, meal := range page.Meals {
+			treatments = append(treatments, mealTreatment(meal, loc))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(treatments)
+}
+
+// treatmentQuery turns Nightscout find and count parameters into a meal
+// query. carbsOnly is false when the filters ask for an event type that
+// meals never produce. Filters on other fields are ignored.
+func treatmentQuery(r *http.Request) (query storage.MealQuery, carbsOnly bool, err error) {
+	params := r.URL.Query()
+	query.Limit = defaultTreatmentCount
+	carbsOnly = true
+
+	if count := params.Get("count"); count != "" {
+		if query.Limit, err = strconv.Atoi(count); err != nil || query.Limit <= 0 {
+			return query, false, fmt.Errorf("invalid count: %s", count)
+		}
+		if query.Limit > maxMealsPerPage {
+			query.Limit = maxMealsPerPage
+		}
+	}
+
+	for key, values := range params {
+		m := findParam.FindStringSubmatch(key)
+		if m == nil || len(values) == 0 {
+			continue
+		}
+		field, op, value := m[1], m[2], values[0]
+
+		switch field {
+		case "eventType":
+			if op == "" && value != carbCorrectionEvent {
+				carbsOnly = false
+			}
+		case "created_at", "date", "mills":
+			t, err := treatmentTime(field, value)
+			if err != nil {
+				return query, false, err
+			}
+			if err := applyTimeFilter(&query, op, t); err != nil {
+				return query, false, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return query, carbsOnly, nil
+}
+
+// treatmentTime parses a filter value: ISO 8601 for created_at, epoch
+// milliseconds for date and mills.
+func treatmentTime(field, value string) (time.Time, error) {
+	if field != "created_at" {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s: %s", field, value)
+		}
+		return time.UnixMilli(ms), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid created_at: %s", value)
+}
+
+// applyTimeFilter narrows the query's half-open [From, To) range. Meal
+// timestamps have at most nanosecond precision, so exclusive bounds on the
+// other side are moved by a nanosecond.
+func applyTimeFilter(query *storage.MealQuery, op string, t time.Time) error {
+	switch op {
+	case "gte":
+		query.From = later(query.From, t)
+	case "gt":
+		query.From = later(query.From, t.Add(time.Nanosecond))
+	case "lt":
+		query.To = earlier(query.To, t)
+	case "lte":
+		query.To = earlier(query.To, t.Add(time.Nanosecond))
+	case "", "eq":
+		query.From = later(query.From, t)
+		query.To = earlier(query.To, t.Add(time.Nanosecond))
+	default:
+		return fmt.Errorf("unsupported operator $%s", op)
+	}
+	return nil
+}
+
+func later(current, t time.Time) time.Time {
+	if current.IsZero() || t.After(current) {
+		return t
+	}
+	return current
+}
+
+func earlier(current, t time.Time) time.Time {
+	if current.IsZero() || t.Before(current) {
+		return t
+	}
+	return current
+}
+
+func mealTreatment(meal *models.Meal, loc *time.Location) Treatment {
+	_, offset := meal.Timestamp.In(loc).Zone()
+	ms := meal.Timestamp.UnixMilli()
+	return Treatment{
+		ID:        meal.ID,
+		EventType: carbCorrectionEvent,
+		CreatedAt: meal.Timestamp.UTC().Format(nightscoutTimeFormat),
+		Date:      ms,
+		Mills:     ms,
+		UTCOffset: offset / 60,
+		Carbs:     meal.TotalCarbs,
+		Notes:     meal.Description,
+		EnteredBy: "meal-log",
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreatmentQueryCount(t *testing.T) {
+	tests := []struct {
+		url   string
+		limit int
+		ok    bool
+	}{
+		{"/api/v1/treatments", defaultTreatmentCount, true},
+		{"/api/v1/treatments?count=5", 5, true},
+		{"/api/v1/treatments?count=100000000", maxMealsPerPage, true},
+		{"/api/v1/treatments?count=0", 0, false},
+		{"/api/v1/treatments?count=lots", 0, false},
+	}
+	for _, tt := range tests {
+		query, _, err := treatmentQuery(httptest.NewRequest("GET", tt.url, nil))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v", tt.url, err)
+			continue
+		}
+		if tt.ok && query.Limit != tt.limit {
+			t.Errorf("%s: limit = %d, want %d", tt.url, query.Limit, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+const (
+	// maxImageBytes caps the size of a meal photo.
+	maxImageBytes = 10 << 20
+	// thumbnailSize is the longest side of the thumbnail kept with a meal.
+	thumbnailSize = 320
+)
+
+// imageTypes are the photo formats vision models accept.
+var imageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type GetMealPhotoParams struct {
+	MealID string `json:"meal_id"`
+}
+
+func photoTools() []Tool {
+	return []Tool{
+		{
+			Name:        "get_meal_photo",
+			Description: "Show the thumbnail of the photo a meal was logged from",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal",
+					},
+				},
+				"required": []string{"meal_id"},
+			},
+		},
+	}
+}
+
+// loadImage reads the photo passed as base64 data or a server path. It
+// returns nil when neither is set.
+func loadImage(ctx context.Context, data, path string) (*models.MealImage, error) {
+	var raw []byte
+	switch {
+	case data != "" && path != "":
+		return nil, fmt.Errorf("pass either image or image_path, not both")
+	case data != "":
+		if _, encoded, ok := strings.Cut(data, ";base64,"); ok && strings.HasPrefix(data, "data:") {
+			data = encoded
+		}
+		var err error
+		if raw, err = base64.StdEncoding.DecodeString(data); err != nil {
+			return nil, fmt.Errorf("image is not valid base64: %w", err)
+		}
+	case path != "":
+		// Any file the server can read would be reachable otherwise
+		if !identityFromContext(ctx).admin() {
+			return nil, fmt.Errorf("image_path requires an authenticated token that is not limited to one profile")
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image: %w", err)
+		}
+		defer f.Close()
+		if raw, err = io.ReadAll(io.LimitReader(f, maxImageBytes+1)); err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+
+	if len(raw) > maxImageBytes {
+		return nil, fmt.Errorf("image is larger than %d MB", maxImageBytes>>20)
+	}
+	mimeType := http.DetectContentType(raw)
+	if !imageTypes[mimeType] {
+		return nil, fmt.Errorf("unsupported image type %s; use JPEG, PNG, GIF or WebP", mimeType)
+	}
+	return &models.MealImage{Data: raw, MimeType: mimeType}, nil
+}
+
+// storedImage returns the thumbnail kept of a meal's photo, for estimating
+// its carbs again, or nil when the meal was not logged from a photo. The
+// original photo is not kept, so see thumbnailEstimate.
+func (s *MealLogServer) storedImage(meal *models.Meal) (*models.MealImage, error) {
+	if meal.PhotoSHA256 == "" {
+		return nil, nil
+	}
+	photo, err := s.storage.GetMealPhoto(meal.ProfileID, meal.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("meal %s was logged from a photo that was not kept, so it cannot be recalculated; update its foods instead", meal.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.MealImage{Data: photo.Thumbnail, MimeType: photo.MimeType}, nil
+}
+
+// thumbnailEstimate marks an estimate made from a thumbnail as low
+// confidence: portions are hard to judge at thumbnailSize pixels.
+func thumbnailEstimate(resp *models.CarbCalculationResponse) {
+	resp.Confidence = models.LowConfidence
+	for i := range resp.Foods {
+		resp.Foods[i].Confidence = models.LowConfidence
+	}
+}
+
+// newMealPhoto hashes a photo and makes the JPEG thumbnail stored with the
+// meal. Formats the standard library cannot decode, such as WebP, keep
+// only the hash.
+func newMealPhoto(img *models.MealImage) *models.MealPhoto {
+	sum := sha256.Sum256(img.Data)
+	photo := &models.MealPhoto{
+		SHA256:   hex.EncodeToString(sum[:]),
+		MimeType: "image/jpeg",
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return photo
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail(decoded, thumbnailSize), &jpeg.Options{Quality: 75}); err != nil {
+		return photo
+	}
+	photo.Thumbnail = buf.Bytes()
+	return photo
+}
+
+// thumbnail scales an image down so its longest side is at most size,
+// averaging the pixels each thumbnail pixel covers.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+func (s *MealLogServer) getMealPhoto(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p GetMealPhotoParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" {
+		return nil, fmt.Errorf("meal_id is required")
+	}
+
+	photo, err := s.storage.GetMealPhoto(profileFromContext(ctx), p.MealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meal photo: %w", err)
+	}
+
+	return contentResult{
+		{
+			"type":     "image",
+			"data":     base64.StdEncoding.EncodeToString(photo.Thumbnail),
+			"mimeType": photo.MimeType,
+		},
+		{
+			"type": "text",
+			"text": "Photo SHA-256: " + photo.SHA256,
+		},
+	}, nil
+}
+
+// photoDescription names a meal logged from a photo alone after the foods
+// found in it.
+func photoDescription(foods []models.Food) string {
+	names := make([]string, 0, len(foods))
+	for _, food := range foods {
+		names = append(names, food.Name)
+	}
+	if len(names) == 0 {
+		return "Meal from photo"
+	}
+	return "Photo: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+func TestStoredImage(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	newMeal := func(id, sha string, photo *models.MealPhoto) *models.Meal {
+		meal := &models.Meal{
+			ID: id, ProfileID: models.DefaultProfileID, Description: "pasta", Timestamp: now,
+			Confidence: models.MediumConfidence, CreatedAt: now, UpdatedAt: now, Source: "ai_parsed",
+			PhotoSHA256: sha, Photo: photo,
+		}
+		if err := s.storage.SaveMeal(meal, testChange); err != nil {
+			t.Fatal(err)
+		}
+		return meal
+	}
+
+	thumbnail := []byte{0xff, 0xd8, 0xff, 0xe0}
+	withPhoto := newMeal("with-photo", "abc", &models.MealPhoto{SHA256: "abc", MimeType: "image/jpeg", Thumbnail: thumbnail})
+	img, err := s.storedImage(withPhoto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img == nil || !bytes.Equal(img.Data, thumbnail) || img.MimeType != "image/jpeg" {
+		t.Errorf("storedImage = %+v", img)
+	}
+
+	// WebP photos and imported meals keep only the hash
+	if _, err := s.storedImage(newMeal("hash-only", "def", nil)); err == nil {
+		t.Error("storedImage of a meal whose photo was not kept succeeded")
+	}
+
+	if img, err := s.storedImage(newMeal("no-photo", "", nil)); img != nil || err != nil {
+		t.Errorf("storedImage of a meal without a photo = %v, %v", img, err)
+	}
+}
+
+func TestThumbnailEstimate(t *testing.T) {
+	resp := &models.CarbCalculationResponse{
+		Foods: []models.Food{
+			{Name: "pasta", EstimatedCarbs: 70, Confidence: models.HighConfidence},
+			{Name: "sauce", EstimatedCarbs: 8, Confidence: models.MediumConfidence},
+		},
+		TotalCarbs: 78,
+		Confidence: models.HighConfidence,
+	}
+	thumbnailEstimate(resp)
+	if resp.Confidence != models.LowConfidence {
+		t.Errorf("confidence = %s, want low", resp.Confidence)
+	}
+	for _, food := range resp.Foods {
+		if food.Confidence != models.LowConfidence {
+			t.Errorf("%s confidence = %s, want low", food.Name, food.Confidence)
+		}
+	}
+	if resp.TotalCarbs != 78 {
+		t.Errorf("total carbs = %g, want it unchanged", resp.TotalCarbs)
+	}
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/products"
+	"mcp-meal-log/internal/storage"
+)
+
+type LookupBarcodeParams struct {
+	Barcode  string  `json:"barcode"`
+	Grams    float64 `json:"grams,omitempty"`
+	Servings float64 `json:"servings,omitempty"`
+}
+
+type AddProductParams struct {
+	Barcode         string   `json:"barcode"`
+	Name            string   `json:"name"`
+	Brand           string   `json:"brand,omitempty"`
+	CarbsPer100g    *float64 `json:"carbs_per_100g,omitempty"`
+	CarbsPerServing *float64 `json:"carbs_per_serving,omitempty"`
+	ServingSize     string   `json:"serving_size,omitempty"`
+	ServingGrams    float64  `json:"serving_grams,omitempty"`
+	PackageGrams    float64  `json:"package_grams,omitempty"`
+}
+
+// ProductLookup is a product with its label values worked out per serving
+// and per package, and for the requested amount.
+type ProductLookup struct {
+	Product            *models.Product `json:"product"`
+	ServingsPerPackage float64         `json:"servings_per_package,omitempty"`
+	CarbsPerServing    float64         `json:"carbs_per_serving,omitempty"`
+	CarbsPerPackage    float64         `json:"carbs_per_package,omitempty"`
+	Portion            *ProductPortion `json:"portion,omitempty"`
+}
+
+type ProductPortion struct {
+	Grams float64 `json:"grams"`
+	Carbs float64 `json:"carbs"`
+}
+
+func productTools() []Tool {
+	return []Tool{
+		{
+			Name:        "lookup_barcode",
+			Description: "Look up a packaged product by its UPC or EAN barcode and work out its carbs per serving, per package and for an amount",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"barcode": map[string]interface{}{
+						"type":        "string",
+						"description": "UPC-A, EAN-8, EAN-13 or GTIN-14 code",
+					},
+					"grams": map[string]interface{}{
+						"type":        "number",
+						"description": "Amount to work out the carbs for, in grams",
+					},
+					"servings": map[string]interface{}{
+						"type":        "number",
+						"description": "Amount to work out the carbs for, in servings (may be fractional)",
+					},
+				},
+				"required": []string{"barcode"},
+			},
+		},
+		{
+			Name:        "add_product",
+			Description: "Add or correct a packaged product from the values on its nutrition label, e.g. when lookup_barcode does not know its barcode. Products are shared by every profile, so this is admin only",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"barcode": map[string]interface{}{
+						"type":        "string",
+						"description": "UPC-A, EAN-8, EAN-13 or GTIN-14 code",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Product name",
+					},
+					"brand": map[string]interface{}{
+						"type":        "string",
+						"description": "Brand",
+					},
+					"carbs_per_100g": map[string]interface{}{
+						"type":        "number",
+						"description": "Total carbohydrate per 100 g (or 100 ml)",
+					},
+					"carbs_per_serving": map[string]interface{}{
+						"type":        "number",
+						"description": "Total carbohydrate per serving, for labels without per 100 g values; needs serving_grams",
+					},
+					"serving_size": map[string]interface{}{
+						"type":        "string",
+						"description": "Serving as printed on the label, e.g. \"2 biscuits (25 g)\"",
+					},
+					"serving_grams": map[string]interface{}{
+						"type":        "number",
+						"description": "Serving size in grams (read from serving_size when omitted)",
+					},
+					"package_grams": map[string]interface{}{
+						"type":        "number",
+						"description": "Net weight of the package in grams",
+					},
+				},
+				"required": []string{"barcode", "name"},
+			},
+		},
+	}
+}
+
+// parseBarcode normalizes a barcode typed or scanned by the user and
+// rejects it when its check digit is wrong.
+func parseBarcode(code string) (string, error) {
+	barcode, err := models.NormalizeBarcode(code)
+	if err != nil {
+		return "", err
+	}
+	if !models.ValidBarcode(barcode) {
+		return "", fmt.Errorf("barcode %s has a wrong check digit; check for a typo", code)
+	}
+	return barcode, nil
+}
+
+// findProduct loads a product, explaining how to add it when it is unknown.
+func (s *MealLogServer) findProduct(code string) (*models.Product, error) {
+	barcode, err := parseBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.storage.GetProduct(barcode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("no product with barcode %s; add it with add_product using the values on its nutrition label", barcode)
+	}
+	return product, err
+}
+
+// portionGrams resolves the amount of a product eaten. Without grams or
+// servings it is one serving.
+func portionGrams(product *models.Product, grams, servings float64) (float64, string, error) {
+	switch {
+	case grams < 0 || servings < 0:
+		return 0, "", fmt.Errorf("amount cannot be negative")
+	case grams > 0:
+		return grams, fmt.Sprintf("%g g", grams), nil
+	case product.ServingGrams <= 0:
+		return 0, "", fmt.Errorf("the serving size of %s is unknown; give the amount in grams", product.DisplayName())
+	case servings == 0:
+		servings = 1
+	}
+	g := servings * product.ServingGrams
+	unit := "servings"
+	if servings == 1 {
+		unit = "serving"
+	}
+	return g, fmt.Sprintf("%g %s (%g g)", servings, unit, roundCarbs(g)), nil
+}
+
+// roundCarbs rounds to a tenth of a gram, as labels do.
+func roundCarbs(grams float64) float64 {
+	return math.Round(grams*10) / 10
+}
+
+func (s *MealLogServer) lookupBarcode(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p LookupBarcodeParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.Barcode == "" {
+		return nil, fmt.Errorf("barcode is required")
+	}
+
+	product, err := s.findProduct(p.Barcode)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := &ProductLookup{
+		Product:            product,
+		ServingsPerPackage: math.Round(product.ServingsPerPackage()*10) / 10,
+		CarbsPerServing:    roundCarbs(product.Carbs(product.ServingGrams)),
+		CarbsPerPackage:    roundCarbs(product.Carbs(product.PackageGrams)),
+	}
+	if p.Grams != 0 || p.Servings != 0 {
+		grams, _, err := portionGrams(product, p.Grams, p.Servings)
+		if err != nil {
+			return nil, err
+		}
+		lookup.Portion = &ProductPortion{Grams: grams, Carbs: roundCarbs(product.Carbs(grams))}
+	}
+	return lookup, nil
+}
+
+func (s *MealLogServer) addProduct(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p AddProductParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	// Every profile doses from the shared products, so one profile must not
+	// be able to change them for the others
+	if !identityFromContext(ctx).admin() {
+		return nil, fmt.Errorf("adding products requires an authenticated token that is not limited to one profile")
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	barcode, err := parseBarcode(p.Barcode)
+	if err != nil {
+		return nil, err
+	}
+
+	servingGrams := p.ServingGrams
+	if servingGrams == 0 {
+		servingGrams = products.ParseGrams(p.ServingSize)
+	}
+	if servingGrams < 0 || p.PackageGrams < 0 {
+		return nil, fmt.Errorf("serving and package sizes cannot be negative")
+	}
+
+	var carbsPer100g float64
+	switch {
+	case p.CarbsPer100g != nil:
+		carbsPer100g = *p.CarbsPer100g
+	case p.CarbsPerServing != nil:
+		if servingGrams <= 0 {
+			return nil, fmt.Errorf("carbs_per_serving needs the serving size in grams")
+		}
+		carbsPer100g = *p.CarbsPerServing * 100 / servingGrams
+	default:
+		return nil, fmt.Errorf("carbs_per_100g or carbs_per_serving is required")
+	}
+	if carbsPer100g < 0 || carbsPer100g > 100 {
+		return nil, fmt.Errorf("carbs per 100 g must be between 0 and 100, got %g", carbsPer100g)
+	}
+
+	product := &models.Product{
+		Barcode:      barcode,
+		Name:         p.Name,
+		Brand:        p.Brand,
+		CarbsPer100g: roundCarbs(carbsPer100g),
+		ServingSize:  p.ServingSize,
+		ServingGrams: servingGrams,
+		PackageGrams: p.PackageGrams,
+		Source:       models.ProductSourceManual,
+		UpdatedAt:    time.Now().UTC(),
+	}
+	if err := s.storage.SaveProducts([]*models.Product{product}); err != nil {
+		return nil, fmt.Errorf("failed to save product: %w", err)
+	}
+	return product, nil
+}
+
+// logProduct logs a packaged product from its barcode, using the label
+// values rather than an AI estimate.
+func (s *MealLogServer) logProduct(ctx context.Context, p LogMealParams, timestamp time.Time) (interface{}, error) {
+	product, err := s.findProduct(p.Barcode)
+	if err != nil {
+		return nil, err
+	}
+	grams, quantity, err := portionGrams(product, p.Grams, p.Servings)
+	if err != nil {
+		return nil, err
+	}
+	carbs := roundCarbs(product.Carbs(grams))
+	return s.logLabelledFood(ctx, p, timestamp, models.Food{
+		Name:           product.DisplayName(),
+		Quantity:       quantity,
+		CarbsPer100g:   product.CarbsPer100g,
+		EstimatedCarbs: carbs,
+		Confidence:     models.HighConfidence,
+	}, "barcode")
+}
+
+// logLabelledFood logs a meal of one food whose carbs come from its label.
+// The description defaults to the food's name.
+func (s *MealLogServer) logLabelledFood(ctx context.Context, p LogMealParams, timestamp time.Time, food models.Food, source string) (interface{}, error) {
+	reportProgress(ctx, 3, logMealStages, "Checking the label values")
+	description := p.Description
+	if description == "" {
+		description = food.Name
+	}
+	meal := &models.Meal{
+		ID:          fmt.Sprintf("meal_%d", time.Now().UnixNano()),
+		ProfileID:   profileFromContext(ctx),
+		Description: description,
+		Timestamp:   timestamp,
+		Slot:        p.Slot,
+		Foods:       []models.Food{food},
+		TotalCarbs:  food.EstimatedCarbs,
+		Confidence:  models.HighConfidence,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		Source:      source,
+	}
+	p.annotate(meal)
+	return s.saveLoggedMeal(ctx, meal, source)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+var profileIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type CreateProfileParams struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type SelectProfileParams struct {
+	ProfileID string `json:"profile_id"`
+}
+
+type UpdateSettingsParams struct {
+	Timezone     *string              `json:"timezone,omitempty"`
+	CarbRatios   map[string]float64   `json:"carb_ratios,omitempty"`
+	MealSchedule *[]models.SlotWindow `json:"meal_schedule,omitempty"`
+}
+
+func profileTools() []Tool {
+	return []Tool{
+		{
+			Name:        "list_profiles",
+			Description: "List the profiles stored on this server and which one is active",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "create_profile",
+			Description: "Create a new profile whose meals and settings are kept separate from other profiles",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Profile ID (lowercase letters, digits, '-' and '_'; derived from name if omitted)",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Display name of the profile",
+					},
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "IANA timezone used for daily totals (e.g. America/New_York)",
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{
+			Name:        "select_profile",
+			Description: "Switch the current session to another profile",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"profile_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the profile to use for the rest of this session",
+					},
+				},
+				"required": []string{"profile_id"},
+			},
+		},
+		{
+			Name:        "get_settings",
+			Description: "Get the active profile's settings such as timezone and insulin-to-carb ratios",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "update_settings",
+			Description: "Update the active profile's timezone, insulin-to-carb ratios and meal schedule",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "IANA timezone name; empty string uses the server's zone",
+					},
+					"carb_ratios": map[string]interface{}{
+						"type":        "object",
+						"description": "Grams of carbohydrate covered by one unit of insulin, keyed by meal slot (breakfast, lunch, dinner, snack, your own slots, default)",
+						"additionalProperties": map[string]interface{}{
+							"type": "number",
+						},
+					},
+					"meal_schedule": map[string]interface{}{
+						"type":        "array",
+						"description": "Local times of day that put meals in a slot; the first matching window wins and meals outside every window are snacks. Replaces the whole schedule; an empty list restores the default (breakfast 04:00-10:30, lunch 11:00-14:30, dinner 17:00-21:30). Meals whose slot came from the old schedule are reassigned",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"slot": map[string]interface{}{
+									"type":        "string",
+									"description": "Slot name, e.g. breakfast or pre-workout",
+								},
+								"start": map[string]interface{}{
+									"type":        "string",
+									"description": "Start time (HH:MM), inclusive",
+								},
+								"end": map[string]interface{}{
+									"type":        "string",
+									"description": "End time (HH:MM), exclusive; before start to wrap past midnight",
+								},
+							},
+							"required": []string{"slot", "start", "end"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) listProfiles(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	profiles, err := s.storage.ListProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	// Callers pinned to one profile must not learn about the others
+	if ident := identityFromContext(ctx); ident.ProfileID != "" {
+		visible := profiles[:0]
+		for _, profile := range profiles {
+			if profile.ID == ident.ProfileID {
+				visible = append(visible, profile)
+			}
+		}
+		profiles = visible
+	}
+
+	return map[string]interface{}{
+		"active_profile": profileFromContext(ctx),
+		"profiles":       profiles,
+	}, nil
+}
+
+func (s *MealLogServer) createProfile(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p CreateProfileParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if identityFromContext(ctx).ProfileID != "" {
+		return nil, fmt.Errorf("creating profiles requires a token that is not limited to one profile")
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("profile name is required")
+	}
+	if p.ID == "" {
+		p.ID = slugify(p.Name)
+	}
+	if !profileIDPattern.MatchString(p.ID) {
+		return nil, fmt.Errorf("invalid profile id %q", p.ID)
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", p.Timezone, err)
+		}
+	}
+
+	profile := &models.Profile{
+		ID:        p.ID,
+		Name:      p.Name,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.CreateProfile(profile); err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	if p.Timezone != "" {
+		settings := &models.ProfileSettings{
+			ProfileID: profile.ID,
+			Timezone:  p.Timezone,
+			UpdatedAt: time.Now(),
+		}
+		if err := s.storage.UpdateSettings(settings); err != nil {
+			return nil, fmt.Errorf("failed to store settings: %w", err)
+		}
+	}
+
+	return profile, nil
+}
+
+func (s *MealLogServer) selectProfile(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p SelectProfileParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return nil, fmt.Errorf("no session: send the %s header to choose a profile per request", profileHeader)
+	}
+
+	if ident := identityFromContext(ctx); ident.ProfileID != "" && ident.ProfileID != p.ProfileID {
+		return nil, fmt.Errorf("not authorized for profile: %s", p.ProfileID)
+	}
+
+	profile, err := s.storage.GetProfile(p.ProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select profile: %w", err)
+	}
+	sess.setProfile(profile.ID)
+
+	return profile, nil
+}
+
+func (s *MealLogServer) getSettings(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	settings, err := s.storage.GetSettings(profileFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *MealLogServer) updateSettings(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p UpdateSettingsParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	settings, err := s.storage.GetSettings(profileFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	if p.Timezone != nil {
+		if _, err := time.LoadLocation(*p.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", *p.Timezone, err)
+		}
+		settings.Timezone = *p.Timezone
+	}
+	if p.CarbRatios != nil {
+		for slot, ratio := range p.CarbRatios {
+			if ratio <= 0 {
+				return nil, fmt.Errorf("carb ratio for %s must be positive", slot)
+			}
+		}
+		settings.CarbRatios = p.CarbRatios
+	}
+	if p.MealSchedule != nil {
+		schedule := *p.MealSchedule
+		for i, w := range schedule {
+			if schedule[i].Slot, err = models.NormalizeSlot(w.Slot); err != nil {
+				return nil, err
+			}
+			if w.Start == w.End {
+				return nil, fmt.Errorf("meal schedule window for %s is empty", w.Slot)
+			}
+		}
+		settings.MealSchedule = schedule
+	}
+	settings.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateSettings(settings); err != nil {
+		return nil, fmt.Errorf("failed to update settings: %w", err)
+	}
+	return settings, nil
+}
+
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/models"
+)
+
+var testChange = models.ChangeSource{Tool: "test", Origin: "test", Actor: "test"}
+
+// newTestServer starts a server on a fresh SQLite database with token auth.
+func newTestServer(t *testing.T) *MealLogServer {
+	t.Helper()
+	t.Setenv(encryption.KeyEnvVar, "")
+	s, err := NewMealLogServer(&Config{
+		DBPath:   filepath.Join(t.TempDir(), "meals.db"),
+		AuthMode: AuthModeToken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.storage.Close() })
+	return s
+}
+
+// newTestToken stores an API token, pinned to profileID unless it is
+// empty, and returns its secret.
+func newTestToken(t *testing.T, s *MealLogServer, profileID string) string {
+	t.Helper()
+	id, secret, err := auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := &models.APIToken{ID: id, Name: "test", ProfileID: profileID, CreatedAt: time.Now()}
+	if err := s.storage.CreateToken(token, auth.HashToken(secret)); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// rpc posts one JSON-RPC request and decodes the response.
+func rpc(t *testing.T, s *MealLogServer, token, profile, method string, params interface{}) MCPResponse {
+	t.Helper()
+	body, err := json.Marshal(MCPRequest{Jsonrpc: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	if profile != "" {
+		r.Header.Set(profileHeader, profile)
+	}
+	w := httptest.NewRecorder()
+	s.handleMCP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: HTTP %d: %s", method, w.Code, w.Body)
+	}
+
+	var response MCPResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s: %v: %s", method, err, w.Body)
+	}
+	return response
+}
+
+// callTool calls a tool and returns its text output, or the error message
+// when the call failed.
+func callTool(t *testing.T, s *MealLogServer, token, profile, tool string, args map[string]interface{}) (string, bool) {
+	t.Helper()
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	response := rpc(t, s, token, profile, "tools/call", map[string]interface{}{"name": tool, "arguments": args})
+	if response.Error != nil {
+		return response.Error.Message, false
+	}
+	return formatJSON(response.Result), true
+}
+
+// Meals stored under one profile must not be visible to, or changeable by,
+// callers working under another, whether they pick the profile with the
+// header or hold a token pinned to it.
+func TestProfileIsolation(t *testing.T) {
+	s := newTestServer(t)
+	for _, id := range []string{"alice", "bob"} {
+		if err := s.storage.CreateProfile(&models.Profile{ID: id, Name: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timestamp := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	meal := &models.Meal{
+		ID:          "alice-secret-meal",
+		ProfileID:   "alice",
+		Description: "Alice's pancakes",
+		Timestamp:   timestamp,
+		Foods:       []models.Food{{Name: "pancake", Quantity: "3", EstimatedCarbs: 45, Confidence: models.HighConfidence}},
+		TotalCarbs:  45,
+		Confidence:  models.HighConfidence,
+		Tags:        []string{"weekend"},
+		CreatedAt:   timestamp,
+		UpdatedAt:   timestamp,
+		Source:      "manual",
+	}
+	if err := s.storage.SaveMeal(meal, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := newTestToken(t, s, "")
+	bobOnly := newTestToken(t, s, "bob")
+
+	// The admin token sees Alice's meal when it asks for her profile
+	if out, ok := callTool(t, s, admin, "alice", "get_meals", nil); !ok || !strings.Contains(out, "pancakes") {
+		t.Fatalf("get_meals as alice = %s", out)
+	}
+	for _, uri := range []string{"meal://" + meal.ID, "summary://week/2026-W27"} {
+		response := rpc(t, s, admin, "alice", "resources/read", map[string]interface{}{"uri": uri})
+		if out := formatJSON(response.Result); !strings.Contains(out, "pancake") {
+			t.Fatalf("resources/read %s as alice = %s", uri, formatJSON(response))
+		}
+	}
+
+	reads := []struct {
+		name string
+		tool string
+		args map[string]interface{}
+	}{
+		{"get_meals", "get_meals", nil},
+		{"search", "get_meals", map[string]interface{}{"query": "pancake"}},
+		{"tags", "get_meals", map[string]interface{}{"tags": []string{"weekend"}}},
+		{"list_tags", "list_tags", nil},
+		{"export", "export_meals", map[string]interface{}{"format": "json"}},
+		{"summary", "generate_report", map[string]interface{}{"start_date": "2026-07-01", "end_date": "2026-07-01"}},
+		{"history", "get_meal_history", map[string]interface{}{"meal_id": meal.ID}},
+		{"update", "update_meal", map[string]interface{}{"meal_id": meal.ID, "description": "stolen"}},
+		{"delete", "delete_meal", map[string]interface{}{"meal_id": meal.ID}},
+	}
+	callers := []struct {
+		name, token, profile string
+	}{
+		{"admin as bob", admin, "bob"},
+		{"bob's token", bobOnly, ""},
+	}
+	for _, caller := range callers {
+		for _, tt := range reads {
+			t.Run(caller.name+"/"+tt.name, func(t *testing.T) {
+				out, _ := callTool(t, s, caller.token, caller.profile, tt.tool, tt.args)
+				if strings.Contains(out, "pancake") || strings.Contains(out, "weekend") {
+					t.Errorf("%s leaked Alice's meal: %s", tt.tool, out)
+				}
+			})
+		}
+
+		t.Run(caller.name+"/resources", func(t *testing.T) {
+			for _, uri := range []string{"meal://" + meal.ID, "meals://day/2026-07-01", "summary://week/2026-W27", "food://pancake"} {
+				response := rpc(t, s, caller.token, caller.profile, "resources/read", map[string]interface{}{"uri": uri})
+				// Errors echo the URI, so only results are checked
+				if out := formatJSON(response.Result); strings.Contains(out, "pancake") || strings.Contains(out, "Alice") {
+					t.Errorf("resources/read %s leaked Alice's meal: %s", uri, out)
+				}
+			}
+		})
+	}
+
+	// A token pinned to Bob cannot switch to Alice through the header
+	for _, tool := range []string{"get_meals", "get_meal_history", "export_meals"} {
+		out, ok := callTool(t, s, bobOnly, "alice", tool, map[string]interface{}{"meal_id": meal.ID})
+		if ok || !strings.Contains(out, "not authorized for profile") {
+			t.Errorf("%s with bob's token as alice = %s", tool, out)
+		}
+	}
+	response := rpc(t, s, bobOnly, "alice", "resources/read", map[string]interface{}{"uri": "meal://" + meal.ID})
+	if response.Error == nil || !strings.Contains(response.Error.Message, "not authorized for profile") {
+		t.Errorf("resources/read with bob's token as alice = %s", formatJSON(response))
+	}
+
+	// Nothing above changed Alice's meal
+	got, err := s.storage.GetMeal("alice", meal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != meal.Description || got.DeletedAt != nil {
+		t.Errorf("Alice's meal changed: %+v", got)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+)
+
+// Long tool calls report progress when the client sends a progress token
+// in the request's _meta. Notifications go out on the call's response
+// stream; clients that do not accept a stream get none.
+
+func withProgressToken(ctx context.Context, token interface{}) context.Context {
+	return context.WithValue(ctx, progressTokenKey, token)
+}
+
+// progressToken extracts _meta.progressToken from request parameters.
+func progressToken(params interface{}) interface{} {
+	paramsMap, _ := params.(map[string]interface{})
+	meta, _ := paramsMap["_meta"].(map[string]interface{})
+	return meta["progressToken"]
+}
+
+// cancelledRequestID extracts the requestId of notifications/cancelled.
+func cancelledRequestID(params interface{}) interface{} {
+	paramsMap, _ := params.(map[string]interface{})
+	return paramsMap["requestId"]
+}
+
+// reportProgress sends notifications/progress for the request in ctx.
+// progress must increase with every call.
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	token := ctx.Value(progressTokenKey)
+	out := responseStreamFromContext(ctx)
+	if token == nil || out == nil {
+		return
+	}
+	out.send(MCPNotification{
+		Jsonrpc: "2.0",
+		Method:  "notifications/progress",
+		Params: map[string]interface{}{
+			"progressToken": token,
+			"progress":      progress,
+			"total":         total,
+			"message":       message,
+		},
+	})
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
+)
+
+// Prompts are reusable instructions for common workflows. Each fills in
+// the active profile's meals so the assistant starts with the context it
+// needs.
+
+// promptRecentMeals is how many recent meals a prompt includes.
+const promptRecentMeals = 10
+
+// errInvalidPrompt is answered with the JSON-RPC invalid params code.
+var errInvalidPrompt = errors.New("invalid prompt request")
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+func prompts() []Prompt {
+	return []Prompt{
+		{
+			Name:        "log_meal_with_clarifications",
+			Description: "Log a meal, asking about portion sizes and preparation before saving it",
+			Arguments: []PromptArgument{
+				{Name: "description", Description: "What was eaten", Required: true},
+				{Name: "time", Description: "When it was eaten (RFC 3339; defaults to now)"},
+			},
+		},
+		{
+			Name:        "review_todays_carbs",
+			Description: "Review a day's meals and carb total and flag entries worth correcting",
+			Arguments: []PromptArgument{
+				{Name: "date", Description: "Local date to review (YYYY-MM-DD; defaults to today)"},
+			},
+		},
+		{
+			Name:        "weekly_pattern_review",
+			Description: "Look for patterns in a week of meals, such as heavy days, late meals and uncertain estimates",
+			Arguments: []PromptArgument{
+				{Name: "week", Description: "ISO week to review, e.g. 2024-W07 (defaults to this week)"},
+			},
+		},
+		{
+			Name:        "pre_bolus_check",
+			Description: "Estimate the carbs of a meal about to be eaten and the matching insulin from the profile's carb ratio",
+			Arguments: []PromptArgument{
+				{Name: "description", Description: "The meal about to be eaten", Required: true},
+				{Name: "carbs", Description: "Carbs in grams, if already known"},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) handlePromptsGet(ctx context.Context, params interface{}) (interface{}, error) {
+	var p GetPromptParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	var prompt *Prompt
+	for _, candidate := range prompts() {
+		if candidate.Name == p.Name {
+			prompt = &candidate
+			break
+		}
+	}
+	if prompt == nil {
+		return nil, fmt.Errorf("%w: unknown prompt %s", errInvalidPrompt, p.Name)
+	}
+	for _, arg := range prompt.Arguments {
+		if arg.Required && strings.TrimSpace(p.Arguments[arg.Name]) == "" {
+			return nil, fmt.Errorf("%w: %s requires the %s argument", errInvalidPrompt, p.Name, arg.Name)
+		}
+	}
+
+	profileID := profileFromContext(ctx)
+	settings, err := s.storage.GetSettings(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+	now := time.Now().In(loc)
+
+	var messages []PromptMessage
+	switch p.Name {
+	case "log_meal_with_clarifications":
+		messages, err = s.logMealPrompt(profileID, p.Arguments, now)
+	case "review_todays_carbs":
+		messages, err = s.reviewDayPrompt(profileID, settings, p.Arguments, now)
+	case "weekly_pattern_review":
+		messages, err = s.weeklyReviewPrompt(profileID, p.Arguments, now)
+	case "pre_bolus_check":
+		messages, err = s.preBolusPrompt(profileID, settings, p.Arguments, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"description": prompt.Description,
+		"messages":    messages,
+	}, nil
+}
+
+func (s *MealLogServer) logMealPrompt(profileID string, args map[string]string, now time.Time) ([]PromptMessage, error) {
+	when := "now"
+	if t := args["time"]; t != "" {
+		if _, err := time.Parse(time.RFC3339, t); err != nil {
+			return nil, fmt.Errorf("%w: invalid time: %v", errInvalidPrompt, err)
+		}
+		when = t
+	}
+	recent, err := s.recentMeals(profileID, storage.MealQuery{Limit: promptRecentMeals})
+	if err != nil {
+		return nil, err
+	}
+
+	text := fmt.Sprintf(`I ate: %s
+Time: %s
+
+Before logging this, call calculate_carbs with ask_clarifications set to true. If it asks about portion sizes, preparation or varieties, ask me those questions and wait for my answers. Then call log_meal with a description that includes my answers (and the timestamp if it is not now), and show me the food breakdown and total carbs that were saved.
+
+My recent meals are attached; use them to judge my usual portions, and point it out if this looks like a duplicate of a meal I already logged.`, args["description"], when)
+
+	return []PromptMessage{
+		textMessage(text),
+		mealsMessage("My recent meals:", recent),
+	}, nil
+}
+
+func (s *MealLogServer) reviewDayPrompt(profileID string, settings *models.ProfileSettings, args map[string]string, now time.Time) ([]PromptMessage, error) {
+	date := args["date"]
+	if date == "" {
+		date = now.Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("%w: invalid date %q, expected YYYY-MM-DD", errInvalidPrompt, date)
+	}
+	uri := dayURIPrefix + date
+	day, err := s.readResource(profileID, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	text := fmt.Sprintf(`Review my meals for %s, which are attached.
+
+1. Give the day's total carbs and how they were split across breakfast, lunch, dinner and snacks.
+2. Flag meals whose estimates look off: low confidence, missing foods, or totals that do not add up from the food breakdown.
+3. For each flagged meal suggest a correction, and apply it with update_meal only after I confirm.`, date)
+	if len(settings.CarbRatios) > 0 {
+		text += "\n\nMy carb ratios (grams per unit of insulin) are: " + formatRatios(settings) + "."
+	}
+
+	return []PromptMessage{
+		textMessage(text),
+		resourceMessage(uri, day),
+	}, nil
+}
+
+func (s *MealLogServer) weeklyReviewPrompt(profileID string, args map[string]string, now time.Time) ([]PromptMessage, error) {
+	week := args["week"]
+	if week == "" {
+		week = isoWeek(now)
+	} else if _, err := parseISOWeek(week); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPrompt, err)
+	}
+	uri := weekURIPrefix + week
+	summary, err := s.readResource(profileID, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	text := fmt.Sprintf(`Review my eating patterns for week %s using the attached summary.
+
+- Which days were highest and lowest in carbs, and how far apart were they?
+- Are there meal times or slots where my carbs are consistently high?
+- Which foods contribute the most carbs, and how many estimates had low confidence?
+
+Finish with two or three concrete, practical observations. Use get_meals if you need the individual meals of a day.`, week)
+
+	return []PromptMessage{
+		textMessage(text),
+		resourceMessage(uri, summary),
+	}, nil
+}
+
+func (s *MealLogServer) preBolusPrompt(profileID string, settings *models.ProfileSettings, args map[string]string, now time.Time) ([]PromptMessage, error) {
+	slot := settings.MealSlot(now)
+	similar, err := s.recentMeals(profileID, storage.MealQuery{Slot: slot, Limit: promptRecentMeals})
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "I am about to eat: %s\n", args["description"])
+	if carbs := args["carbs"]; carbs != "" {
+		grams, err := strconv.ParseFloat(carbs, 64)
+		if err != nil || grams < 0 {
+			return nil, fmt.Errorf("%w: carbs must be a number of grams", errInvalidPrompt)
+		}
+		fmt.Fprintf(&sb, "I counted %g g of carbs.\n", grams)
+	} else {
+		sb.WriteString("Estimate the carbs with calculate_carbs first; do not log the meal yet.\n")
+	}
+
+	if ratio, ok := settings.CarbRatio(slot); ok {
+		fmt.Fprintf(&sb, "My %s carb ratio is 1 unit per %g g.\n", slot, ratio)
+		sb.WriteString("\nWork out the insulin for the carbs by that ratio and show the arithmetic.")
+	} else {
+		sb.WriteString("\nI have no carb ratio set for this time of day, so give the carbs only and remind me to set one with update_settings.")
+	}
+	fmt.Fprintf(&sb, ` Compare the estimate with my recent %s meals, which are attached, and say if it is unusually high or low. Point out anything likely to absorb slowly, such as fat or protein heavy foods. This is a check of my own calculation, not a dosing instruction.`, slot)
+
+	return []PromptMessage{
+		textMessage(sb.String()),
+		mealsMessage(fmt.Sprintf("My recent %s meals:", slot), similar),
+	}, nil
+}
+
+func (s *MealLogServer) recentMeals(profileID string, q storage.MealQuery) ([]*models.Meal, error) {
+	page, err := s.storage.GetMeals(profileID, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent meals: %w", err)
+	}
+	return page.Meals, nil
+}
+
+// formatRatios lists the carb ratios in slot order: the built-in slots,
+// then the profile's own, then any other ratio and finally the default.
+func formatRatios(settings *models.ProfileSettings) string {
+	slots := append([]string(nil), models.MealSlots...)
+	for _, window := range settings.SlotWindows() {
+		if !slices.Contains(slots, window.Slot) {
+			slots = append(slots, window.Slot)
+		}
+	}
+	var others []string
+	for slot := range settings.CarbRatios {
+		if !slices.Contains(slots, slot) && slot != "default" {
+			others = append(others, slot)
+		}
+	}
+	slices.Sort(others)
+	slots = append(append(slots, others...), "default")
+
+	var parts []string
+	for _, slot := range slots {
+		if ratio, ok := settings.CarbRatios[slot]; ok {
+			parts = append(parts, fmt.Sprintf("%s 1:%g", slot, ratio))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func textMessage(text string) PromptMessage {
+	return PromptMessage{
+		Role:    "user",
+		Content: map[string]interface{}{"type": "text", "text": text},
+	}
+}
+
+// mealsMessage lists meals as JSON after a heading.
+func mealsMessage(heading string, meals []*models.Meal) PromptMessage {
+	return textMessage(heading + "\n" + formatJSON(meals))
+}
+
+// resourceMessage embeds data as a JSON resource.
+func resourceMessage(uri string, data interface{}) PromptMessage {
+	return PromptMessage{
+		Role: "user",
+		Content: map[string]interface{}{
+			"type": "resource",
+			"resource": map[string]interface{}{
+				"uri":      uri,
+				"mimeType": "application/json",
+				"text":     formatJSON(data),
+			},
+		},
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"testing"
+
+	"mcp-meal-log/internal/models"
+)
+
+func TestFormatRatios(t *testing.T) {
+	settings := &models.ProfileSettings{
+		CarbRatios: map[string]float64{
+			"default": 12, "dinner": 8, "breakfast": 6, "pre-workout": 20, "second-breakfast": 9, "old-slot": 15,
+		},
+		MealSchedule: []models.SlotWindow{
+			{Slot: "breakfast", Start: 6 * 60, End: 9 * 60},
+			{Slot: "second-breakfast", Start: 9 * 60, End: 11 * 60},
+			{Slot: "pre-workout", Start: 17 * 60, End: 18 * 60},
+		},
+	}
+	want := "breakfast 1:6, dinner 1:8, second-breakfast 1:9, pre-workout 1:20, old-slot 1:15, default 1:12"
+	if got := formatRatios(settings); got != want {
+		t.Errorf("formatRatios =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"mcp-meal-log/internal/report"
+)
+
+type GenerateReportParams struct {
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	Format    string `json:"format,omitempty"`
+	TopFoods  int    `json:"top_foods,omitempty"`
+}
+
+func reportTools() []Tool {
+	return []Tool{
+		{
+			Name:        "generate_report",
+			Description: "Generate a self-contained report for a date range, for sharing with a care team: daily carb totals, average carbs per meal slot, estimate confidence and the most frequent foods, with charts. Returns HTML, or a PDF when format is pdf",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"start_date": map[string]interface{}{
+						"type":        "string",
+						"description": fmt.Sprintf("Start date (YYYY-MM-DD); defaults to %d days before the end date. A report covers at most %d days", report.DefaultDays-1, report.MaxDays),
+					},
+					"end_date": map[string]interface{}{
+						"type":        "string",
+						"description": "End date (YYYY-MM-DD); defaults to today",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"html", "pdf"},
+						"description": "Report format (default html)",
+					},
+					"top_foods": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Number of foods to list (default %d)", report.DefaultTopFoods),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) generateReport(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p GenerateReportParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.Format != "" && p.Format != "html" && p.Format != "pdf" {
+		return nil, fmt.Errorf("unknown report format: %s", p.Format)
+	}
+	if p.TopFoods <= 0 {
+		p.TopFoods = report.DefaultTopFoods
+	}
+
+	profileID := profileFromContext(ctx)
+	settings, err := s.storage.GetSettings(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+	startDate, endDate, err := report.DateRange(p.StartDate, p.EndDate, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := s.storage.Summarize(profileID, startDate, endDate, p.TopFoods)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize meals: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Render(&buf, summary); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	if p.Format != "pdf" {
+		return textResult(buf.String()), nil
+	}
+
+	pdf, err := report.ConvertPDF(ctx, buf.Bytes(), s.config.PDFCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert report to PDF: %w", err)
+	}
+	return contentResult{
+		{
+			"type": "resource",
+			"resource": map[string]interface{}{
+				"uri":      fmt.Sprintf("meal-log://reports/%s/%s_%s.pdf", profileID, startDate, endDate),
+				"mimeType": "application/pdf",
+				"blob":     base64.StdEncoding.EncodeToString(pdf),
+			},
+		},
+	}, nil
+}
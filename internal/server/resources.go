@@ -0,0 +1,435 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/report"
+	"mcp-meal-log/internal/storage"
+)
+
+// Resources expose the meal log as context a client can attach without a
+// tool call. Every resource is read from the caller's active profile.
+const (
+	mealURIPrefix = "meal://"
+	dayURIPrefix  = "meals://day/"
+	weekURIPrefix = "summary://week/"
+	foodURIPrefix = "food://"
+)
+
+// recentMealResources is how many recent meals resources/list includes.
+const recentMealResources = 20
+
+// errResourceNotFound is answered with the MCP "resource not found" code.
+var errResourceNotFound = errors.New("resource not found")
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceURIParams struct {
+	URI string `json:"uri"`
+}
+
+// FoodHistory is the food:// resource: every time a food was eaten.
+type FoodHistory struct {
+	Name                string      `json:"name"`
+	Occurrences         int         `json:"occurrences"`
+	AverageCarbs        float64     `json:"average_carbs"`
+	AverageCarbsPer100g float64     `json:"average_carbs_per_100g,omitempty"`
+	LastEaten           *time.Time  `json:"last_eaten,omitempty"`
+	Recent              []FoodEntry `json:"recent"`
+}
+
+type FoodEntry struct {
+	MealID         string    `json:"meal_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Quantity       string    `json:"quantity,omitempty"`
+	EstimatedCarbs float64   `json:"estimated_carbs"`
+	CarbsPer100g   float64   `json:"carbs_per_100g,omitempty"`
+}
+
+// foodHistoryRecent is how many recent servings a food:// resource lists.
+const foodHistoryRecent = 10
+
+func resourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{
+			URITemplate: mealURIPrefix + "{id}",
+			Name:        "Meal",
+			Description: "A logged meal with its food breakdown",
+			MimeType:    "application/json",
+		},
+		{
+			URITemplate: dayURIPrefix + "{date}",
+			Name:        "Meals of a day",
+			Description: "Every meal logged on a local date (YYYY-MM-DD) with the day's carb total",
+			MimeType:    "application/json",
+		},
+		{
+			URITemplate: weekURIPrefix + "{iso-week}",
+			Name:        "Weekly summary",
+			Description: "Daily carb totals, averages and top foods for an ISO week such as 2024-W07",
+			MimeType:    "application/json",
+		},
+		{
+			URITemplate: foodURIPrefix + "{name}",
+			Name:        "Food history",
+			Description: "How often a food was eaten and how many carbs it was logged with",
+			MimeType:    "application/json",
+		},
+	}
+}
+
+func (s *MealLogServer) handleResourcesList(ctx context.Context) (interface{}, error) {
+	profileID := profileFromContext(ctx)
+	loc, err := s.profileLocation(profileID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(loc)
+	today := now.Format("2006-01-02")
+	week := isoWeek(now)
+
+	resources := []Resource{
+		{
+			URI:      dayURIPrefix + today,
+			Name:     "Today's meals (" + today + ")",
+			MimeType: "application/json",
+		},
+		{
+			URI:      weekURIPrefix + week,
+			Name:     "This week's summary (" + week + ")",
+			MimeType: "application/json",
+		},
+	}
+
+	page, err := s.storage.GetMeals(profileID, storage.MealQuery{Limit: recentMealResources})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list meals: %w", err)
+	}
+	for _, meal := range page.Meals {
+		resources = append(resources, Resource{
+			URI:         mealURIPrefix + meal.ID,
+			Name:        meal.Description,
+			Description: fmt.Sprintf("%.0fg carbs on %s", meal.TotalCarbs, meal.Timestamp.In(loc).Format("2006-01-02 15:04")),
+			MimeType:    "application/json",
+		})
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+func (s *MealLogServer) handleResourcesRead(ctx context.Context, params interface{}) (interface{}, error) {
+	var p ResourceURIParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	data, err := s.readResource(profileFromContext(ctx), p.URI)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{
+				"uri":      p.URI,
+				"mimeType": "application/json",
+				"text":     formatJSON(data),
+			},
+		},
+	}, nil
+}
+
+// handleResourcesSubscribe subscribes the session to, or with subscribe
+// false unsubscribes it from, notifications/resources/updated for a URI.
+func (s *MealLogServer) handleResourcesSubscribe(ctx context.Context, params interface{}, subscribe bool) (interface{}, error) {
+	var p ResourceURIParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return nil, fmt.Errorf("subscriptions require a session; send the Mcp-Session-Id header")
+	}
+	uri, err := canonicalURI(p.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscribe {
+		sess.subscribe(profileFromContext(ctx), uri)
+	} else {
+		sess.unsubscribe(profileFromContext(ctx), uri)
+	}
+	return map[string]interface{}{}, nil
+}
+
+func decodeParams(params interface{}, target interface{}) error {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid parameters format")
+	}
+	if err := mapToStruct(paramsMap, target); err != nil {
+		return fmt.Errorf("invalid parameters: %w", err)
+	}
+	return nil
+}
+
+func (s *MealLogServer) readResource(profileID, uri string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(uri, mealURIPrefix):
+		meal, err := s.storage.GetMeal(profileID, strings.TrimPrefix(uri, mealURIPrefix))
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+		}
+		return meal, err
+
+	case strings.HasPrefix(uri, dayURIPrefix):
+		date := strings.TrimPrefix(uri, dayURIPrefix)
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: invalid date in %s", errResourceNotFound, uri)
+		}
+		return s.readDay(profileID, date)
+
+	case strings.HasPrefix(uri, weekURIPrefix):
+		monday, err := parseISOWeek(strings.TrimPrefix(uri, weekURIPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errResourceNotFound, err)
+		}
+		summary, err := s.storage.Summarize(profileID, monday.Format("2006-01-02"), monday.AddDate(0, 0, 6).Format("2006-01-02"), report.DefaultTopFoods)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize week: %w", err)
+		}
+		return summary, nil
+
+	case strings.HasPrefix(uri, foodURIPrefix):
+		name, err := url.PathUnescape(strings.TrimPrefix(uri, foodURIPrefix))
+		if err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: invalid food name in %s", errResourceNotFound, uri)
+		}
+		return s.readFood(profileID, strings.TrimSpace(name))
+	}
+	return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+}
+
+func (s *MealLogServer) readDay(profileID, date string) (interface{}, error) {
+	page, err := s.storage.GetMeals(profileID, storage.MealQuery{
+		StartDate: date,
+		EndDate:   date,
+		Ascending: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meals: %w", err)
+	}
+	total := 0.0
+	for _, meal := range page.Meals {
+		total += meal.TotalCarbs
+	}
+	return map[string]interface{}{
+		"date":        date,
+		"meals":       page.Meals,
+		"meal_count":  len(page.Meals),
+		"total_carbs": total,
+	}, nil
+}
+
+// readFood collects every serving of a food, matching names without regard
+// to case. The search index narrows the meals down when it is available.
+func (s *MealLogServer) readFood(profileID, name string) (interface{}, error) {
+	history := &FoodHistory{Name: name, Recent: []FoodEntry{}}
+	var carbs, per100 float64
+	var per100Count int
+	var entries []FoodEntry
+
+	collect := func(meal *models.Meal) error {
+		for _, food := range meal.Foods {
+			if !strings.EqualFold(strings.TrimSpace(food.Name), name) {
+				continue
+			}
+			entries = append(entries, FoodEntry{
+				MealID:         meal.ID,
+				Timestamp:      meal.Timestamp,
+				Quantity:       food.Quantity,
+				EstimatedCarbs: food.EstimatedCarbs,
+				CarbsPer100g:   food.CarbsPer100g,
+			})
+			carbs += food.EstimatedCarbs
+			if food.CarbsPer100g > 0 {
+				per100 += food.CarbsPer100g
+				per100Count++
+			}
+		}
+		return nil
+	}
+
+	err := s.storage.StreamMeals(profileID, storage.MealQuery{Text: name}, collect)
+	if errors.Is(err, storage.ErrSearchUnavailable) {
+		err = s.storage.StreamMeals(profileID, storage.MealQuery{}, collect)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meals: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no food named %q", errResourceNotFound, name)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	history.Occurrences = len(entries)
+	history.AverageCarbs = carbs / float64(len(entries))
+	if per100Count > 0 {
+		history.AverageCarbsPer100g = per100 / float64(per100Count)
+	}
+	history.LastEaten = &entries[0].Timestamp
+	if len(entries) > foodHistoryRecent {
+		entries = entries[:foodHistoryRecent]
+	}
+	history.Recent = entries
+	return history, nil
+}
+
+func (s *MealLogServer) profileLocation(profileID string) (*time.Location, error) {
+	settings, err := s.storage.GetSettings(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+	return loc, nil
+}
+
+// isoWeek formats the ISO week of t, e.g. 2024-W07.
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// parseISOWeek returns the Monday starting an ISO week such as 2024-W07.
+func parseISOWeek(s string) (time.Time, error) {
+	var year, week int
+	if n, err := fmt.Sscanf(s, "%4d-W%2d", &year, &week); err != nil || n != 2 || len(s) != 8 {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q, expected e.g. 2024-W07", s)
+	}
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	if isoWeek(monday) != s {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q", s)
+	}
+	return monday, nil
+}
+
+// foodURI is the canonical food:// URI of a food name.
+func foodURI(name string) string {
+	return foodURIPrefix + url.PathEscape(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// canonicalURI normalizes a URI a client subscribes to so it matches the
+// URIs notifications are sent for.
+func canonicalURI(uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, foodURIPrefix):
+		name, err := url.PathUnescape(strings.TrimPrefix(uri, foodURIPrefix))
+		if err != nil || strings.TrimSpace(name) == "" {
+			return "", fmt.Errorf("invalid food URI: %s", uri)
+		}
+		return foodURI(name), nil
+	case strings.HasPrefix(uri, mealURIPrefix), strings.HasPrefix(uri, dayURIPrefix), strings.HasPrefix(uri, weekURIPrefix):
+		return uri, nil
+	}
+	return "", fmt.Errorf("%w: %s", errResourceNotFound, uri)
+}
+
+// mealResourceURIs lists the resources whose contents depend on meal.
+func mealResourceURIs(meal *models.Meal, loc *time.Location) []string {
+	local := meal.Timestamp.In(loc)
+	uris := []string{
+		mealURIPrefix + meal.ID,
+		dayURIPrefix + local.Format("2006-01-02"),
+		weekURIPrefix + isoWeek(local),
+	}
+	for _, food := range meal.Foods {
+		uris = append(uris, foodURI(food.Name))
+	}
+	return uris
+}
+
+// mealsChanged sends notifications/resources/updated to every session
+// subscribed to a resource that depends on the given meals. Pass both the
+// old and new version of an edited meal, since it may have moved to another
+// day; nil meals are skipped.
+func (s *MealLogServer) mealsChanged(profileID string, meals ...*models.Meal) {
+	loc, err := s.profileLocation(profileID)
+	if err != nil {
+		log.Printf("Failed to notify resource subscribers: %v", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	var uris []string
+	for _, meal := range meals {
+		if meal == nil {
+			continue
+		}
+		for _, uri := range mealResourceURIs(meal, loc) {
+			if !seen[uri] {
+				seen[uri] = true
+				uris = append(uris, uri)
+			}
+		}
+	}
+
+	for _, sess := range s.sessions.all() {
+		for _, uri := range uris {
+			if !sess.subscribed(profileID, uri) {
+				continue
+			}
+			message, err := json.Marshal(MCPNotification{
+				Jsonrpc: "2.0",
+				Method:  "notifications/resources/updated",
+				Params:  map[string]interface{}{"uri": uri},
+			})
+			if err != nil {
+				continue
+			}
+			sess.notify(message)
+		}
+	}
+}
+
+// importStore notifies subscribers about each batch the importer saves.
+type importStore struct {
+	storage.Store
+	server    *MealLogServer
+	profileID string
+}
+
+func (st importStore) SaveMeals(meals []*models.Meal, change models.ChangeSource) error {
+	if err := st.Store.SaveMeals(meals, change); err != nil {
+		return err
+	}
+	st.server.mealsChanged(st.profileID, meals...)
+	return nil
+}
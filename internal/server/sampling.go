@@ -4,6 +4,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +20,8 @@ type SamplingClient struct {
 	gatewayURL string
 	apiKey     string
 	model      string
+	// visionModel analyses meal photos
+	visionModel string
 }
 
 func NewSamplingClient() *SamplingClient {
@@ -59,14 +62,20 @@ func NewSamplingClient() *SamplingClient {
 	if model == "" {
 		model = "anthropic/claude-3.5-sonnet" // Default fallback
 	}
+	// Photos need a model that accepts images
+	visionModel := os.Getenv("OPENROUTER_VISION_MODEL")
+	if visionModel == "" {
+		visionModel = model
+	}
 
 	return &SamplingClient{
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
-		gatewayURL: gatewayURL,
-		apiKey:     apiKey,
-		model:      model,
+		gatewayURL:  gatewayURL,
+		apiKey:      apiKey,
+		model:       model,
+		visionModel: visionModel,
 	}
 }
 
@@ -105,14 +114,36 @@ Then set "needs_more_info" to true and include specific clarifying questions in
 	userPrompt := fmt.Sprintf(`Analyze this meal and calculate carbohydrates: "%s"
 Provide detailed breakdown of each food item, realistic portion estimates, and total carbohydrates.%s`, req.MealDescription, clarificationText)
 
+	// Photos go to the vision model as an image part next to the prompt
+	model := s.model
+	var content interface{} = userPrompt
+	if req.Image != nil {
+		model = s.visionModel
+		if req.MealDescription == "" {
+			userPrompt = `Analyze the meal in this photo and calculate carbohydrates.
+Identify each food item and estimate its portion from the plate, cutlery and packaging visible, then give the total carbohydrates.` + clarificationText
+		} else {
+			userPrompt += "\nThe attached photo shows the meal; use it to identify the foods and judge the portion sizes."
+		}
+		content = []map[string]interface{}{
+			{"type": "text", "text": userPrompt},
+			{
+				"type": "image_url",
+				"image_url": map[string]interface{}{
+					"url": "data:" + req.Image.MimeType + ";base64," + base64.StdEncoding.EncodeToString(req.Image.Data),
+				},
+			},
+		}
+	}
+
 	// Call the OpenRouter gateway using the configured model
 	completionRequest := map[string]interface{}{
-		"model":         s.model, // Use the configured model
+		"model":         model,
 		"system_prompt": systemPrompt,
 		"messages": []map[string]interface{}{
 			{
 				"role":    "user",
-				"content": userPrompt,
+				"content": content,
 			},
 		},
 		"max_tokens":  2000,
@@ -120,7 +151,7 @@ Provide detailed breakdown of each food item, realistic portion estimates, and t
 	}
 
 	// Call the gateway
-	gatewayResponse, err := s.callGateway("create_completion", completionRequest)
+	gatewayResponse, err := s.callGateway(ctx, "create_completion", completionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AI completion: %w", err)
 	}
@@ -129,7 +160,7 @@ Provide detailed breakdown of each food item, realistic portion estimates, and t
 	return s.parseAIResponse(gatewayResponse)
 }
 
-func (s *SamplingClient) callGateway(toolName string, args interface{}) (string, error) {
+func (s *SamplingClient) callGateway(ctx context.Context, toolName string, args interface{}) (string, error) {
 	// Use the gateway URL directly (could be direct service or via proxy)
 	url := s.gatewayURL
 
@@ -167,7 +198,7 @@ func (s *SamplingClient) callGateway(toolName string, args interface{}) (string,
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -266,9 +297,10 @@ func (s *SamplingClient) createFallbackResponse(aiOutput string) *models.CarbCal
 	}
 }
 
-func (s *SamplingClient) AskClarification(ctx context.Context, mealDesc string, questions []string) (*models.CarbCalculationResponse, error) {
+func (s *SamplingClient) AskClarification(ctx context.Context, mealDesc string, image *models.MealImage, questions []string) (*models.CarbCalculationResponse, error) {
 	return s.CalculateCarbs(ctx, &models.CarbCalculationRequest{
 		MealDescription:   mealDesc,
 		AskClarifications: false,
+		Image:             image,
 	})
 }
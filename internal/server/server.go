@@ -4,10 +4,17 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"mcp-meal-log/internal/auth"
+	"mcp-meal-log/internal/backup"
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/models"
 	"mcp-meal-log/internal/storage"
 )
 
@@ -16,21 +23,64 @@ type Config struct {
 	Host      string
 	Port      int
 	DBPath    string
+	// DatabaseURL is a PostgreSQL connection string. When set the meal
+	// log is kept there instead of the SQLite file at DBPath.
+	DatabaseURL string
+
+	// AuthMode is AuthModeToken (default) or AuthModeNone.
+	AuthMode string
+	// CORSOrigins lists browser origins allowed to call the server; "*"
+	// allows any origin. Empty disables CORS.
+	CORSOrigins []string
+	// JWT enables validation of OAuth access tokens when JWKSFile is set.
+	JWT auth.JWTConfig
+	// ResourceURL is this server's public URL, advertised in OAuth
+	// protected resource metadata. Derived from the request when empty.
+	ResourceURL string
+	// EncryptionKeyFile holds the key for encrypting sensitive columns;
+	// encryption.KeyEnvVar is used when empty.
+	EncryptionKeyFile string
+	// TrashRetention is how long deleted meals stay restorable before they
+	// are purged. Zero keeps them forever.
+	TrashRetention time.Duration
+	// PDFCommand converts HTML reports to PDF, with {in} and {out}
+	// placeholders. A known converter on PATH is used when empty.
+	PDFCommand string
+	// Pragmas are applied to every database connection on top of
+	// storage.DefaultPragmas; MaxOpenConns sizes the connection pool
+	// (storage.DefaultMaxOpenConns when zero).
+	Pragmas      []storage.Pragma
+	MaxOpenConns int
+	// BackupDir enables snapshots of the SQLite database, written there
+	// every BackupInterval (never when zero) and by the create_snapshot
+	// tool, and rotated according to BackupPolicy.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupPolicy   backup.Policy
 }
 
 type MealLogServer struct {
 	httpServer     *http.Server
-	storage        *storage.SQLiteStorage
+	storage        storage.Store
+	backups        *backup.Manager
 	samplingClient *SamplingClient
+	sessions       *sessionStore
+	jwtValidator   *auth.JWTValidator
 	config         *Config
 }
 
 // MCP Protocol types
+
+// MCPRequest is a message from the client: a request, a notification, or
+// the response to a request the server sent, which has Result or Error
+// instead of Method.
 type MCPRequest struct {
-	Jsonrpc string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params,omitempty"`
+	Jsonrpc string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *MCPError       `json:"error,omitempty"`
 }
 
 type MCPResponse struct {
@@ -40,6 +90,13 @@ type MCPResponse struct {
 	Error   *MCPError   `json:"error,omitempty"`
 }
 
+// MCPNotification is a message the server sends without expecting a reply.
+type MCPNotification struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type MCPError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -64,7 +121,14 @@ type ToolsListResult struct {
 
 func NewMealLogServer(cfg *Config) (*MealLogServer, error) {
 	// Initialize database
-	stor, err := storage.NewSQLiteStorage(cfg.DBPath)
+	cipher, err := encryption.LoadCipher(cfg.EncryptionKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	stor, err := storage.Open(cfg.DBPath, cfg.DatabaseURL,
+		storage.WithCipher(cipher),
+		storage.WithPragmas(cfg.Pragmas...),
+		storage.WithMaxOpenConns(cfg.MaxOpenConns))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
@@ -72,12 +136,46 @@ func NewMealLogServer(cfg *Config) (*MealLogServer, error) {
 	mealServer := &MealLogServer{
 		storage:        stor,
 		samplingClient: NewSamplingClient(),
+		sessions:       newSessionStore(),
 		config:         cfg,
 	}
 
+	if cfg.BackupDir != "" {
+		sqlite, ok := stor.(*storage.SQLiteStorage)
+		if !ok {
+			stor.Close()
+			return nil, fmt.Errorf("snapshots only support SQLite databases; use pg_dump for PostgreSQL")
+		}
+		mealServer.backups = backup.NewManager(cfg.BackupDir, sqlite, cipher, cfg.BackupPolicy)
+	}
+
+	if cfg.AuthMode == "" {
+		cfg.AuthMode = AuthModeToken
+	}
+	if cfg.AuthMode != AuthModeToken && cfg.AuthMode != AuthModeNone {
+		stor.Close()
+		return nil, fmt.Errorf("unknown auth mode: %s", cfg.AuthMode)
+	}
+	if cfg.JWT.JWKSFile != "" {
+		if mealServer.jwtValidator, err = auth.NewJWTValidator(cfg.JWT); err != nil {
+			stor.Close()
+			return nil, fmt.Errorf("failed to load JWT configuration: %w", err)
+		}
+	}
+	if cfg.AuthMode == AuthModeNone {
+		log.Printf("WARNING: authentication is disabled; anyone who can reach %s:%d can read and change meal data", cfg.Host, cfg.Port)
+	} else if n, err := stor.CountActiveTokens(); err == nil && n == 0 && mealServer.jwtValidator == nil {
+		log.Printf("No API tokens exist yet; create one with 'meal-log token create'")
+	}
+
 	// Set up HTTP handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", mealServer.handleMCP)
+	mux.HandleFunc(treatmentsPath, mealServer.handleTreatments)
+	mux.HandleFunc(treatmentsPath+".json", mealServer.handleTreatments)
+	if mealServer.jwtValidator != nil {
+		mux.HandleFunc("/.well-known/oauth-protected-resource", mealServer.handleProtectedResourceMetadata)
+	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	mealServer.httpServer = &http.Server{
@@ -91,11 +189,29 @@ func NewMealLogServer(cfg *Config) (*MealLogServer, error) {
 
 func (s *MealLogServer) handleMCP(w http.ResponseWriter, r *http.Request) {
 	// Handle CORS
-	s.setCORSHeaders(w)
+	s.setCORSHeaders(w, r)
 	if r.Method == http.MethodOptions {
 		return
 	}
 
+	ident, err := s.authenticate(r)
+	if err != nil {
+		s.sendUnauthorized(w, r, err)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if sess, ok := s.sessions.get(r.Header.Get("Mcp-Session-Id")); ok && sess.subject == ident.Subject {
+			s.sessions.remove(sess.id)
+		}
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.handleStream(w, r, ident)
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		s.sendMCPError(w, nil, -32601, "Method not allowed")
 		return
@@ -108,48 +224,186 @@ func (s *MealLogServer) handleMCP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Resolve the session; initialize always starts a new one. Sessions
+	// belong to the caller that created them.
+	var sess *session
+	if request.Method != "initialize" {
+		if id := r.Header.Get("Mcp-Session-Id"); id != "" {
+			var ok bool
+			if sess, ok = s.sessions.get(id); !ok || sess.subject != ident.Subject {
+				http.Error(w, "Unknown session", http.StatusNotFound)
+				return
+			}
+		}
+	}
+
+	profileID, err := s.resolveProfile(r, sess, ident)
+	if err != nil {
+		s.sendMCPError(w, request.ID, -32602, err.Error())
+		return
+	}
+
+	if request.Method == "initialize" {
+		if sess, err = s.sessions.create(ident.Subject); err != nil {
+			s.sendMCPError(w, request.ID, -32603, err.Error())
+			return
+		}
+		if r.Header.Get(profileHeader) != "" {
+			sess.setProfile(profileID)
+		}
+		sess.setClient(negotiateProtocolVersion(request.Params), clientCapabilities(request.Params))
+		w.Header().Set("Mcp-Session-Id", sess.id)
+	}
+
+	// Responses to the server's own requests go to the call awaiting them
+	if request.Method == "" && request.ID != nil {
+		if sess == nil || !sess.deliver(&request) {
+			http.Error(w, "No request is waiting for this response", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Notifications carry no id and expect no response body
+	if request.ID == nil && strings.HasPrefix(request.Method, "notifications/") {
+		if request.Method == "notifications/cancelled" && sess != nil {
+			sess.cancel(cancelledRequestID(request.Params))
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if sess != nil {
+		sess.track(request.ID, cancel)
+		defer sess.untrack(request.ID)
+	}
+	out := newResponseStream(w, r)
+	ctx = withIdentity(withSession(withProfile(ctx, profileID), sess), ident)
+	ctx = withProgressToken(withResponseStream(ctx, out), progressToken(request.Params))
+
 	// Route to appropriate handler based on method
 	var result interface{}
-	var err error
 
 	switch request.Method {
 	case "initialize":
-		result = s.handleInitialize(request.Params)
+		result = s.handleInitialize(sess.protocol())
 	case "tools/list":
 		result = s.handleToolsList()
 	case "tools/call":
-		result, err = s.handleToolsCall(request.Params)
+		result, err = s.handleToolsCall(ctx, request.Params)
+	case "prompts/list":
+		result = map[string]interface{}{"prompts": prompts()}
+	case "prompts/get":
+		result, err = s.handlePromptsGet(ctx, request.Params)
+	case "resources/list":
+		result, err = s.handleResourcesList(ctx)
+	case "resources/templates/list":
+		result = map[string]interface{}{"resourceTemplates": resourceTemplates()}
+	case "resources/read":
+		result, err = s.handleResourcesRead(ctx, request.Params)
+	case "resources/subscribe":
+		result, err = s.handleResourcesSubscribe(ctx, request.Params, true)
+	case "resources/unsubscribe":
+		result, err = s.handleResourcesSubscribe(ctx, request.Params, false)
 	default:
 		s.sendMCPError(w, request.ID, -32601, fmt.Sprintf("Unknown method: %s", request.Method))
 		return
 	}
 
-	if err != nil {
-		s.sendMCPError(w, request.ID, -32603, err.Error())
-		return
-	}
-
-	// Send success response
 	response := MCPResponse{
 		Jsonrpc: "2.0",
 		ID:      request.ID,
-		Result:  result,
 	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		response.Error = &MCPError{Code: -32603, Message: "Request cancelled"}
+	case errors.Is(err, errInvalidPrompt):
+		response.Error = &MCPError{Code: -32602, Message: err.Error()}
+	case errors.Is(err, errResourceNotFound):
+		response.Error = &MCPError{Code: -32002, Message: err.Error()}
+	case err != nil:
+		response.Error = &MCPError{Code: -32603, Message: err.Error()}
+	default:
+		response.Result = result
+	}
+	out.reply(response)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// protocolVersions are the MCP revisions the server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// capabilitySince is the first revision in which the server may use a
+// client capability. Clients on older revisions are not asked, whatever
+// they declare.
+var capabilitySince = map[string]string{
+	"elicitation": "2025-06-18",
+}
+
+// negotiateProtocolVersion answers initialize with the client's requested
+// revision when the server speaks it, and with the newest one otherwise.
+func negotiateProtocolVersion(params interface{}) string {
+	paramsMap, _ := params.(map[string]interface{})
+	requested, _ := paramsMap["protocolVersion"].(string)
+	for _, version := range protocolVersions {
+		if version == requested {
+			return version
+		}
+	}
+	return protocolVersions[0]
+}
+
+// clientCapabilities extracts the capabilities from initialize parameters.
+func clientCapabilities(params interface{}) map[string]interface{} {
+	paramsMap, _ := params.(map[string]interface{})
+	capabilities, _ := paramsMap["capabilities"].(map[string]interface{})
+	return capabilities
 }
 
-func (s *MealLogServer) handleInitialize(params interface{}) interface{} {
+// profileHeader lets a client pick the profile for a single request or, when
+// sent with initialize, for the whole session.
+const profileHeader = "X-Meal-Log-Profile"
+
+func (s *MealLogServer) resolveProfile(r *http.Request, sess *session, ident *identity) (string, error) {
+	profileID := r.Header.Get(profileHeader)
+	if profileID == "" && sess != nil {
+		profileID = sess.profile()
+	}
+	if ident.ProfileID != "" {
+		if profileID != "" && profileID != ident.ProfileID {
+			return "", fmt.Errorf("not authorized for profile: %s", profileID)
+		}
+		profileID = ident.ProfileID
+	}
+	if profileID == "" {
+		return models.DefaultProfileID, nil
+	}
+
+	if _, err := s.storage.GetProfile(profileID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", fmt.Errorf("unknown profile: %s", profileID)
+		}
+		return "", err
+	}
+	return profileID, nil
+}
+
+func (s *MealLogServer) handleInitialize(protocolVersion string) interface{} {
 	return map[string]interface{}{
-		"protocolVersion": "2024-11-05",
+		"protocolVersion": protocolVersion,
 		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":   map[string]interface{}{},
+			"prompts": map[string]interface{}{},
+			"resources": map[string]interface{}{
+				"subscribe": true,
+			},
 		},
 		"serverInfo": ServerInfo{
 			Name:            "meal-log",
 			Version:         "1.0.0",
-			ProtocolVersion: "2024-11-05",
+			ProtocolVersion: protocolVersion,
 		},
 	}
 }
@@ -158,43 +412,98 @@ func (s *MealLogServer) handleToolsList() interface{} {
 	tools := []Tool{
 		{
 			Name:        "log_meal",
-			Description: "Log a meal with automatic carbohydrate calculation using AI",
+			Description: "Log a meal with automatic carbohydrate calculation using AI, from a description, a photo or both",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"description": map[string]interface{}{
 						"type":        "string",
-						"description": "Description of the meal eaten",
+						"description": "Description of the meal eaten (required without a photo, barcode or custom food)",
 					},
 					"timestamp": map[string]interface{}{
 						"type":        "string",
 						"description": "ISO timestamp of when meal was eaten (defaults to now)",
 					},
+					"slot": map[string]interface{}{
+						"type":        "string",
+						"description": "Meal slot such as breakfast, lunch, dinner, snack or one of your own (inferred from the timestamp by the meal schedule if omitted)",
+					},
+					"image": map[string]interface{}{
+						"type":        "string",
+						"description": "Photo of the meal, base64 encoded or as a data: URL (JPEG, PNG, GIF or WebP)",
+					},
+					"image_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of a photo of the meal on the server, instead of image (admin only)",
+					},
+					"barcode": map[string]interface{}{
+						"type":        "string",
+						"description": "UPC or EAN barcode of a packaged product; its label values are used instead of an AI estimate",
+					},
+					"custom_food": map[string]interface{}{
+						"type":        "string",
+						"description": "Name or ID of a custom food saved with parse_nutrition_label; its label values are used instead of an AI estimate",
+					},
+					"grams": map[string]interface{}{
+						"type":        "number",
+						"description": "Amount of the barcode product or custom food eaten, in grams",
+					},
+					"servings": map[string]interface{}{
+						"type":        "number",
+						"description": "Amount of the barcode product or custom food eaten, in servings (defaults to one serving)",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tags for the meal, such as ate out, sick day or pre-exercise; see list_tags for the ones already used",
+					},
+					"notes": map[string]interface{}{
+						"type":        "string",
+						"description": "Free-text notes about the meal",
+					},
+					"location": map[string]interface{}{
+						"type":        "string",
+						"description": "Where the meal was eaten",
+					},
+					"restaurant": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the restaurant the meal came from",
+					},
+					"exercise_within_2h": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether there was exercise within two hours of the meal, before or after",
+					},
 				},
-				"required": []string{"description"},
 			},
 		},
 		{
 			Name:        "calculate_carbs",
-			Description: "Calculate carbohydrates for a meal description without logging",
+			Description: "Calculate carbohydrates for a meal description or photo without logging",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"meal_description": map[string]interface{}{
 						"type":        "string",
-						"description": "Description of the meal to analyze",
+						"description": "Description of the meal to analyze (required without a photo)",
 					},
 					"ask_clarifications": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Whether to ask clarifying questions if needed",
 					},
+					"image": map[string]interface{}{
+						"type":        "string",
+						"description": "Photo of the meal, base64 encoded or as a data: URL (JPEG, PNG, GIF or WebP)",
+					},
+					"image_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of a photo of the meal on the server, instead of image (admin only)",
+					},
 				},
-				"required": []string{"meal_description"},
 			},
 		},
 		{
 			Name:        "get_meals",
-			Description: "Retrieve logged meals within a date range",
+			Description: "Retrieve logged meals within a date range, one page at a time. Pass next_cursor from the response as cursor to get the next page",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -208,17 +517,186 @@ func (s *MealLogServer) handleToolsList() interface{} {
 					},
 					"limit": map[string]interface{}{
 						"type":        "integer",
-						"description": "Maximum number of meals to return",
+						"description": "Maximum number of meals per page (default 20, at most 200)",
+					},
+					"include_deleted": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also return meals that are in the trash",
+					},
+					"sort": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"timestamp", "carbs"},
+						"description": "Sort by meal time or total carbs (default timestamp)",
+					},
+					"order": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"desc", "asc"},
+						"description": "Newest or largest first (desc, default) or oldest or smallest first (asc)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "next_cursor from the previous page; keep the other arguments unchanged",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Words to find in the meal description or food names, matched as prefixes (not available on encrypted databases)",
+					},
+					"min_carbs": map[string]interface{}{
+						"type":        "number",
+						"description": "Minimum total carbs in grams",
+					},
+					"max_carbs": map[string]interface{}{
+						"type":        "number",
+						"description": "Maximum total carbs in grams",
+					},
+					"confidence": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "string",
+							"enum": []string{"high", "medium", "low"},
+						},
+						"description": "Only meals with one of these confidence levels",
+					},
+					"source": map[string]interface{}{
+						"type":        "string",
+						"description": "Only meals from this source, e.g. ai_parsed, import or myfitnesspal",
+					},
+					"slot": map[string]interface{}{
+						"type":        "string",
+						"description": "Only meals in this meal slot, e.g. breakfast, lunch, dinner, snack or one of your own",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Only meals carrying all of these tags",
+					},
+					"time_from": map[string]interface{}{
+						"type":        "string",
+						"description": "Earliest local time of day (HH:MM)",
+					},
+					"time_to": map[string]interface{}{
+						"type":        "string",
+						"description": "Local time of day (HH:MM) before which meals were eaten; may be earlier than time_from to wrap past midnight",
 					},
 				},
 			},
 		},
+		{
+			Name:        "update_meal",
+			Description: "Correct a logged meal; the previous version is kept in the meal's history",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal to update",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "New description of the meal",
+					},
+					"timestamp": map[string]interface{}{
+						"type":        "string",
+						"description": "New ISO timestamp of when the meal was eaten",
+					},
+					"slot": map[string]interface{}{
+						"type":        "string",
+						"description": "New meal slot; an empty string infers it from the timestamp again",
+					},
+					"foods": map[string]interface{}{
+						"type":        "array",
+						"description": "Replacement food breakdown; total carbs and the meal confidence (the lowest food confidence) are recomputed from it",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"name":            map[string]interface{}{"type": "string"},
+								"quantity":        map[string]interface{}{"type": "string"},
+								"carbs_per_100g":  map[string]interface{}{"type": "number"},
+								"estimated_carbs": map[string]interface{}{"type": "number"},
+								"confidence":      map[string]interface{}{"type": "string", "enum": []string{"high", "medium", "low"}},
+							},
+							"required": []string{"name", "estimated_carbs"},
+						},
+					},
+					"total_carbs": map[string]interface{}{
+						"type":        "number",
+						"description": "Override the total carbohydrates in grams",
+					},
+					"recalculate": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Re-run the AI carb analysis on the (new) description. A meal logged from a photo is analysed with the small thumbnail kept of it, not the original photo, and the result is marked low confidence",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Replacement tags; an empty list removes them all",
+					},
+					"notes": map[string]interface{}{
+						"type":        "string",
+						"description": "New notes; an empty string clears them",
+					},
+					"location": map[string]interface{}{
+						"type":        "string",
+						"description": "New location; an empty string clears it",
+					},
+					"restaurant": map[string]interface{}{
+						"type":        "string",
+						"description": "New restaurant name; an empty string clears it",
+					},
+					"exercise_within_2h": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether there was exercise within two hours of the meal, before or after",
+					},
+				},
+				"required": []string{"meal_id"},
+			},
+		},
+		{
+			Name:        "delete_meal",
+			Description: "Move a logged meal to the trash; it can be restored with restore_meal until the trash is purged",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal to delete",
+					},
+				},
+				"required": []string{"meal_id"},
+			},
+		},
+		{
+			Name:        "restore_meal",
+			Description: "Take a deleted meal back out of the trash",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"meal_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the meal to restore",
+					},
+				},
+				"required": []string{"meal_id"},
+			},
+		},
+	}
+	tools = append(tools, historyTools()...)
+	tools = append(tools, photoTools()...)
+	tools = append(tools, productTools()...)
+	tools = append(tools, customFoodTools()...)
+	tools = append(tools, tagTools()...)
+	tools = append(tools, profileTools()...)
+	tools = append(tools, exportTools()...)
+	tools = append(tools, importTools()...)
+	tools = append(tools, reportTools()...)
+	if s.backups != nil {
+		tools = append(tools, backupTools()...)
 	}
 
 	return ToolsListResult{Tools: tools}
 }
 
-func (s *MealLogServer) handleToolsCall(params interface{}) (interface{}, error) {
+func (s *MealLogServer) handleToolsCall(ctx context.Context, params interface{}) (interface{}, error) {
 	// Parse the tool call parameters
 	paramsMap, ok := params.(map[string]interface{})
 	if !ok {
@@ -242,54 +720,91 @@ func (s *MealLogServer) handleToolsCall(params interface{}) (interface{}, error)
 	}
 
 	// Route to the appropriate tool handler
+	var result interface{}
+	var err error
+
 	switch toolName {
 	case "log_meal":
-		result, err := s.logMeal(args)
-		if err != nil {
-			return nil, err
-		}
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": formatJSON(result),
-				},
-			},
-		}, nil
-
+		result, err = s.logMeal(ctx, args)
 	case "calculate_carbs":
-		result, err := s.calculateCarbs(args)
-		if err != nil {
-			return nil, err
-		}
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": formatJSON(result),
-				},
-			},
-		}, nil
-
+		result, err = s.calculateCarbs(ctx, args)
 	case "get_meals":
-		result, err := s.getMeals(args)
-		if err != nil {
-			return nil, err
-		}
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": formatJSON(result),
-				},
-			},
-		}, nil
-
+		result, err = s.getMeals(ctx, args)
+	case "update_meal":
+		result, err = s.updateMeal(ctx, args)
+	case "delete_meal":
+		result, err = s.deleteMeal(ctx, args)
+	case "restore_meal":
+		result, err = s.restoreMeal(ctx, args)
+	case "get_meal_photo":
+		result, err = s.getMealPhoto(ctx, args)
+	case "lookup_barcode":
+		result, err = s.lookupBarcode(ctx, args)
+	case "add_product":
+		result, err = s.addProduct(ctx, args)
+	case "parse_nutrition_label":
+		result, err = s.parseNutritionLabel(ctx, args)
+	case "list_custom_foods":
+		result, err = s.listCustomFoods(ctx, args)
+	case "delete_custom_food":
+		result, err = s.deleteCustomFood(ctx, args)
+	case "list_tags":
+		result, err = s.listTags(ctx, args)
+	case "get_meal_history":
+		result, err = s.getMealHistory(ctx, args)
+	case "restore_meal_revision":
+		result, err = s.restoreMealRevision(ctx, args)
+	case "list_profiles":
+		result, err = s.listProfiles(ctx, args)
+	case "create_profile":
+		result, err = s.createProfile(ctx, args)
+	case "select_profile":
+		result, err = s.selectProfile(ctx, args)
+	case "get_settings":
+		result, err = s.getSettings(ctx, args)
+	case "update_settings":
+		result, err = s.updateSettings(ctx, args)
+	case "export_meals":
+		result, err = s.exportMeals(ctx, args)
+	case "import_meals":
+		result, err = s.importMeals(ctx, args)
+	case "generate_report":
+		result, err = s.generateReport(ctx, args)
+	case "create_snapshot":
+		result, err = s.createSnapshot(ctx, args)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", toolName)
 	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if content, ok := result.(contentResult); ok {
+		return map[string]interface{}{"content": content}, nil
+	}
+	text, ok := result.(textResult)
+	if !ok {
+		text = textResult(formatJSON(result))
+	}
+	return map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": string(text),
+			},
+		},
+	}, nil
 }
 
+// textResult is returned by tools whose output is already formatted text,
+// such as exports, so it is not wrapped in JSON.
+type textResult string
+
+// contentResult is returned by tools that build their own MCP content
+// items, such as binary resources.
+type contentResult []map[string]interface{}
+
 func formatJSON(data interface{}) string {
 	jsonBytes, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -298,12 +813,6 @@ func formatJSON(data interface{}) string {
 	return string(jsonBytes)
 }
 
-func (s *MealLogServer) setCORSHeaders(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-}
-
 func (s *MealLogServer) sendMCPError(w http.ResponseWriter, id interface{}, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK) // MCP errors are still HTTP 200
@@ -320,6 +829,14 @@ func (s *MealLogServer) sendMCPError(w http.ResponseWriter, id interface{}, code
 }
 
 func (s *MealLogServer) Start(ctx context.Context) error {
+	if s.config.TrashRetention > 0 {
+		go s.purgeTrash(ctx)
+	}
+	if s.backups != nil && s.config.BackupInterval > 0 {
+		go s.scheduleSnapshots(ctx)
+	}
+	go s.expireSessions(ctx)
+
 	log.Printf("Starting meal log server on %s", s.httpServer.Addr)
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
@@ -327,6 +844,46 @@ func (s *MealLogServer) Start(ctx context.Context) error {
 	return nil
 }
 
+// purgeTrash periodically removes meals that have been in the trash longer
+// than the retention period.
+func (s *MealLogServer) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.storage.PurgeDeletedMeals(time.Now().Add(-s.config.TrashRetention))
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d meals from the trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireSessions periodically drops sessions whose clients went away
+// without deleting them.
+func (s *MealLogServer) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if n := s.sessions.expire(); n > 0 {
+			log.Printf("Expired %d idle sessions", n)
+		}
+	}
+}
+
 func (s *MealLogServer) Stop() error {
 	if s.storage != nil {
 		s.storage.Close()
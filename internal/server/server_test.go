@@ -0,0 +1,44 @@
+package server
+
+import "testing"
+
+func TestInitializeNegotiatesProtocolVersion(t *testing.T) {
+	s := newTestServer(t)
+	token := newTestToken(t, s, "")
+	elicitation := map[string]interface{}{"elicitation": map[string]interface{}{}}
+
+	tests := []struct {
+		requested string
+		want      string
+		elicit    bool
+	}{
+		{"2025-06-18", "2025-06-18", true},
+		{"2025-03-26", "2025-03-26", false},
+		{"2024-11-05", "2024-11-05", false},
+		{"2099-01-01", "2025-06-18", true},
+		{"", "2025-06-18", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			before := len(s.sessions.all())
+			response := rpc(t, s, token, "", "initialize", map[string]interface{}{
+				"protocolVersion": tt.requested,
+				"capabilities":    elicitation,
+			})
+			result, _ := response.Result.(map[string]interface{})
+			if got := result["protocolVersion"]; got != tt.want {
+				t.Errorf("protocolVersion = %v, want %s", got, tt.want)
+			}
+
+			sessions := s.sessions.all()
+			if len(sessions) != before+1 {
+				t.Fatalf("%d sessions, want %d", len(sessions), before+1)
+			}
+			for _, sess := range sessions {
+				if sess.protocol() == tt.want && sess.supports("elicitation") != tt.elicit {
+					t.Errorf("supports(elicitation) = %v, want %v", !tt.elicit, tt.elicit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// session tracks per-client state between requests. It is created on
+// initialize and identified by the Mcp-Session-Id header afterwards.
+type session struct {
+	id        string
+	subject   string
+	createdAt time.Time
+
+	mu        sync.Mutex
+	profileID string
+	// subscriptions are the resources the client subscribed to, each
+	// keyed by the profile that was active when it subscribed.
+	subscriptions map[subscription]bool
+	// stream carries notifications to the client's open GET stream.
+	// Notifications sent while no stream is open are dropped.
+	stream chan []byte
+	// protocolVersion is the MCP revision negotiated in initialize, and
+	// capabilities are the client capabilities declared there.
+	protocolVersion string
+	capabilities    map[string]interface{}
+	// pending holds the requests sent to the client that await a
+	// response, by request ID.
+	pending       map[string]chan *MCPRequest
+	nextRequestID int
+	// inFlight cancels the client's requests that are being handled, by
+	// request ID.
+	inFlight map[string]context.CancelFunc
+}
+
+// track registers a request being handled so notifications/cancelled can
+// stop it.
+func (sess *session) track(id interface{}, cancel context.CancelFunc) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.inFlight == nil {
+		sess.inFlight = make(map[string]context.CancelFunc)
+	}
+	sess.inFlight[fmt.Sprint(id)] = cancel
+}
+
+func (sess *session) untrack(id interface{}) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	delete(sess.inFlight, fmt.Sprint(id))
+}
+
+// cancel stops a request being handled; requests that already finished
+// are ignored.
+func (sess *session) cancel(id interface{}) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if cancel, ok := sess.inFlight[fmt.Sprint(id)]; ok {
+		cancel()
+		delete(sess.inFlight, fmt.Sprint(id))
+	}
+}
+
+func (sess *session) setClient(protocolVersion string, capabilities map[string]interface{}) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.protocolVersion = protocolVersion
+	sess.capabilities = capabilities
+}
+
+func (sess *session) protocol() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.protocolVersion
+}
+
+// supports reports whether the client declared a capability, such as
+// "elicitation", and the negotiated revision allows the server to use it.
+func (sess *session) supports(capability string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if since, ok := capabilitySince[capability]; ok && sess.protocolVersion < since {
+		return false
+	}
+	_, ok := sess.capabilities[capability]
+	return ok
+}
+
+// expect allocates the ID of a request to the client and the channel its
+// response is delivered to. Call forget once the response has arrived or
+// is no longer wanted.
+func (sess *session) expect() (string, chan *MCPRequest) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.pending == nil {
+		sess.pending = make(map[string]chan *MCPRequest)
+	}
+	sess.nextRequestID++
+	id := fmt.Sprintf("srv-%d", sess.nextRequestID)
+	replies := make(chan *MCPRequest, 1)
+	sess.pending[id] = replies
+	return id, replies
+}
+
+func (sess *session) forget(id string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	delete(sess.pending, id)
+}
+
+// deliver hands a client's response to the request waiting for it, and
+// reports whether one was.
+func (sess *session) deliver(response *MCPRequest) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	replies, ok := sess.pending[fmt.Sprint(response.ID)]
+	if !ok {
+		return false
+	}
+	delete(sess.pending, fmt.Sprint(response.ID))
+	replies <- response
+	return true
+}
+
+type subscription struct {
+	profileID string
+	uri       string
+}
+
+func (sess *session) subscribe(profileID, uri string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.subscriptions == nil {
+		sess.subscriptions = make(map[subscription]bool)
+	}
+	sess.subscriptions[subscription{profileID, uri}] = true
+}
+
+func (sess *session) unsubscribe(profileID, uri string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	delete(sess.subscriptions, subscription{profileID, uri})
+}
+
+func (sess *session) subscribed(profileID, uri string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.subscriptions[subscription{profileID, uri}]
+}
+
+// openStream attaches a GET stream to the session, replacing any earlier
+// one so each notification is delivered once.
+func (sess *session) openStream() chan []byte {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.stream != nil {
+		close(sess.stream)
+	}
+	sess.stream = make(chan []byte, 64)
+	return sess.stream
+}
+
+// closeStream detaches stream if it is still the session's stream; nil
+// detaches whichever stream is open.
+func (sess *session) closeStream(stream chan []byte) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.stream != nil && (stream == nil || stream == sess.stream) {
+		close(sess.stream)
+		sess.stream = nil
+	}
+}
+
+// notify queues a message for the GET stream without blocking; a client
+// that stops reading misses notifications rather than stalling writes.
+func (sess *session) notify(message []byte) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.stream == nil {
+		return
+	}
+	select {
+	case sess.stream <- message:
+	default:
+	}
+}
+
+func (sess *session) profile() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.profileID
+}
+
+func (sess *session) setProfile(profileID string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.profileID = profileID
+}
+
+// busy reports whether the session has an open GET stream or requests
+// being handled, which keeps it from expiring.
+func (sess *session) busy() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.stream != nil || len(sess.inFlight) > 0
+}
+
+const (
+	// sessionIdleTimeout is how long a session is kept after its last
+	// request. Clients that come back later start a new one.
+	sessionIdleTimeout = 30 * time.Minute
+	// maxSessions bounds the sessions held in memory; creating one more
+	// evicts the least recently used idle session.
+	maxSessions = 1000
+)
+
+var errTooManySessions = errors.New("too many open sessions")
+
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	// lastUsed is when each session was last looked up, by session ID.
+	lastUsed    map[string]time.Time
+	idleTimeout time.Duration
+	max         int
+	now         func() time.Time
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions:    make(map[string]*session),
+		lastUsed:    make(map[string]time.Time),
+		idleTimeout: sessionIdleTimeout,
+		max:         maxSessions,
+		now:         time.Now,
+	}
+}
+
+func (st *sessionStore) create(subject string) (*session, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	sess := &session{
+		id:        hex.EncodeToString(buf),
+		subject:   subject,
+		createdAt: st.now(),
+	}
+
+	st.mu.Lock()
+	var evicted *session
+	if len(st.sessions) >= st.max {
+		if evicted = st.leastRecentlyUsed(); evicted == nil {
+			st.mu.Unlock()
+			return nil, errTooManySessions
+		}
+		st.delete(evicted.id)
+	}
+	st.sessions[sess.id] = sess
+	st.lastUsed[sess.id] = sess.createdAt
+	st.mu.Unlock()
+
+	if evicted != nil {
+		evicted.closeStream(nil)
+	}
+	return sess, nil
+}
+
+// leastRecentlyUsed returns the idle session used longest ago, or nil when
+// every session is busy. st.mu must be held.
+func (st *sessionStore) leastRecentlyUsed() *session {
+	var oldest *session
+	for id, sess := range st.sessions {
+		if sess.busy() {
+			continue
+		}
+		if oldest == nil || st.lastUsed[id].Before(st.lastUsed[oldest.id]) {
+			oldest = sess
+		}
+	}
+	return oldest
+}
+
+func (st *sessionStore) delete(id string) {
+	delete(st.sessions, id)
+	delete(st.lastUsed, id)
+}
+
+func (st *sessionStore) get(id string) (*session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sess, ok := st.sessions[id]
+	if ok {
+		st.lastUsed[id] = st.now()
+	}
+	return sess, ok
+}
+
+// expire removes sessions that have been idle longer than the idle
+// timeout and returns how many were removed. Busy sessions count as used.
+func (st *sessionStore) expire() int {
+	now := st.now()
+	var expired []*session
+
+	st.mu.Lock()
+	for id, sess := range st.sessions {
+		switch {
+		case sess.busy():
+			st.lastUsed[id] = now
+		case now.Sub(st.lastUsed[id]) > st.idleTimeout:
+			st.delete(id)
+			expired = append(expired, sess)
+		}
+	}
+	st.mu.Unlock()
+
+	for _, sess := range expired {
+		sess.closeStream(nil)
+	}
+	return len(expired)
+}
+
+func (st *sessionStore) remove(id string) {
+	st.mu.Lock()
+	sess, ok := st.sessions[id]
+	st.delete(id)
+	st.mu.Unlock()
+	if ok {
+		sess.closeStream(nil)
+	}
+}
+
+// all returns every open session.
+func (st *sessionStore) all() []*session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sessions := make([]*session, 0, len(st.sessions))
+	for _, sess := range st.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+type contextKey int
+
+const (
+	profileKey contextKey = iota
+	sessionKey
+	identityKey
+	responseStreamKey
+	progressTokenKey
+)
+
+func withProfile(ctx context.Context, profileID string) context.Context {
+	return context.WithValue(ctx, profileKey, profileID)
+}
+
+// profileFromContext returns the profile every storage call made on behalf
+// of this request must be scoped to.
+func profileFromContext(ctx context.Context) string {
+	profileID, _ := ctx.Value(profileKey).(string)
+	return profileID
+}
+
+func withSession(ctx context.Context, sess *session) context.Context {
+	return context.WithValue(ctx, sessionKey, sess)
+}
+
+func sessionFromContext(ctx context.Context) *session {
+	sess, _ := ctx.Value(sessionKey).(*session)
+	return sess
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionStoreExpiresIdleSessions(t *testing.T) {
+	now := time.Now()
+	st := newSessionStore()
+	st.now = func() time.Time { return now }
+
+	idle, err := st.create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streaming, err := st.create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streaming.openStream()
+
+	now = now.Add(sessionIdleTimeout + time.Second)
+	if n := st.expire(); n != 1 {
+		t.Fatalf("expire() = %d, want 1", n)
+	}
+	if _, ok := st.get(idle.id); ok {
+		t.Error("idle session was not expired")
+	}
+	if _, ok := st.get(streaming.id); !ok {
+		t.Error("session with an open stream was expired")
+	}
+}
+
+func TestSessionStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	st := newSessionStore()
+	st.now = func() time.Time { return now }
+	st.max = 2
+
+	first, _ := st.create("alice")
+	now = now.Add(time.Second)
+	second, _ := st.create("alice")
+	now = now.Add(time.Second)
+	st.get(first.id)
+
+	now = now.Add(time.Second)
+	if _, err := st.create("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.get(second.id); ok {
+		t.Error("least recently used session was not evicted")
+	}
+	if _, ok := st.get(first.id); !ok {
+		t.Error("recently used session was evicted")
+	}
+}
+
+func TestSessionStoreRefusesWhenAllBusy(t *testing.T) {
+	st := newSessionStore()
+	st.max = 1
+
+	sess, _ := st.create("alice")
+	sess.openStream()
+	if _, err := st.create("alice"); err != errTooManySessions {
+		t.Fatalf("create() error = %v, want %v", err, errTooManySessions)
+	}
+}
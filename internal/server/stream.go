@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamKeepAlive is how often an idle GET stream sends a comment so
+// proxies do not close it.
+const streamKeepAlive = 30 * time.Second
+
+// handleStream serves a session's GET stream: server-sent events carrying
+// notifications such as notifications/resources/updated.
+func (s *MealLogServer) handleStream(w http.ResponseWriter, r *http.Request, ident *identity) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, ok := s.sessions.get(r.Header.Get("Mcp-Session-Id"))
+	if !ok || sess.subject != ident.Subject {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	stream := sess.openStream()
+	defer sess.closeStream(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-stream:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", message)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// errStreamUnsupported is returned when a POST cannot be answered with a
+// stream, so the server cannot send messages before its reply.
+var errStreamUnsupported = errors.New("client does not accept a streamed response")
+
+// responseStream answers a POST. The reply is plain JSON unless the server
+// sends messages while handling the request, such as an elicitation/create
+// request; the response then becomes server-sent events ending with the
+// reply.
+type responseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// accepts is whether the client listed text/event-stream in Accept.
+	accepts bool
+
+	mu      sync.Mutex
+	started bool
+}
+
+func newResponseStream(w http.ResponseWriter, r *http.Request) *responseStream {
+	flusher, _ := w.(http.Flusher)
+	return &responseStream{
+		w:       w,
+		flusher: flusher,
+		accepts: strings.Contains(r.Header.Get("Accept"), "text/event-stream"),
+	}
+}
+
+// send writes a message ahead of the reply, switching the response to a
+// stream.
+func (rs *responseStream) send(message interface{}) error {
+	if !rs.accepts || rs.flusher == nil {
+		return errStreamUnsupported
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.started {
+		rs.w.Header().Set("Content-Type", "text/event-stream")
+		rs.w.Header().Set("Cache-Control", "no-cache")
+		rs.w.WriteHeader(http.StatusOK)
+		rs.started = true
+	}
+	fmt.Fprintf(rs.w, "event: message\ndata: %s\n\n", data)
+	rs.flusher.Flush()
+	return nil
+}
+
+// reply writes the response to the request, closing the stream if one was
+// started.
+func (rs *responseStream) reply(response MCPResponse) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.started {
+		rs.w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rs.w).Encode(response)
+		return
+	}
+	data, _ := json.Marshal(response)
+	fmt.Fprintf(rs.w, "event: message\ndata: %s\n\n", data)
+	rs.flusher.Flush()
+}
+
+func withResponseStream(ctx context.Context, rs *responseStream) context.Context {
+	return context.WithValue(ctx, responseStreamKey, rs)
+}
+
+func responseStreamFromContext(ctx context.Context) *responseStream {
+	rs, _ := ctx.Value(responseStreamKey).(*responseStream)
+	return rs
+}
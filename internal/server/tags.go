@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"fmt"
+)
+
+func tagTools() []Tool {
+	return []Tool{
+		{
+			Name:        "list_tags",
+			Description: "List the tags used on the active profile's meals, most used first, with how many meals carry each and their average carbs",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func (s *MealLogServer) listTags(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	tags, err := s.storage.ListTags(profileFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return map[string]interface{}{"tags": tags}, nil
+}
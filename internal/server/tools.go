@@ -9,24 +9,97 @@ import (
 	"time"
 
 	"mcp-meal-log/internal/models"
+	"mcp-meal-log/internal/storage"
 )
 
 type LogMealParams struct {
 	Description string `json:"description"`
 	Timestamp   string `json:"timestamp,omitempty"`
+	// Slot overrides the meal slot inferred from the timestamp.
+	Slot      string `json:"slot,omitempty"`
+	Image     string `json:"image,omitempty"`
+	ImagePath string `json:"image_path,omitempty"`
+	// Barcode or CustomFood log a packaged product or a saved custom food
+	// instead; Grams or Servings give the amount eaten.
+	Barcode    string  `json:"barcode,omitempty"`
+	CustomFood string  `json:"custom_food,omitempty"`
+	Grams      float64 `json:"grams,omitempty"`
+	Servings   float64 `json:"servings,omitempty"`
+	// Tags, Notes and the context fields are kept with the meal however it
+	// is logged.
+	Tags       []string `json:"tags,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	Restaurant string   `json:"restaurant,omitempty"`
+	Exercise   *bool    `json:"exercise_within_2h,omitempty"`
+}
+
+// annotate copies the tags, notes and context fields onto a new meal.
+func (p *LogMealParams) annotate(meal *models.Meal) {
+	meal.Tags = p.Tags
+	meal.Notes = strings.TrimSpace(p.Notes)
+	meal.Location = strings.TrimSpace(p.Location)
+	meal.Restaurant = strings.TrimSpace(p.Restaurant)
+	meal.Exercise = p.Exercise
 }
 
 type CalculateCarbsParams struct {
 	MealDescription   string `json:"meal_description"`
 	AskClarifications bool   `json:"ask_clarifications"`
+	Image             string `json:"image,omitempty"`
+	ImagePath         string `json:"image_path,omitempty"`
+}
+
+type UpdateMealParams struct {
+	MealID      string        `json:"meal_id"`
+	Description *string       `json:"description,omitempty"`
+	Timestamp   string        `json:"timestamp,omitempty"`
+	Slot        *string       `json:"slot,omitempty"`
+	Foods       []models.Food `json:"foods,omitempty"`
+	TotalCarbs  *float64      `json:"total_carbs,omitempty"`
+	Recalculate bool          `json:"recalculate,omitempty"`
+	// Tags replaces the meal's tags; an empty string clears Notes,
+	// Location or Restaurant.
+	Tags       *[]string `json:"tags,omitempty"`
+	Notes      *string   `json:"notes,omitempty"`
+	Location   *string   `json:"location,omitempty"`
+	Restaurant *string   `json:"restaurant,omitempty"`
+	Exercise   *bool     `json:"exercise_within_2h,omitempty"`
+}
+
+type DeleteMealParams struct {
+	MealID string `json:"meal_id"`
+}
+
+type RestoreMealParams struct {
+	MealID string `json:"meal_id"`
 }
 
 type GetMealsParams struct {
-	StartDate string `json:"start_date,omitempty"`
-	EndDate   string `json:"end_date,omitempty"`
-	Limit     int    `json:"limit,omitempty"`
+	StartDate      string                   `json:"start_date,omitempty"`
+	EndDate        string                   `json:"end_date,omitempty"`
+	Limit          int                      `json:"limit,omitempty"`
+	IncludeDeleted bool                     `json:"include_deleted,omitempty"`
+	Sort           string                   `json:"sort,omitempty"`
+	Order          string                   `json:"order,omitempty"`
+	Cursor         string                   `json:"cursor,omitempty"`
+	Query          string                   `json:"query,omitempty"`
+	MinCarbs       *float64                 `json:"min_carbs,omitempty"`
+	MaxCarbs       *float64                 `json:"max_carbs,omitempty"`
+	Confidence     []models.ConfidenceLevel `json:"confidence,omitempty"`
+	Source         string                   `json:"source,omitempty"`
+	Slot           string                   `json:"slot,omitempty"`
+	Tags           []string                 `json:"tags,omitempty"`
+	TimeFrom       string                   `json:"time_from,omitempty"`
+	TimeTo         string                   `json:"time_to,omitempty"`
 }
 
+const maxMealsPerPage = 200
+
+// logMealStages is the progress total of log_meal: parsing, estimating,
+// checking and saving.
+const logMealStages = 4
+
 // helper function to convert map to struct
 func mapToStruct(data map[string]interface{}, target interface{}) error {
 	jsonBytes, err := json.Marshal(data)
@@ -36,19 +109,23 @@ func mapToStruct(data map[string]interface{}, target interface{}) error {
 	return json.Unmarshal(jsonBytes, target)
 }
 
-func (s *MealLogServer) logMeal(params map[string]interface{}) (interface{}, error) {
+func (s *MealLogServer) logMeal(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var p LogMealParams
 	if err := mapToStruct(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
-	if p.Description == "" {
-		return nil, fmt.Errorf("meal description is required")
+	image, err := loadImage(ctx, p.Image, p.ImagePath)
+	if err != nil {
+		return nil, err
 	}
+	if p.Description == "" && image == nil && p.Barcode == "" && p.CustomFood == "" {
+		return nil, fmt.Errorf("meal description, image, barcode or custom food is required")
+	}
+	reportProgress(ctx, 1, logMealStages, "Parsing the meal")
 
 	// Parse timestamp or use current time
 	var timestamp time.Time
-	var err error
 	if p.Timestamp != "" {
 		timestamp, err = time.Parse(time.RFC3339, p.Timestamp)
 		if err != nil {
@@ -57,32 +134,74 @@ func (s *MealLogServer) logMeal(params map[string]interface{}) (interface{}, err
 	} else {
 		timestamp = time.Now()
 	}
+	if p.Slot != "" {
+		if p.Slot, err = models.NormalizeSlot(p.Slot); err != nil {
+			return nil, err
+		}
+	}
+	if p.Tags, err = models.NormalizeTags(p.Tags); err != nil {
+		return nil, err
+	}
+
+	// Packaged products and custom foods have exact label values, so no
+	// estimate is needed
+	switch {
+	case p.Barcode != "":
+		return s.logProduct(ctx, p, timestamp)
+	case p.CustomFood != "":
+		return s.logCustomFood(ctx, p, timestamp)
+	}
 
 	// Use AI to calculate carbs
 	carbReq := &models.CarbCalculationRequest{
 		MealDescription:   p.Description,
 		AskClarifications: true,
+		Image:             image,
 	}
 
-	carbResp, err := s.samplingClient.CalculateCarbs(context.Background(), carbReq)
+	reportProgress(ctx, 2, logMealStages, "Estimating carbs")
+	carbResp, err := s.samplingClient.CalculateCarbs(ctx, carbReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate carbs: %w", err)
 	}
 
-	// If clarifications are needed, return them instead of logging
+	// If clarifications are needed, ask the user when the client supports
+	// it, otherwise return them instead of logging
+	description := p.Description
 	if carbResp.NeedsMoreInfo && len(carbResp.Clarifications) > 0 {
-		return map[string]interface{}{
-			"needs_clarification":  true,
-			"clarifications":       carbResp.Clarifications,
-			"preliminary_analysis": carbResp,
-		}, nil
+		answered, ok, err := s.askClarifications(ctx, p.Description, carbResp.Clarifications)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return map[string]interface{}{
+				"needs_clarification":  true,
+				"clarifications":       carbResp.Clarifications,
+				"preliminary_analysis": carbResp,
+			}, nil
+		}
+		description = answered
+		reportProgress(ctx, 2.5, logMealStages, "Estimating carbs with your answers")
+		carbResp, err = s.samplingClient.AskClarification(ctx, answered, image, carbResp.Clarifications)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate carbs: %w", err)
+		}
+	}
+	reportProgress(ctx, 3, logMealStages, "Checking the estimate")
+	if carbResp.TotalCarbs < 0 {
+		return nil, fmt.Errorf("carb estimate is negative (%g g)", carbResp.TotalCarbs)
+	}
+	if description == "" {
+		description = photoDescription(carbResp.Foods)
 	}
 
 	// Create meal entry
 	meal := &models.Meal{
 		ID:          fmt.Sprintf("meal_%d", time.Now().UnixNano()),
-		Description: p.Description,
+		ProfileID:   profileFromContext(ctx),
+		Description: description,
 		Timestamp:   timestamp,
+		Slot:        p.Slot,
 		Foods:       carbResp.Foods,
 		TotalCarbs:  carbResp.TotalCarbs,
 		Confidence:  carbResp.Confidence,
@@ -90,11 +209,23 @@ func (s *MealLogServer) logMeal(params map[string]interface{}) (interface{}, err
 		UpdatedAt:   time.Now(),
 		Source:      "ai_parsed",
 	}
+	p.annotate(meal)
+	if image != nil {
+		meal.Photo = newMealPhoto(image)
+		meal.PhotoSHA256 = meal.Photo.SHA256
+	}
 
-	// Save to storage
-	if err := s.storage.SaveMeal(meal); err != nil {
+	return s.saveLoggedMeal(ctx, meal, "ai")
+}
+
+// saveLoggedMeal stores a meal created by log_meal and passes it on to
+// subscribers and the knowledge graph.
+func (s *MealLogServer) saveLoggedMeal(ctx context.Context, meal *models.Meal, origin string) (interface{}, error) {
+	reportProgress(ctx, logMealStages, logMealStages, "Saving the meal")
+	if err := s.storage.SaveMeal(meal, changeSource(ctx, "log_meal", origin)); err != nil {
 		return nil, fmt.Errorf("failed to save meal: %w", err)
 	}
+	s.mealsChanged(meal.ProfileID, meal)
 
 	// Add to knowledge graph via memory MCP server
 	if err := s.addMealToKnowledgeGraph(meal); err != nil {
@@ -105,22 +236,27 @@ func (s *MealLogServer) logMeal(params map[string]interface{}) (interface{}, err
 	return meal, nil
 }
 
-func (s *MealLogServer) calculateCarbs(params map[string]interface{}) (interface{}, error) {
+func (s *MealLogServer) calculateCarbs(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var p CalculateCarbsParams
 	if err := mapToStruct(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
-	if p.MealDescription == "" {
-		return nil, fmt.Errorf("meal description is required")
+	image, err := loadImage(ctx, p.Image, p.ImagePath)
+	if err != nil {
+		return nil, err
+	}
+	if p.MealDescription == "" && image == nil {
+		return nil, fmt.Errorf("meal description or image is required")
 	}
 
 	carbReq := &models.CarbCalculationRequest{
 		MealDescription:   p.MealDescription,
 		AskClarifications: p.AskClarifications,
+		Image:             image,
 	}
 
-	result, err := s.samplingClient.CalculateCarbs(context.Background(), carbReq)
+	result, err := s.samplingClient.CalculateCarbs(ctx, carbReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate carbs: %w", err)
 	}
@@ -128,7 +264,179 @@ func (s *MealLogServer) calculateCarbs(params map[string]interface{}) (interface
 	return result, nil
 }
 
-func (s *MealLogServer) getMeals(params map[string]interface{}) (interface{}, error) {
+func (s *MealLogServer) updateMeal(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p UpdateMealParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" {
+		return nil, fmt.Errorf("meal_id is required")
+	}
+
+	meal, err := s.storage.GetMeal(profileFromContext(ctx), p.MealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meal: %w", err)
+	}
+	before := *meal
+
+	origin := "manual"
+	if p.Description != nil {
+		if *p.Description == "" {
+			return nil, fmt.Errorf("meal description cannot be empty")
+		}
+		meal.Description = *p.Description
+	}
+	if p.Timestamp != "" {
+		if meal.Timestamp, err = time.Parse(time.RFC3339, p.Timestamp); err != nil {
+			return nil, fmt.Errorf("invalid timestamp format: %w", err)
+		}
+	}
+	switch {
+	case p.Slot != nil && *p.Slot == "":
+		// Infer the slot from the timestamp again
+		meal.Slot = ""
+	case p.Slot != nil:
+		if meal.Slot, err = models.NormalizeSlot(*p.Slot); err != nil {
+			return nil, err
+		}
+	case !meal.Timestamp.Equal(before.Timestamp):
+		// A slot that came from the old time follows the new one
+		settings, err := s.storage.GetSettings(meal.ProfileID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load settings: %w", err)
+		}
+		loc, err := settings.Location()
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+		}
+		if before.Slot == settings.MealSlot(before.Timestamp.In(loc)) {
+			meal.Slot = ""
+		}
+	}
+
+	if p.Recalculate {
+		// Photo meals are estimated from the thumbnail of their photo, so
+		// the new estimate only differs where the description does
+		image, err := s.storedImage(meal)
+		if err != nil {
+			return nil, err
+		}
+		carbResp, err := s.samplingClient.CalculateCarbs(ctx, &models.CarbCalculationRequest{
+			MealDescription:   meal.Description,
+			AskClarifications: false,
+			Image:             image,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate carbs: %w", err)
+		}
+		if image != nil {
+			thumbnailEstimate(carbResp)
+		}
+		meal.Foods = carbResp.Foods
+		meal.TotalCarbs = carbResp.TotalCarbs
+		meal.Confidence = carbResp.Confidence
+		origin = "ai"
+	}
+
+	if p.Foods != nil {
+		meal.Foods = p.Foods
+		meal.TotalCarbs = 0
+		for _, food := range p.Foods {
+			meal.TotalCarbs += food.EstimatedCarbs
+		}
+		if confidence := models.LowestConfidence(p.Foods); confidence != "" {
+			meal.Confidence = confidence
+		}
+	}
+	if p.TotalCarbs != nil {
+		if *p.TotalCarbs < 0 {
+			return nil, fmt.Errorf("total carbs cannot be negative")
+		}
+		meal.TotalCarbs = *p.TotalCarbs
+	}
+
+	if p.Tags != nil {
+		if meal.Tags, err = models.NormalizeTags(*p.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if p.Notes != nil {
+		meal.Notes = strings.TrimSpace(*p.Notes)
+	}
+	if p.Location != nil {
+		meal.Location = strings.TrimSpace(*p.Location)
+	}
+	if p.Restaurant != nil {
+		meal.Restaurant = strings.TrimSpace(*p.Restaurant)
+	}
+	if p.Exercise != nil {
+		meal.Exercise = p.Exercise
+	}
+	meal.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateMeal(meal, changeSource(ctx, "update_meal", origin)); err != nil {
+		return nil, fmt.Errorf("failed to update meal: %w", err)
+	}
+	s.mealsChanged(meal.ProfileID, &before, meal)
+
+	return meal, nil
+}
+
+func (s *MealLogServer) deleteMeal(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p DeleteMealParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" {
+		return nil, fmt.Errorf("meal_id is required")
+	}
+
+	profileID := profileFromContext(ctx)
+	meal, _ := s.storage.GetMeal(profileID, p.MealID)
+	if err := s.storage.DeleteMeal(profileID, p.MealID, changeSource(ctx, "delete_meal", "manual")); err != nil {
+		return nil, fmt.Errorf("failed to delete meal: %w", err)
+	}
+	s.mealsChanged(profileID, meal)
+
+	return map[string]interface{}{
+		"deleted": true,
+		"meal_id": p.MealID,
+		"message": "Meal moved to the trash; use restore_meal to undo",
+	}, nil
+}
+
+func (s *MealLogServer) restoreMeal(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var p RestoreMealParams
+	if err := mapToStruct(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.MealID == "" {
+		return nil, fmt.Errorf("meal_id is required")
+	}
+
+	meal, err := s.storage.RestoreMeal(profileFromContext(ctx), p.MealID, changeSource(ctx, "restore_meal", "manual"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore meal: %w", err)
+	}
+	s.mealsChanged(meal.ProfileID, meal)
+
+	return meal, nil
+}
+
+// changeSource records which tool changed a meal, whether the values came
+// from the model or the user, and who called it.
+func changeSource(ctx context.Context, tool, origin string) models.ChangeSource {
+	return models.ChangeSource{
+		Tool:   tool,
+		Origin: origin,
+		Actor:  identityFromContext(ctx).String(),
+	}
+}
+
+func (s *MealLogServer) getMeals(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var p GetMealsParams
 	if err := mapToStruct(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid parameters: %w", err)
@@ -138,13 +446,47 @@ func (s *MealLogServer) getMeals(params map[string]interface{}) (interface{}, er
 	if p.Limit <= 0 {
 		p.Limit = 20
 	}
+	if p.Limit > maxMealsPerPage {
+		p.Limit = maxMealsPerPage
+	}
+	if p.Order != "" && p.Order != "asc" && p.Order != "desc" {
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	if p.Slot != "" {
+		slot, err := models.NormalizeSlot(p.Slot)
+		if err != nil {
+			return nil, err
+		}
+		p.Slot = slot
+	}
+	tags, err := models.NormalizeTags(p.Tags)
+	if err != nil {
+		return nil, err
+	}
 
-	meals, err := s.storage.GetMeals(p.StartDate, p.EndDate, p.Limit)
+	page, err := s.storage.GetMeals(profileFromContext(ctx), storage.MealQuery{
+		StartDate:      p.StartDate,
+		EndDate:        p.EndDate,
+		Limit:          p.Limit,
+		IncludeDeleted: p.IncludeDeleted,
+		Sort:           p.Sort,
+		Ascending:      p.Order == "asc",
+		Cursor:         p.Cursor,
+		Text:           p.Query,
+		MinCarbs:       p.MinCarbs,
+		MaxCarbs:       p.MaxCarbs,
+		Confidence:     p.Confidence,
+		Source:         p.Source,
+		Slot:           p.Slot,
+		Tags:           tags,
+		TimeFrom:       p.TimeFrom,
+		TimeTo:         p.TimeTo,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve meals: %w", err)
 	}
 
-	return meals, nil
+	return page, nil
 }
 
 func (s *MealLogServer) addMealToKnowledgeGraph(meal *models.Meal) error {
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// auditTriggers make meal_audit append-only. Rekey drops and recreates them
+// because re-encrypting snapshots is the one legitimate rewrite.
+const auditTriggers = `
+    CREATE TRIGGER IF NOT EXISTS meal_audit_no_update BEFORE UPDATE ON meal_audit
+    BEGIN
+        SELECT RAISE(ABORT, 'meal_audit is append-only');
+    END;
+
+    CREATE TRIGGER IF NOT EXISTS meal_audit_no_delete BEFORE DELETE ON meal_audit
+    BEGIN
+        SELECT RAISE(ABORT, 'meal_audit is append-only');
+    END;
+`
+
+const dropAuditTriggers = `
+    DROP TRIGGER IF EXISTS meal_audit_no_update;
+    DROP TRIGGER IF EXISTS meal_audit_no_delete;
+`
+
+// writeAudit appends a revision with JSON snapshots of the meal before and
+// after the change. Snapshots contain descriptions and food names, so they
+// are encrypted like the columns they copy.
+func (s *sqlStore) writeAudit(tx *sqlTx, profileID, mealID string, action models.AuditAction, before, after *models.Meal, change models.ChangeSource) error {
+	beforeJSON, err := s.snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := s.snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO meal_audit (meal_id, profile_id, action, before_json, after_json, tool, origin, actor, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, mealID, profileID, string(action), beforeJSON, afterJSON, change.Tool, change.Origin, change.Actor, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) snapshot(meal *models.Meal) (sql.NullString, error) {
+	if meal == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(meal)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode meal snapshot: %w", err)
+	}
+	sealed, err := s.seal(string(data))
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: sealed, Valid: true}, nil
+}
+
+func (s *sqlStore) parseSnapshot(value sql.NullString) (*models.Meal, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	data, err := s.open(value.String)
+	if err != nil {
+		return nil, err
+	}
+	meal := &models.Meal{}
+	if err := json.Unmarshal([]byte(data), meal); err != nil {
+		return nil, fmt.Errorf("failed to decode meal snapshot: %w", err)
+	}
+	return meal, nil
+}
+
+// GetMealHistory returns every revision of a meal, oldest first.
+func (s *sqlStore) GetMealHistory(profileID, mealID string) ([]*models.MealRevision, error) {
+	rows, err := s.db.Query(`
+        SELECT id, meal_id, profile_id, action, before_json, after_json, tool, origin, actor, created_at
+        FROM meal_audit
+        WHERE meal_id = ? AND profile_id = ?
+        ORDER BY id
+    `, mealID, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meal history: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*models.MealRevision
+	for rows.Next() {
+		revision, err := s.scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query meal history: %w", err)
+	}
+	if len(revisions) == 0 {
+		return nil, fmt.Errorf("meal %s: %w", mealID, ErrNotFound)
+	}
+	return revisions, nil
+}
+
+func (s *sqlStore) scanRevision(row rowScanner) (*models.MealRevision, error) {
+	revision := &models.MealRevision{}
+	var action, createdAtStr string
+	var beforeJSON, afterJSON sql.NullString
+	err := row.Scan(&revision.ID, &revision.MealID, &revision.ProfileID, &action, &beforeJSON, &afterJSON,
+		&revision.Source.Tool, &revision.Source.Origin, &revision.Source.Actor, &createdAtStr)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+	}
+
+	revision.Action = models.AuditAction(action)
+	if revision.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	if revision.Before, err = s.parseSnapshot(beforeJSON); err != nil {
+		return nil, err
+	}
+	if revision.After, err = s.parseSnapshot(afterJSON); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// RestoreMealRevision puts a meal back into the state recorded by the given
+// revision, re-creating it if it has been deleted since.
+func (s *sqlStore) RestoreMealRevision(profileID, mealID string, revisionID int64, change models.ChangeSource) (*models.Meal, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	revision, err := s.scanRevision(tx.QueryRow(`
+        SELECT id, meal_id, profile_id, action, before_json, after_json, tool, origin, actor, created_at
+        FROM meal_audit
+        WHERE id = ? AND meal_id = ? AND profile_id = ?
+    `, revisionID, mealID, profileID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision %d of meal %s: %w", revisionID, mealID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revision.After == nil {
+		return nil, fmt.Errorf("revision %d records a purge; restore an earlier revision", revisionID)
+	}
+
+	// A restored meal is always live, even when the revision put it in the trash
+	restored := revision.After
+	restored.ProfileID = profileID
+	restored.UpdatedAt = time.Now()
+	restored.DeletedAt = nil
+
+	current, err := s.getMeal(tx, profileID, mealID)
+	switch {
+	case err == nil:
+		if err := s.replaceMeal(tx, restored); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, ErrNotFound):
+		current = nil
+		if err := s.insertMeal(tx, restored); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.writeAudit(tx, profileID, mealID, models.AuditRestore, current, restored, change); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
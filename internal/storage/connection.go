@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Pragma is a SQLite setting applied to every connection when it opens.
+type Pragma struct {
+	Name  string
+	Value string
+}
+
+// DefaultPragmas let the HTTP handlers share one database. busy_timeout
+// makes a writer wait for another instead of failing with SQLITE_BUSY, WAL
+// lets readers run alongside a writer, and foreign_keys enables the ON
+// DELETE CASCADE clauses of the schema. busy_timeout comes first so the
+// switch to WAL also waits for other connections.
+var DefaultPragmas = []Pragma{
+	{"busy_timeout", "5000"},
+	{"journal_mode", "WAL"},
+	{"foreign_keys", "ON"},
+}
+
+// DefaultMaxOpenConns bounds the connection pool. With WAL, readers use
+// their own connections while writes are serialized by SQLite.
+const DefaultMaxOpenConns = 8
+
+var (
+	pragmaName  = regexp.MustCompile(`^[a-z_]+$`)
+	pragmaValue = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// ParsePragma parses a name=value setting such as synchronous=NORMAL.
+func ParsePragma(s string) (Pragma, error) {
+	name, value, ok := strings.Cut(s, "=")
+	p := Pragma{Name: strings.ToLower(strings.TrimSpace(name)), Value: strings.TrimSpace(value)}
+	if !ok || !pragmaName.MatchString(p.Name) || !pragmaValue.MatchString(p.Value) {
+		return Pragma{}, fmt.Errorf("invalid pragma %q, expected name=value", s)
+	}
+	return p, nil
+}
+
+// WithPragmas applies extra pragmas to every SQLite connection. A pragma
+// named in DefaultPragmas replaces the default value. PostgreSQL ignores
+// them.
+func WithPragmas(pragmas ...Pragma) Option {
+	return func(s *sqlStore) {
+		for _, p := range pragmas {
+			s.setPragma(p)
+		}
+	}
+}
+
+// WithMaxOpenConns sets the size of the connection pool.
+func WithMaxOpenConns(n int) Option {
+	return func(s *sqlStore) {
+		if n > 0 {
+			s.maxOpenConns = n
+		}
+	}
+}
+
+func (s *sqlStore) setPragma(p Pragma) {
+	for i := range s.pragmas {
+		if s.pragmas[i].Name == p.Name {
+			s.pragmas[i] = p
+			return
+		}
+	}
+	s.pragmas = append(s.pragmas, p)
+}
+
+// dsn adds the connection settings to dbPath. The driver writes time.Time
+// values in a format SQLite's date functions understand instead of Go's
+// default String() form, and transactions take the write lock when they
+// begin, so busy_timeout applies instead of a read transaction failing
+// when it later tries to write.
+func (s *SQLiteStorage) dsn(dbPath string) string {
+	params := url.Values{}
+	for _, p := range s.pragmas {
+		params.Add("_pragma", p.Name+"("+p.Value+")")
+	}
+	if !strings.Contains(dbPath, "_time_format=") {
+		params.Set("_time_format", "sqlite")
+	}
+	if !strings.Contains(dbPath, "_txlock=") {
+		params.Set("_txlock", "immediate")
+	}
+
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + params.Encode()
+}
+
+// inMemory reports whether dbPath is an in-memory database, which exists
+// once per connection and so cannot be pooled.
+func inMemory(dbPath string) bool {
+	return dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory")
+}
+
+func (s *SQLiteStorage) configurePool(dbPath string) {
+	conns := s.maxOpenConns
+	if inMemory(dbPath) {
+		conns = 1
+	}
+	s.db.SetMaxOpenConns(conns)
+	s.db.SetMaxIdleConns(conns)
+}
+
+// checkIntegrity runs SQLite's quick_check, which finds corrupted pages
+// and records in time linear in the database size, and refuses to start
+// on a damaged database. Foreign key violations left by versions that ran
+// without foreign_keys are only logged.
+func (s *SQLiteStorage) checkIntegrity() error {
+	problems, err := s.pragmaRows(`PRAGMA quick_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	if err := integrityError(problems); err != nil {
+		return err
+	}
+
+	violations, err := s.pragmaRows(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	// Each violation starts with the table holding the dangling reference
+	tables := map[string]int{}
+	for _, v := range violations {
+		table, _, _ := strings.Cut(v, " ")
+		tables[table]++
+	}
+	for table, n := range tables {
+		log.Printf("Warning: %d rows in %s refer to missing records", n, table)
+	}
+	return nil
+}
+
+// integrityError reports the problems found by an integrity check, if any.
+func integrityError(problems []string) error {
+	if len(problems) == 1 && problems[0] == "ok" {
+		return nil
+	}
+	if len(problems) > 5 {
+		problems = append(problems[:5], fmt.Sprintf("and %d more", len(problems)-5))
+	}
+	return fmt.Errorf("database failed integrity check: %s", strings.Join(problems, "; "))
+}
+
+// VerifyFile runs SQLite's full integrity_check on the database at path
+// without modifying it and checks that it holds a meal log, e.g. before a
+// backup is restored over the live database.
+func VerifyFile(path string) error {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	s := &SQLiteStorage{&sqlStore{db: &sqlDB{DB: db, dialect: sqliteDialect{}}, dialect: sqliteDialect{}}}
+
+	problems, err := s.pragmaRows(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	if err := integrityError(problems); err != nil {
+		return err
+	}
+
+	var tables int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('meals', 'foods')`).Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	if tables != 2 {
+		return fmt.Errorf("%s is not a meal log database", path)
+	}
+	return nil
+}
+
+// pragmaRows returns the rows of a pragma, each with its columns joined by
+// spaces.
+func (s *SQLiteStorage) pragmaRows(query string) ([]string, error) {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprint(*v.(*interface{}))
+		}
+		result = append(result, strings.Join(parts, " "))
+	}
+	return result, rows.Err()
+}
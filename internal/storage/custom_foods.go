@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// SaveCustomFood inserts a custom food or replaces the one with the same ID.
+// The name is encrypted like food names in meals; the label values are
+// stored as JSON.
+func (s *sqlStore) SaveCustomFood(food *models.CustomFood) error {
+	name, err := s.seal(food.Name)
+	if err != nil {
+		return err
+	}
+	labelJSON, err := json.Marshal(food.NutritionLabel)
+	if err != nil {
+		return fmt.Errorf("failed to encode label: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+        INSERT INTO custom_foods (id, profile_id, name, label, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT (id) DO UPDATE SET name = excluded.name, label = excluded.label, updated_at = excluded.updated_at
+        WHERE custom_foods.profile_id = excluded.profile_id
+    `, food.ID, food.ProfileID, name, string(labelJSON), food.CreatedAt.UTC(), food.UpdatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save custom food: %w", err)
+	}
+	return nil
+}
+
+// ListCustomFoods returns a profile's custom foods, oldest first.
+func (s *sqlStore) ListCustomFoods(profileID string) ([]*models.CustomFood, error) {
+	rows, err := s.db.Query(`
+        SELECT id, profile_id, name, label, created_at, updated_at
+        FROM custom_foods
+        WHERE profile_id = ?
+        ORDER BY created_at, id
+    `, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom foods: %w", err)
+	}
+	defer rows.Close()
+
+	var foods []*models.CustomFood
+	for rows.Next() {
+		food := &models.CustomFood{}
+		var labelJSON, createdAt, updatedAt string
+		if err := rows.Scan(&food.ID, &food.ProfileID, &food.Name, &labelJSON, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan custom food: %w", err)
+		}
+		if food.Name, err = s.open(food.Name); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(labelJSON), &food.NutritionLabel); err != nil {
+			return nil, fmt.Errorf("failed to parse label: %w", err)
+		}
+		if food.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("failed to parse created_at: %w", err)
+		}
+		if food.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+		}
+		foods = append(foods, food)
+	}
+	return foods, rows.Err()
+}
+
+// DeleteCustomFood removes a custom food of a profile, or returns ErrNotFound.
+func (s *sqlStore) DeleteCustomFood(profileID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM custom_foods WHERE profile_id = ? AND id = ?`, profileID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete custom food: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("custom food %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"mcp-meal-log/internal/encryption"
+)
+
+const metaEncryptionKeyID = "encryption_key_id"
+
+// encryptedColumns lists every text column that holds sensitive data and is
+// encrypted when a cipher is configured.
+var encryptedColumns = []struct{ table, key, column string }{
+	{"meals", "id", "description"},
+	{"meals", "id", "notes"},
+	{"meals", "id", "location"},
+	{"meals", "id", "restaurant"},
+	{"tags", "id", "name"},
+	{"foods", "id", "name"},
+	{"meal_photos", "meal_id", "thumbnail"},
+	{"custom_foods", "id", "name"},
+	{"meal_audit", "id", "before_json"},
+	{"meal_audit", "id", "after_json"},
+}
+
+// seal encrypts a sensitive value when encryption is enabled.
+func (s *sqlStore) seal(value string) (string, error) {
+	if s.cipher == nil {
+		return value, nil
+	}
+	sealed, err := s.cipher.Encrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt value: %w", err)
+	}
+	return sealed, nil
+}
+
+// open decrypts a value written by seal. Plaintext passes through unchanged.
+func (s *sqlStore) open(value string) (string, error) {
+	if s.cipher == nil {
+		return value, nil
+	}
+	plaintext, err := s.cipher.Decrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// sealOptional is seal for an optional value, which is stored as NULL when
+// empty.
+func (s *sqlStore) sealOptional(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return s.seal(value)
+}
+
+// openOptional decrypts a value written by sealOptional.
+func (s *sqlStore) openOptional(value sql.NullString) (string, error) {
+	if !value.Valid {
+		return "", nil
+	}
+	return s.open(value.String)
+}
+
+func (s *sqlStore) getMeta(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// checkEncryptionKey refuses to open an encrypted database without its key
+// (or with a different one), and encrypts existing rows the first time a
+// key is configured.
+func (s *sqlStore) checkEncryptionKey() error {
+	stored, err := s.getMeta(metaEncryptionKeyID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stored == "" && s.cipher == nil:
+		return nil
+	case s.cipher == nil:
+		return fmt.Errorf("database is encrypted with key %s; configure the encryption key", stored)
+	case stored == s.cipher.KeyID():
+		return nil
+	case stored != "":
+		return fmt.Errorf("encryption key %s does not match database key %s; use 'meal-log rekey' to change keys", s.cipher.KeyID(), stored)
+	}
+
+	log.Printf("Encrypting existing records with key %s", s.cipher.KeyID())
+	return s.Rekey(s.cipher)
+}
+
+// Rekey re-encrypts every sensitive column with newCipher, reading values
+// with the current cipher. A nil newCipher decrypts the database.
+func (s *sqlStore) Rekey(newCipher *encryption.FieldCipher) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The audit trail is append-only except for re-encryption
+	if _, err := tx.Exec(s.dialect.unlockAudit()); err != nil {
+		return fmt.Errorf("failed to unlock audit trail: %w", err)
+	}
+
+	next := &sqlStore{dialect: s.dialect, cipher: newCipher}
+	for _, c := range encryptedColumns {
+		if err := s.rekeyColumn(tx, next, c.table, c.key, c.column); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(s.dialect.lockAudit()); err != nil {
+		return fmt.Errorf("failed to lock audit trail: %w", err)
+	}
+
+	// Only a plaintext database keeps a search index
+	if newCipher == nil {
+		err = next.rebuildSearchIndex(tx)
+	} else {
+		err = s.clearSearchIndex(tx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if newCipher == nil {
+		_, err = tx.Exec(`DELETE FROM meta WHERE key = ?`, metaEncryptionKeyID)
+	} else {
+		_, err = tx.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)
+            ON CONFLICT(key) DO UPDATE SET value = excluded.value`, metaEncryptionKeyID, newCipher.KeyID())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record encryption key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.cipher = newCipher
+
+	// The old values are still in free pages until they are overwritten
+	if err := s.dialect.scrub(s.db); err != nil {
+		return fmt.Errorf("failed to remove old values from the database files: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) rekeyColumn(tx *sqlTx, next *sqlStore, table, key, column string) error {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT %s, %s FROM %s`, key, column, table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+	}
+
+	updates := map[interface{}]string{}
+	for rows.Next() {
+		var id interface{}
+		var value sql.NullString
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan %s.%s: %w", table, column, err)
+		}
+		if !value.Valid {
+			continue
+		}
+		if s.cipher == nil && encryption.IsEncrypted(value.String) {
+			rows.Close()
+			return fmt.Errorf("%s.%s contains encrypted values but no current key is configured", table, column)
+		}
+		plaintext, err := s.open(value.String)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		sealed, err := next.seal(plaintext)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		updates[id] = sealed
+	}
+	rows.Close()
+
+	query := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ?`, table, column, key)
+	for id, value := range updates {
+		if _, err := tx.Exec(query, value, id); err != nil {
+			return fmt.Errorf("failed to update %s.%s: %w", table, column, err)
+		}
+	}
+	return nil
+}
+
+// BackupTo writes a consistent copy of the database to path using
+// VACUUM INTO, which is safe while the server keeps serving requests.
+func (s *SQLiteStorage) BackupTo(path string) error {
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Encrypting a plaintext database must not leave the old values readable
+// in free pages, the WAL or the search index.
+func TestRekeyLeavesNoPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meals.db")
+	s, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meals := testMeals(models.DefaultProfileID, 200, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	for _, meal := range meals {
+		meal.Description = "quokkaberry crumble"
+		meal.Notes = "zanzibar picnic"
+	}
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening with a key encrypts the existing rows
+	s, err = NewSQLiteStorage(path, WithCipher(testCipher(t, 7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, name := range []string{path, path + "-wal"} {
+		data, err := os.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, plaintext := range []string{"quokkaberry", "zanzibar", "toast"} {
+			if bytes.Contains(bytes.ToLower(data), []byte(plaintext)) {
+				t.Errorf("%s still contains %q", filepath.Base(name), plaintext)
+			}
+		}
+	}
+
+	got, err := s.GetMeal(models.DefaultProfileID, meals[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "quokkaberry crumble" || got.Notes != "zanzibar picnic" {
+		t.Errorf("meal after rekey = %q, %q", got.Description, got.Notes)
+	}
+}
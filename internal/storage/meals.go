@@ -0,0 +1,873 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// backfillLocalDates rewrites meals saved before timestamps were normalised
+// to UTC and fills in their profile-local date, time of day and slot.
+func (s *sqlStore) backfillLocalDates() error {
+	rows, err := s.db.Query(`
+        SELECT id, profile_id, timestamp, created_at, updated_at
+        FROM meals
+        WHERE local_date IS NULL OR local_minutes IS NULL OR slot IS NULL
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to query meals for backfill: %w", err)
+	}
+
+	type legacyMeal struct {
+		id, profileID                   string
+		timestamp, createdAt, updatedAt time.Time
+	}
+	var pending []legacyMeal
+	for rows.Next() {
+		var m legacyMeal
+		if err := rows.Scan(&m.id, &m.profileID, &m.timestamp, &m.createdAt, &m.updatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan meal for backfill: %w", err)
+		}
+		pending = append(pending, m)
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type clock struct {
+		settings *models.ProfileSettings
+		loc      *time.Location
+	}
+	clocks := map[string]clock{}
+	for _, m := range pending {
+		c, ok := clocks[m.profileID]
+		if !ok {
+			if c.settings, c.loc, err = s.profileClock(tx, m.profileID); err != nil {
+				return err
+			}
+			clocks[m.profileID] = c
+		}
+		_, err := tx.Exec(`
+            UPDATE meals SET timestamp = ?, created_at = ?, updated_at = ?, local_date = ?, local_minutes = ?,
+                slot = COALESCE(slot, ?)
+            WHERE id = ?
+        `, m.timestamp.UTC(), m.createdAt.UTC(), m.updatedAt.UTC(), localDate(m.timestamp, c.loc),
+			localMinutes(m.timestamp, c.loc), c.settings.MealSlot(m.timestamp.In(c.loc)), m.id)
+		if err != nil {
+			return fmt.Errorf("failed to backfill meal %s: %w", m.id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// localDate is the calendar day a meal belongs to in its profile's timezone.
+// Date range filters compare against it so they follow the user's day rather
+// than UTC.
+func localDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02")
+}
+
+// localMinutes is the meal's time of day in its profile's timezone, in
+// minutes after midnight, for slot and time-of-day filters.
+func localMinutes(t time.Time, loc *time.Location) int {
+	return models.MinutesOfDay(t.In(loc))
+}
+
+// mealColumns is the column list scanMeal expects.
+const mealColumns = `id, profile_id, description, timestamp, slot, total_carbs, confidence, created_at, updated_at, source, deleted_at, photo_sha256,
+    notes, location, restaurant, exercise`
+
+// MealQuery selects meals for GetMeals. Dates are YYYY-MM-DD in the
+// profile's timezone.
+type MealQuery struct {
+	// StartDate and EndDate are inclusive local dates (YYYY-MM-DD).
+	StartDate string
+	EndDate   string
+	// From and To bound the meal timestamp; From is inclusive and To is
+	// exclusive. Zero values leave that side open.
+	From  time.Time
+	To    time.Time
+	Limit int
+	// IncludeDeleted also returns meals that are in the trash.
+	IncludeDeleted bool
+	// Sort is SortTimestamp (default) or SortCarbs, newest or largest
+	// first unless Ascending is set.
+	Sort      string
+	Ascending bool
+	// Cursor continues from the NextCursor of a previous page of the same
+	// query.
+	Cursor string
+
+	// Text matches words in the description or food names, as prefixes.
+	Text string
+	// MinCarbs and MaxCarbs bound the meal's total carbs, inclusive.
+	MinCarbs *float64
+	MaxCarbs *float64
+	// Confidence keeps meals with any of the listed levels.
+	Confidence []models.ConfidenceLevel
+	Source     string
+	// Slot is a meal slot such as models.SlotBreakfast or a profile's own.
+	Slot string
+	// Tags keeps meals carrying every listed tag; names must be normalized
+	// with models.NormalizeTag.
+	Tags []string
+	// TimeFrom and TimeTo limit the local time of day (HH:MM); TimeFrom is
+	// inclusive, TimeTo exclusive, and the window may wrap past midnight.
+	TimeFrom string
+	TimeTo   string
+}
+
+// mealFilter returns the conditions shared by every meal listing, for the
+// meals table referred to as table.
+func (s *sqlStore) mealFilter(profileID string, q MealQuery, table string) (string, []interface{}, error) {
+	var where string
+	var args []interface{}
+	if !q.IncludeDeleted {
+		where += " AND " + table + ".deleted_at IS NULL"
+	}
+	if q.StartDate != "" {
+		where += " AND " + table + ".local_date >= ?"
+		args = append(args, q.StartDate)
+	}
+	if q.EndDate != "" {
+		where += " AND " + table + ".local_date <= ?"
+		args = append(args, q.EndDate)
+	}
+	if !q.From.IsZero() {
+		where += " AND " + table + ".timestamp >= ?"
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where += " AND " + table + ".timestamp < ?"
+		args = append(args, q.To.UTC())
+	}
+
+	if text := s.dialect.searchQuery(q.Text); text != "" {
+		if s.cipher != nil {
+			return "", nil, ErrSearchUnavailable
+		}
+		where += " AND " + s.dialect.searchFilter(table)
+		args = append(args, text, profileID)
+	}
+	if q.MinCarbs != nil {
+		where += " AND " + table + ".total_carbs >= ?"
+		args = append(args, *q.MinCarbs)
+	}
+	if q.MaxCarbs != nil {
+		where += " AND " + table + ".total_carbs <= ?"
+		args = append(args, *q.MaxCarbs)
+	}
+	if len(q.Confidence) > 0 {
+		placeholders := make([]string, len(q.Confidence))
+		for i, level := range q.Confidence {
+			placeholders[i] = "?"
+			args = append(args, string(level))
+		}
+		where += " AND " + table + ".confidence IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if q.Source != "" {
+		where += " AND " + table + ".source = ?"
+		args = append(args, q.Source)
+	}
+
+	if q.Slot != "" {
+		where += " AND " + table + ".slot = ?"
+		args = append(args, q.Slot)
+	}
+	if q.TimeFrom != "" || q.TimeTo != "" {
+		cond, timeArgs, err := timeOfDayCondition(q.TimeFrom, q.TimeTo, table+".local_minutes")
+		if err != nil {
+			return "", nil, err
+		}
+		where += " AND " + cond
+		args = append(args, timeArgs...)
+	}
+
+	if len(q.Tags) > 0 {
+		// Tag names may be encrypted, so they are matched to IDs here
+		ids, err := s.tagIDs(s.db, profileID)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, tag := range q.Tags {
+			// An unknown tag matches no meals, as no tag has ID 0
+			where += " AND " + table + ".id IN (SELECT meal_id FROM meal_tags WHERE profile_id = ? AND tag_id = ?)"
+			args = append(args, profileID, ids[tag])
+		}
+	}
+	return where, args, nil
+}
+
+// windowCondition matches local times of day inside w.
+func windowCondition(w models.SlotWindow, column string) (string, []interface{}) {
+	if w.Start <= w.End {
+		return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{w.Start, w.End}
+	}
+	return "(" + column + " >= ? OR " + column + " < ?)", []interface{}{w.Start, w.End}
+}
+
+// timeOfDayCondition matches local times of day from from (inclusive) to to
+// (exclusive), both HH:MM. A window ending before it starts wraps past
+// midnight, so 21:00 to 03:00 finds late-night meals.
+func timeOfDayCondition(from, to, column string) (string, []interface{}, error) {
+	w := models.SlotWindow{Start: 0, End: 24 * 60}
+	var err error
+	if from != "" {
+		if w.Start, err = models.ParseClock(from); err != nil {
+			return "", nil, err
+		}
+	}
+	if to != "" {
+		if w.End, err = models.ParseClock(to); err != nil {
+			return "", nil, err
+		}
+	}
+	cond, args := windowCondition(w, column)
+	return cond, args, nil
+}
+
+func (s *sqlStore) SaveMeal(meal *models.Meal, change models.ChangeSource) error {
+	return s.SaveMeals([]*models.Meal{meal}, change)
+}
+
+// SaveMeals saves several meals in one transaction, so a batch is stored
+// either completely or not at all. Imports use it to avoid a transaction
+// per meal.
+func (s *sqlStore) SaveMeals(meals []*models.Meal, change models.ChangeSource) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, meal := range meals {
+		if meal.ProfileID == "" {
+			meal.ProfileID = models.DefaultProfileID
+		}
+		if err := s.insertMeal(tx, meal); err != nil {
+			return err
+		}
+		if err := s.writeAudit(tx, meal.ProfileID, meal.ID, models.AuditCreate, nil, meal, change); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) insertMeal(tx *sqlTx, meal *models.Meal) error {
+	settings, loc, err := s.profileClock(tx, meal.ProfileID)
+	if err != nil {
+		return err
+	}
+	if meal.Slot == "" {
+		meal.Slot = settings.MealSlot(meal.Timestamp.In(loc))
+	}
+
+	description, err := s.seal(meal.Description)
+	if err != nil {
+		return err
+	}
+	notes, location, restaurant, err := s.sealContext(meal)
+	if err != nil {
+		return err
+	}
+
+	// Insert meal
+	mealQuery := `
+        INSERT INTO meals (id, profile_id, description, timestamp, local_date, local_minutes, slot, total_carbs, confidence, created_at, updated_at, source, deleted_at, photo_sha256,
+            notes, location, restaurant, exercise)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+	_, err = tx.Exec(mealQuery,
+		meal.ID, meal.ProfileID, description, meal.Timestamp.UTC(), localDate(meal.Timestamp, loc),
+		localMinutes(meal.Timestamp, loc), meal.Slot, meal.TotalCarbs, string(meal.Confidence), meal.CreatedAt.UTC(),
+		meal.UpdatedAt.UTC(), meal.Source, nullTime(meal.DeletedAt), nullString(meal.PhotoSHA256),
+		notes, location, restaurant, nullBool(meal.Exercise))
+	if err != nil {
+		return fmt.Errorf("failed to insert meal: %w", err)
+	}
+
+	if err := s.insertFoods(tx, meal); err != nil {
+		return err
+	}
+	if err := s.insertTags(tx, meal); err != nil {
+		return err
+	}
+	if err := s.insertPhoto(tx, meal); err != nil {
+		return err
+	}
+	return s.indexMeal(tx, meal)
+}
+
+// sealContext encrypts a meal's notes and context text for storage.
+func (s *sqlStore) sealContext(meal *models.Meal) (notes, location, restaurant interface{}, err error) {
+	if notes, err = s.sealOptional(meal.Notes); err != nil {
+		return nil, nil, nil, err
+	}
+	if location, err = s.sealOptional(meal.Location); err != nil {
+		return nil, nil, nil, err
+	}
+	if restaurant, err = s.sealOptional(meal.Restaurant); err != nil {
+		return nil, nil, nil, err
+	}
+	return notes, location, restaurant, nil
+}
+
+// insertPhoto stores the thumbnail of the photo a new meal was logged
+// from, encrypted like the description.
+func (s *sqlStore) insertPhoto(tx *sqlTx, meal *models.Meal) error {
+	if meal.Photo == nil || len(meal.Photo.Thumbnail) == 0 {
+		return nil
+	}
+	thumbnail, err := s.seal(base64.StdEncoding.EncodeToString(meal.Photo.Thumbnail))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO meal_photos (meal_id, profile_id, sha256, mime_type, thumbnail, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, meal.ID, meal.ProfileID, meal.Photo.SHA256, meal.Photo.MimeType, thumbnail, meal.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert meal photo: %w", err)
+	}
+	return nil
+}
+
+// GetMealPhoto returns the thumbnail stored with a meal, or ErrNotFound if
+// the meal was not logged from a photo.
+func (s *sqlStore) GetMealPhoto(profileID, mealID string) (*models.MealPhoto, error) {
+	photo := &models.MealPhoto{}
+	var thumbnail string
+	err := s.db.QueryRow(`
+        SELECT sha256, mime_type, thumbnail FROM meal_photos WHERE meal_id = ? AND profile_id = ?
+    `, mealID, profileID).Scan(&photo.SHA256, &photo.MimeType, &thumbnail)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("photo of meal %s: %w", mealID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load meal photo: %w", err)
+	}
+	if thumbnail, err = s.open(thumbnail); err != nil {
+		return nil, err
+	}
+	if photo.Thumbnail, err = base64.StdEncoding.DecodeString(thumbnail); err != nil {
+		return nil, fmt.Errorf("failed to decode meal photo: %w", err)
+	}
+	return photo, nil
+}
+
+func (s *sqlStore) insertFoods(tx *sqlTx, meal *models.Meal) error {
+	foodQuery := `
+        INSERT INTO foods (meal_id, profile_id, name, quantity, carbs_per_100g, estimated_carbs, confidence)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
+	for _, food := range meal.Foods {
+		name, err := s.seal(food.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(foodQuery,
+			meal.ID, meal.ProfileID, name, food.Quantity, food.CarbsPer100g,
+			food.EstimatedCarbs, string(food.Confidence))
+		if err != nil {
+			return fmt.Errorf("failed to insert food: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetMeal returns a single meal of the profile, or ErrNotFound. Meals in the
+// trash are returned too; check DeletedAt.
+func (s *sqlStore) GetMeal(profileID, id string) (*models.Meal, error) {
+	return s.getMeal(s.db, profileID, id)
+}
+
+func (s *sqlStore) getMeal(q queryer, profileID, id string) (*models.Meal, error) {
+	meal, err := s.scanMeal(q.QueryRow(`SELECT `+mealColumns+` FROM meals WHERE id = ? AND profile_id = ?`, id, profileID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("meal %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadFoods(q, profileID, []*models.Meal{meal}); err != nil {
+		return nil, fmt.Errorf("failed to load foods for meal %s: %w", meal.ID, err)
+	}
+	if err := s.loadTags(q, profileID, []*models.Meal{meal}); err != nil {
+		return nil, fmt.Errorf("failed to load tags for meal %s: %w", meal.ID, err)
+	}
+	return meal, nil
+}
+
+// UpdateMeal replaces a stored meal, including its foods, and records the
+// previous version in the audit trail.
+func (s *sqlStore) UpdateMeal(meal *models.Meal, change models.ChangeSource) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.getMeal(tx, meal.ProfileID, meal.ID)
+	if err != nil {
+		return err
+	}
+	if before.DeletedAt != nil {
+		return fmt.Errorf("meal %s is in the trash; restore it first", meal.ID)
+	}
+	meal.DeletedAt = nil
+	if err := s.replaceMeal(tx, meal); err != nil {
+		return err
+	}
+	if err := s.writeAudit(tx, meal.ProfileID, meal.ID, models.AuditUpdate, before, meal, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) replaceMeal(tx *sqlTx, meal *models.Meal) error {
+	settings, loc, err := s.profileClock(tx, meal.ProfileID)
+	if err != nil {
+		return err
+	}
+	if meal.Slot == "" {
+		meal.Slot = settings.MealSlot(meal.Timestamp.In(loc))
+	}
+	description, err := s.seal(meal.Description)
+	if err != nil {
+		return err
+	}
+	notes, location, restaurant, err := s.sealContext(meal)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE meals
+        SET description = ?, timestamp = ?, local_date = ?, local_minutes = ?, slot = ?, total_carbs = ?, confidence = ?, updated_at = ?, source = ?, deleted_at = ?, photo_sha256 = ?,
+            notes = ?, location = ?, restaurant = ?, exercise = ?
+        WHERE id = ? AND profile_id = ?
+    `, description, meal.Timestamp.UTC(), localDate(meal.Timestamp, loc), localMinutes(meal.Timestamp, loc),
+		meal.Slot, meal.TotalCarbs, string(meal.Confidence), meal.UpdatedAt.UTC(), meal.Source, nullTime(meal.DeletedAt),
+		nullString(meal.PhotoSHA256), notes, location, restaurant, nullBool(meal.Exercise), meal.ID, meal.ProfileID)
+	if err != nil {
+		return fmt.Errorf("failed to update meal: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM foods WHERE meal_id = ? AND profile_id = ?`, meal.ID, meal.ProfileID); err != nil {
+		return fmt.Errorf("failed to replace foods: %w", err)
+	}
+	if err := s.insertFoods(tx, meal); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM meal_tags WHERE meal_id = ? AND profile_id = ?`, meal.ID, meal.ProfileID); err != nil {
+		return fmt.Errorf("failed to replace tags: %w", err)
+	}
+	if err := s.insertTags(tx, meal); err != nil {
+		return err
+	}
+	return s.indexMeal(tx, meal)
+}
+
+// DeleteMeal moves a meal to the trash. It stays out of GetMeals results
+// until restored, and is purged with its foods after the retention period.
+func (s *sqlStore) DeleteMeal(profileID, id string, change models.ChangeSource) error {
+	return s.setDeleted(profileID, id, true, models.AuditDelete, change)
+}
+
+// RestoreMeal takes a meal back out of the trash.
+func (s *sqlStore) RestoreMeal(profileID, id string, change models.ChangeSource) (*models.Meal, error) {
+	if err := s.setDeleted(profileID, id, false, models.AuditRestore, change); err != nil {
+		return nil, err
+	}
+	return s.GetMeal(profileID, id)
+}
+
+func (s *sqlStore) setDeleted(profileID, id string, deleted bool, action models.AuditAction, change models.ChangeSource) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.getMeal(tx, profileID, id)
+	if err != nil {
+		return err
+	}
+	if deleted && before.DeletedAt != nil {
+		return fmt.Errorf("meal %s is already in the trash", id)
+	}
+	if !deleted && before.DeletedAt == nil {
+		return fmt.Errorf("meal %s is not in the trash", id)
+	}
+
+	now := time.Now().UTC()
+	after := *before
+	after.UpdatedAt = now
+	after.DeletedAt = nil
+	if deleted {
+		after.DeletedAt = &now
+	}
+
+	_, err = tx.Exec(`UPDATE meals SET deleted_at = ?, updated_at = ? WHERE id = ? AND profile_id = ?`,
+		nullTime(after.DeletedAt), now, id, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to update meal: %w", err)
+	}
+	if err := s.writeAudit(tx, profileID, id, action, before, &after, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedMeals permanently removes meals, and their foods, tags and photos, that have
+// been in the trash since before cutoff. Their last version stays in the
+// audit trail. It returns the number of meals removed.
+func (s *sqlStore) PurgeDeletedMeals(cutoff time.Time) (int, error) {
+	rows, err := s.db.Query(`SELECT id, profile_id FROM meals WHERE deleted_at IS NOT NULL AND deleted_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed meals: %w", err)
+	}
+	type trashed struct{ id, profileID string }
+	var pending []trashed
+	for rows.Next() {
+		var t trashed
+		if err := rows.Scan(&t.id, &t.profileID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trashed meal: %w", err)
+		}
+		pending = append(pending, t)
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	purge := models.ChangeSource{Tool: "trash_purge", Origin: "retention", Actor: "system"}
+	for _, t := range pending {
+		before, err := s.getMeal(tx, t.profileID, t.id)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM foods WHERE meal_id = ? AND profile_id = ?`, t.id, t.profileID); err != nil {
+			return 0, fmt.Errorf("failed to delete foods: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM meal_tags WHERE meal_id = ? AND profile_id = ?`, t.id, t.profileID); err != nil {
+			return 0, fmt.Errorf("failed to delete meal tags: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM meal_photos WHERE meal_id = ? AND profile_id = ?`, t.id, t.profileID); err != nil {
+			return 0, fmt.Errorf("failed to delete meal photo: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM meals WHERE id = ? AND profile_id = ?`, t.id, t.profileID); err != nil {
+			return 0, fmt.Errorf("failed to delete meal: %w", err)
+		}
+		if err := s.unindexMeal(tx, t.profileID, t.id); err != nil {
+			return 0, err
+		}
+		if err := s.writeAudit(tx, t.profileID, t.id, models.AuditPurge, before, nil, purge); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// GetMeals returns one page of a profile's meals, ordered by q.Sort with
+// the meal ID breaking ties.
+func (s *sqlStore) GetMeals(profileID string, q MealQuery) (*MealPage, error) {
+	column, err := sortColumn(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := s.mealFilter(profileID, q, "meals")
+	if err != nil {
+		return nil, err
+	}
+	args = append([]interface{}{profileID}, args...)
+
+	page := &MealPage{Meals: []*models.Meal{}}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM meals WHERE profile_id = ?`+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count meals: %w", err)
+	}
+
+	if q.Cursor != "" {
+		after, afterArgs, err := q.after(column)
+		if err != nil {
+			return nil, err
+		}
+		where += after
+		args = append(args, afterArgs...)
+	}
+
+	direction := "DESC"
+	if q.Ascending {
+		direction = "ASC"
+	}
+	query := `
+        SELECT ` + mealColumns + `
+        FROM meals
+        WHERE profile_id = ?` + where + `
+        ORDER BY ` + column + ` ` + direction + `, id ` + direction
+	if q.Limit > 0 {
+		// One extra row tells whether there is another page
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	if err := s.queryMeals(page, query, args...); err != nil {
+		return nil, err
+	}
+
+	if q.Limit > 0 && len(page.Meals) > q.Limit {
+		page.Meals = page.Meals[:q.Limit]
+		page.NextCursor = newCursor(q, page.Meals[q.Limit-1])
+	}
+	// Foods and tags are loaded after the meal rows are closed, so a single
+	// connection is never asked for two result sets at once.
+	if err := s.loadFoods(s.db, profileID, page.Meals); err != nil {
+		return nil, fmt.Errorf("failed to load foods: %w", err)
+	}
+	if err := s.loadTags(s.db, profileID, page.Meals); err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	return page, nil
+}
+
+// queryMeals appends the meals returned by query to page.
+func (s *sqlStore) queryMeals(page *MealPage, query string, args ...interface{}) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query meals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		meal, err := s.scanMeal(rows)
+		if err != nil {
+			return err
+		}
+		page.Meals = append(page.Meals, meal)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query meals: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) scanMeal(row rowScanner) (*models.Meal, error) {
+	meal := &models.Meal{}
+	var timestampStr, createdAtStr, updatedAtStr string
+	var confidenceStr string
+	var slot, deletedAt, photoSHA256 sql.NullString
+	var notes, location, restaurant sql.NullString
+	var exercise sql.NullBool
+
+	err := row.Scan(
+		&meal.ID, &meal.ProfileID, &meal.Description, &timestampStr, &slot, &meal.TotalCarbs,
+		&confidenceStr, &createdAtStr, &updatedAtStr, &meal.Source, &deletedAt, &photoSHA256,
+		&notes, &location, &restaurant, &exercise)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan meal: %w", err)
+	}
+
+	// Parse timestamps
+	if meal.Timestamp, err = time.Parse(time.RFC3339, timestampStr); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	if meal.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	if meal.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+	if meal.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse deleted_at: %w", err)
+	}
+
+	meal.Confidence = models.ConfidenceLevel(confidenceStr)
+	meal.Slot = slot.String
+	meal.PhotoSHA256 = photoSHA256.String
+	if exercise.Valid {
+		meal.Exercise = &exercise.Bool
+	}
+	if meal.Description, err = s.open(meal.Description); err != nil {
+		return nil, err
+	}
+	if meal.Notes, err = s.openOptional(notes); err != nil {
+		return nil, err
+	}
+	if meal.Location, err = s.openOptional(location); err != nil {
+		return nil, err
+	}
+	if meal.Restaurant, err = s.openOptional(restaurant); err != nil {
+		return nil, err
+	}
+
+	return meal, nil
+}
+
+// foodBatchSize caps the meal IDs per foods query, well below SQLite's
+// limit on bound parameters.
+const foodBatchSize = 500
+
+// loadFoods attaches foods to meals of one profile with one query per
+// foodBatchSize meals, instead of one query per meal.
+func (s *sqlStore) loadFoods(q queryer, profileID string, meals []*models.Meal) error {
+	for start := 0; start < len(meals); start += foodBatchSize {
+		end := start + foodBatchSize
+		if end > len(meals) {
+			end = len(meals)
+		}
+		if err := s.loadFoodBatch(q, profileID, meals[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) loadFoodBatch(q queryer, profileID string, meals []*models.Meal) error {
+	byID := make(map[string]*models.Meal, len(meals))
+	placeholders := make([]string, len(meals))
+	args := []interface{}{profileID}
+	for i, meal := range meals {
+		byID[meal.ID] = meal
+		placeholders[i] = "?"
+		args = append(args, meal.ID)
+	}
+
+	rows, err := q.Query(`
+        SELECT meal_id, name, quantity, carbs_per_100g, estimated_carbs, confidence
+        FROM foods
+        WHERE profile_id = ? AND meal_id IN (`+strings.Join(placeholders, ", ")+`)
+        ORDER BY meal_id, id
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query foods: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mealID, name, quantity, confidence string
+		var carbsPer100g, estimatedCarbs float64
+		if err := rows.Scan(&mealID, &name, &quantity, &carbsPer100g, &estimatedCarbs, &confidence); err != nil {
+			return fmt.Errorf("failed to scan food: %w", err)
+		}
+		food, err := s.food(name, quantity, carbsPer100g, estimatedCarbs, confidence)
+		if err != nil {
+			return err
+		}
+		if meal, ok := byID[mealID]; ok {
+			meal.Foods = append(meal.Foods, food)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query foods: %w", err)
+	}
+	return nil
+}
+
+// food builds a food from its stored columns, decrypting the name.
+func (s *sqlStore) food(name, quantity string, carbsPer100g, estimatedCarbs float64, confidence string) (models.Food, error) {
+	name, err := s.open(name)
+	if err != nil {
+		return models.Food{}, err
+	}
+	return models.Food{
+		Name:           name,
+		Quantity:       quantity,
+		CarbsPer100g:   carbsPer100g,
+		EstimatedCarbs: estimatedCarbs,
+		Confidence:     models.ConfidenceLevel(confidence),
+	}, nil
+}
+
+// streamBatchSize is how many meals StreamMeals reads before handing them
+// to its callback.
+const streamBatchSize = foodBatchSize
+
+// StreamMeals calls fn for every meal matching q, oldest first, with its
+// foods and tags attached. Meals are read in batches of streamBatchSize
+// with keyset pagination, so exports of any size run in bounded memory.
+// Each batch is fully read, and its rows closed, before fn is called, so
+// fn may use the store. q.Limit, q.Sort and q.Cursor are ignored.
+func (s *sqlStore) StreamMeals(profileID string, q MealQuery, fn func(*models.Meal) error) error {
+	q.Sort, q.Ascending, q.Cursor = SortTimestamp, true, ""
+
+	where, args, err := s.mealFilter(profileID, q, "meals")
+	if err != nil {
+		return err
+	}
+	args = append([]interface{}{profileID}, args...)
+
+	for {
+		batchWhere, batchArgs := where, args
+		if q.Cursor != "" {
+			after, afterArgs, err := q.after("timestamp")
+			if err != nil {
+				return err
+			}
+			batchWhere += after
+			batchArgs = append(append([]interface{}{}, args...), afterArgs...)
+		}
+		query := `
+            SELECT ` + mealColumns + `
+            FROM meals
+            WHERE profile_id = ?` + batchWhere + `
+            ORDER BY timestamp, id
+            LIMIT ?`
+		batchArgs = append(batchArgs, streamBatchSize)
+
+		page := &MealPage{}
+		if err := s.queryMeals(page, query, batchArgs...); err != nil {
+			return err
+		}
+		if len(page.Meals) == 0 {
+			return nil
+		}
+		if err := s.loadFoods(s.db, profileID, page.Meals); err != nil {
+			return fmt.Errorf("failed to load foods: %w", err)
+		}
+		if err := s.loadTags(s.db, profileID, page.Meals); err != nil {
+			return fmt.Errorf("failed to load tags: %w", err)
+		}
+
+		for _, meal := range page.Meals {
+			if err := fn(meal); err != nil {
+				return err
+			}
+		}
+		if len(page.Meals) < streamBatchSize {
+			return nil
+		}
+		q.Cursor = newCursor(q, page.Meals[len(page.Meals)-1])
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+var testChange = models.ChangeSource{Tool: "test", Origin: "test", Actor: "test"}
+
+// newTestSQLite opens a fresh SQLite database in a temporary directory.
+func newTestSQLite(tb testing.TB, opts ...Option) *SQLiteStorage {
+	tb.Helper()
+	s, err := NewSQLiteStorage(filepath.Join(tb.TempDir(), "meals.db"), opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testMeals returns n meals of profileID a minute apart, each with two
+// foods and a tag.
+func testMeals(profileID string, n int, start time.Time) []*models.Meal {
+	meals := make([]*models.Meal, n)
+	for i := range meals {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		meals[i] = &models.Meal{
+			ID:          fmt.Sprintf("%s-meal-%06d", profileID, i),
+			ProfileID:   profileID,
+			Description: fmt.Sprintf("toast and jam %d", i),
+			Timestamp:   ts,
+			Foods: []models.Food{
+				{Name: "toast", Quantity: "2 slices", CarbsPer100g: 49, EstimatedCarbs: 30, Confidence: models.HighConfidence},
+				{Name: "jam", Quantity: "1 tbsp", CarbsPer100g: 65, EstimatedCarbs: 13, Confidence: models.MediumConfidence},
+			},
+			TotalCarbs: 43,
+			Confidence: models.MediumConfidence,
+			Tags:       []string{"breakfast-out"},
+			CreatedAt:  ts,
+			UpdatedAt:  ts,
+			Source:     "manual",
+		}
+	}
+	return meals
+}
+
+// StreamMeals must finish reading before calling back, or a callback that
+// queries the store waits forever for the only pooled connection.
+func TestStreamMealsCallbackUsesStore(t *testing.T) {
+	s := newTestSQLite(t, WithMaxOpenConns(1))
+	meals := testMeals(models.DefaultProfileID, streamBatchSize+10, time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	done := make(chan error, 1)
+	go func() {
+		done <- s.StreamMeals(models.DefaultProfileID, MealQuery{}, func(meal *models.Meal) error {
+			if _, err := s.GetMeal(meal.ProfileID, meal.ID); err != nil {
+				return err
+			}
+			if len(meal.Foods) != 2 || len(meal.Tags) != 1 || meal.Description == "" {
+				return fmt.Errorf("meal %s streamed incomplete: %+v", meal.ID, meal)
+			}
+			got = append(got, meal.ID)
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("StreamMeals deadlocked on a callback that uses the store")
+	}
+	if len(got) != len(meals) {
+		t.Fatalf("streamed %d meals, want %d", len(got), len(meals))
+	}
+	for i, meal := range meals {
+		if got[i] != meal.ID {
+			t.Fatalf("meal %d = %s, want %s", i, got[i], meal.ID)
+		}
+	}
+}
+
+const benchmarkMeals = 100000
+
+// seedBenchmark stores benchmarkMeals meals in a new database, 5000 per
+// transaction.
+func seedBenchmark(b *testing.B) *SQLiteStorage {
+	b.Helper()
+	s := newTestSQLite(b)
+	meals := testMeals(models.DefaultProfileID, benchmarkMeals, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	for start := 0; start < len(meals); start += 5000 {
+		if err := s.SaveMeals(meals[start:start+5000], testChange); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return s
+}
+
+func BenchmarkGetMeals(b *testing.B) {
+	s := seedBenchmark(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		// Page 20 deep into the log, the way a client scrolls back
+		q := MealQuery{Limit: 50}
+		for page := 0; page < 20; page++ {
+			result, err := s.GetMeals(models.DefaultProfileID, q)
+			if err != nil {
+				b.Fatal(err)
+			}
+			q.Cursor = result.NextCursor
+		}
+	}
+}
+
+func BenchmarkStreamMeals(b *testing.B) {
+	s := seedBenchmark(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n := 0
+		err := s.StreamMeals(models.DefaultProfileID, MealQuery{}, func(*models.Meal) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != benchmarkMeals {
+			b.Fatalf("streamed %d meals, want %d", n, benchmarkMeals)
+		}
+	}
+}
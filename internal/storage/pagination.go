@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Sort orders for MealQuery.Sort.
+const (
+	SortTimestamp = "timestamp"
+	SortCarbs     = "carbs"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by the
+// same query.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MealPage is one page of GetMeals results. NextCursor is empty on the last
+// page; Total counts every meal matching the filters, across all pages.
+type MealPage struct {
+	Meals      []*models.Meal `json:"meals"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      int            `json:"total"`
+}
+
+// cursor records the position after the last meal of a page. Pages are
+// read with keyset pagination on the sort key and the meal ID, so meals
+// inserted while paging never shift later pages.
+type cursor struct {
+	Sort      string     `json:"s"`
+	Ascending bool       `json:"a,omitempty"`
+	Timestamp *time.Time `json:"t,omitempty"`
+	Carbs     *float64   `json:"c,omitempty"`
+	ID        string     `json:"i"`
+}
+
+func sortColumn(sort string) (string, error) {
+	switch sort {
+	case "", SortTimestamp:
+		return "timestamp", nil
+	case SortCarbs:
+		return "total_carbs", nil
+	}
+	return "", fmt.Errorf("unknown sort %q (want %s or %s)", sort, SortTimestamp, SortCarbs)
+}
+
+func newCursor(q MealQuery, last *models.Meal) string {
+	c := cursor{Sort: q.Sort, Ascending: q.Ascending, ID: last.ID}
+	if c.Sort == "" {
+		c.Sort = SortTimestamp
+	}
+	if c.Sort == SortCarbs {
+		carbs := last.TotalCarbs
+		c.Carbs = &carbs
+	} else {
+		timestamp := last.Timestamp.UTC()
+		c.Timestamp = &timestamp
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// after returns the condition selecting meals past the cursor position.
+func (q MealQuery) after(column string) (string, []interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return "", nil, ErrInvalidCursor
+	}
+
+	sort := q.Sort
+	if sort == "" {
+		sort = SortTimestamp
+	}
+	if c.Sort != sort || c.Ascending != q.Ascending {
+		return "", nil, fmt.Errorf("%w: cursor is for a different sort order", ErrInvalidCursor)
+	}
+
+	var value interface{}
+	switch {
+	case sort == SortCarbs && c.Carbs != nil:
+		value = *c.Carbs
+	case sort == SortTimestamp && c.Timestamp != nil:
+		value = c.Timestamp.UTC()
+	default:
+		return "", nil, ErrInvalidCursor
+	}
+
+	op := "<"
+	if q.Ascending {
+		op = ">"
+	}
+	where := fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op)
+	return where, []interface{}{value, value, c.ID}, nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+
+	"mcp-meal-log/internal/models"
+)
+
+// PostgresStorage keeps the meal log in a PostgreSQL database, so several
+// servers can share it.
+type PostgresStorage struct {
+	*sqlStore
+}
+
+// postgresSchemaLock is the advisory lock servers hold while they create
+// or update the schema, so two starting at once do not race.
+const postgresSchemaLock = 0x6d65616c // "meal"
+
+func NewPostgresStorage(dsn string, opts ...Option) (*PostgresStorage, error) {
+	storage := &PostgresStorage{newSQLStore(postgresDialect{}, opts)}
+
+	db, err := sql.Open("postgres", postgresDSN(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	storage.db = &sqlDB{DB: db, dialect: storage.dialect}
+	db.SetMaxOpenConns(storage.maxOpenConns)
+	db.SetMaxIdleConns(storage.maxOpenConns)
+
+	if err := storage.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return storage, nil
+}
+
+func (s *PostgresStorage) init() error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresSchemaLock); err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresSchemaLock)
+
+	if _, err := conn.ExecContext(ctx, postgresSchema+postgresAuditTriggers); err != nil {
+		return fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	return s.prepare()
+}
+
+// postgresDSN makes the server report times in UTC, as SQLite stores them.
+func postgresDSN(dsn string) string {
+	if strings.Contains(strings.ToLower(dsn), "timezone") {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "timezone=UTC"
+	}
+	return dsn + " timezone=UTC"
+}
+
+// postgresSchema matches the SQLite schema after every migration.
+const postgresSchema = `
+    CREATE TABLE IF NOT EXISTS meta (
+        key TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS profiles (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS settings (
+        profile_id TEXT PRIMARY KEY REFERENCES profiles(id) ON DELETE CASCADE,
+        timezone TEXT NOT NULL DEFAULT '',
+        carb_ratios TEXT NOT NULL DEFAULT '{}',
+        meal_schedule TEXT NOT NULL DEFAULT '[]',
+        updated_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS meals (
+        id TEXT PRIMARY KEY,
+        profile_id TEXT NOT NULL DEFAULT 'default',
+        description TEXT NOT NULL,
+        timestamp TIMESTAMPTZ NOT NULL,
+        local_date TEXT,
+        local_minutes INTEGER,
+        slot TEXT,
+        total_carbs DOUBLE PRECISION NOT NULL,
+        confidence TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL,
+        source TEXT NOT NULL,
+        deleted_at TIMESTAMPTZ,
+        photo_sha256 TEXT,
+        notes TEXT,
+        location TEXT,
+        restaurant TEXT,
+        exercise BOOLEAN
+    );
+
+    CREATE TABLE IF NOT EXISTS foods (
+        id BIGSERIAL PRIMARY KEY,
+        meal_id TEXT NOT NULL REFERENCES meals(id) ON DELETE CASCADE,
+        profile_id TEXT NOT NULL DEFAULT 'default',
+        name TEXT NOT NULL,
+        quantity TEXT NOT NULL,
+        carbs_per_100g DOUBLE PRECISION NOT NULL,
+        estimated_carbs DOUBLE PRECISION NOT NULL,
+        confidence TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_photos (
+        meal_id TEXT PRIMARY KEY REFERENCES meals(id) ON DELETE CASCADE,
+        profile_id TEXT NOT NULL,
+        sha256 TEXT NOT NULL,
+        mime_type TEXT NOT NULL,
+        thumbnail TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS products (
+        barcode TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        brand TEXT NOT NULL DEFAULT '',
+        carbs_per_100g DOUBLE PRECISION NOT NULL,
+        serving_size TEXT NOT NULL DEFAULT '',
+        serving_grams DOUBLE PRECISION NOT NULL DEFAULT 0,
+        package_grams DOUBLE PRECISION NOT NULL DEFAULT 0,
+        source TEXT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS custom_foods (
+        id TEXT PRIMARY KEY,
+        profile_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        label TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS tags (
+        id BIGSERIAL PRIMARY KEY,
+        profile_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_tags (
+        profile_id TEXT NOT NULL,
+        meal_id TEXT NOT NULL REFERENCES meals(id) ON DELETE CASCADE,
+        tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+        PRIMARY KEY (profile_id, meal_id, tag_id)
+    );
+
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        profile_id TEXT NOT NULL DEFAULT '',
+        token_hash TEXT NOT NULL UNIQUE,
+        created_at TIMESTAMPTZ NOT NULL,
+        last_used_at TIMESTAMPTZ,
+        revoked_at TIMESTAMPTZ
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_audit (
+        id BIGSERIAL PRIMARY KEY,
+        meal_id TEXT NOT NULL,
+        profile_id TEXT NOT NULL,
+        action TEXT NOT NULL,
+        before_json TEXT,
+        after_json TEXT,
+        tool TEXT NOT NULL,
+        origin TEXT NOT NULL,
+        actor TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_search (
+        profile_id TEXT NOT NULL,
+        meal_id TEXT NOT NULL,
+        document TSVECTOR NOT NULL,
+        PRIMARY KEY (profile_id, meal_id)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_meals_timestamp ON meals(timestamp);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_local_date ON meals(profile_id, local_date);
+    CREATE INDEX IF NOT EXISTS idx_meals_deleted_at ON meals(deleted_at);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_timestamp_id ON meals(profile_id, timestamp, id);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_carbs_id ON meals(profile_id, total_carbs, id);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_deleted_at ON meals(profile_id, deleted_at);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_slot ON meals(profile_id, slot);
+    CREATE INDEX IF NOT EXISTS idx_foods_meal_id ON foods(meal_id);
+    CREATE INDEX IF NOT EXISTS idx_foods_profile_meal ON foods(profile_id, meal_id);
+    CREATE INDEX IF NOT EXISTS idx_meal_audit_meal ON meal_audit(profile_id, meal_id);
+    CREATE INDEX IF NOT EXISTS idx_custom_foods_profile ON custom_foods(profile_id);
+    CREATE INDEX IF NOT EXISTS idx_tags_profile ON tags(profile_id);
+    CREATE INDEX IF NOT EXISTS idx_meal_tags_tag ON meal_tags(profile_id, tag_id);
+    CREATE INDEX IF NOT EXISTS idx_meal_search_document ON meal_search USING GIN (document);
+`
+
+// postgresAuditTriggers make meal_audit append-only, like auditTriggers.
+const postgresAuditTriggers = `
+    CREATE OR REPLACE FUNCTION meal_audit_append_only() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'meal_audit is append-only';
+    END;
+    $$ LANGUAGE plpgsql;
+
+    DROP TRIGGER IF EXISTS meal_audit_append_only ON meal_audit;
+    CREATE TRIGGER meal_audit_append_only BEFORE UPDATE OR DELETE ON meal_audit
+        FOR EACH ROW EXECUTE FUNCTION meal_audit_append_only();
+`
+
+// postgresDialect numbers placeholders and searches with a tsvector
+// column. The simple configuration lowercases words without stemming,
+// which suits food names in any language.
+type postgresDialect struct{}
+
+// bind replaces each ? outside string literals with $1, $2 and so on.
+func (postgresDialect) bind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (postgresDialect) lockAudit() string { return postgresAuditTriggers }
+
+func (postgresDialect) unlockAudit() string {
+	return `DROP TRIGGER IF EXISTS meal_audit_append_only ON meal_audit;`
+}
+
+// scrub rewrites the tables holding encrypted columns, which drops the old
+// row versions that plain VACUUM would only mark as free.
+func (postgresDialect) scrub(db *sqlDB) error {
+	_, err := db.Exec(`VACUUM FULL meals, tags, foods, meal_photos, custom_foods, meal_audit, meal_search`)
+	return err
+}
+
+func (postgresDialect) indexMeal(tx *sqlTx, meal *models.Meal, foods string) error {
+	_, err := tx.Exec(`
+        INSERT INTO meal_search (profile_id, meal_id, document)
+        VALUES (?, ?, to_tsvector('simple', ?))
+        ON CONFLICT (profile_id, meal_id) DO UPDATE SET document = excluded.document
+    `, meal.ProfileID, meal.ID, meal.Description+" "+foods)
+	return err
+}
+
+func (postgresDialect) unindexMeal(tx *sqlTx, profileID, mealID string) error {
+	_, err := tx.Exec(`DELETE FROM meal_search WHERE profile_id = ? AND meal_id = ?`, profileID, mealID)
+	return err
+}
+
+func (d postgresDialect) rebuildSearchIndex(tx *sqlTx) error {
+	if err := d.clearSearchIndex(tx); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+        INSERT INTO meal_search (profile_id, meal_id, document)
+        SELECT m.profile_id, m.id,
+               to_tsvector('simple', m.description || ' ' ||
+                   COALESCE((SELECT string_agg(f.name, ' ') FROM foods f
+                             WHERE f.meal_id = m.id AND f.profile_id = m.profile_id), ''))
+        FROM meals m
+    `)
+	return err
+}
+
+func (postgresDialect) clearSearchIndex(tx *sqlTx) error {
+	_, err := tx.Exec(`DELETE FROM meal_search`)
+	return err
+}
+
+func (postgresDialect) searchFilter(table string) string {
+	return table + ".id IN (" +
+		"SELECT meal_id FROM meal_search WHERE document @@ to_tsquery('simple', ?) AND profile_id = ?)"
+}
+
+// searchQuery turns free text into a tsquery matching every word as a
+// prefix. Words are quoted so operators in the input are searched for
+// literally.
+func (postgresDialect) searchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		word = strings.ReplaceAll(word, `\`, `\\`)
+		terms = append(terms, "'"+strings.ReplaceAll(word, "'", "''")+"':*")
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Products are shared by every profile: they hold label values, not
+// anything about what a person ate.
+
+// upsertProduct inserts a product or replaces the stored one. Imported
+// data never replaces label values entered by hand.
+const upsertProduct = `
+    INSERT INTO products (barcode, name, brand, carbs_per_100g, serving_size, serving_grams, package_grams, source, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    ON CONFLICT (barcode) DO UPDATE SET
+        name = excluded.name, brand = excluded.brand, carbs_per_100g = excluded.carbs_per_100g,
+        serving_size = excluded.serving_size, serving_grams = excluded.serving_grams,
+        package_grams = excluded.package_grams, source = excluded.source, updated_at = excluded.updated_at
+    WHERE products.source <> 'manual' OR excluded.source = 'manual'
+`
+
+// GetProduct returns the product with a normalized barcode, or ErrNotFound.
+func (s *sqlStore) GetProduct(barcode string) (*models.Product, error) {
+	p := &models.Product{}
+	var updatedAt string
+	err := s.db.QueryRow(`
+        SELECT barcode, name, brand, carbs_per_100g, serving_size, serving_grams, package_grams, source, updated_at
+        FROM products
+        WHERE barcode = ?
+    `, barcode).Scan(&p.Barcode, &p.Name, &p.Brand, &p.CarbsPer100g, &p.ServingSize, &p.ServingGrams,
+		&p.PackageGrams, &p.Source, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product %s: %w", barcode, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product: %w", err)
+	}
+	if p.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+	return p, nil
+}
+
+// SaveProducts inserts or updates products in one transaction. A product
+// entered by hand is only replaced by another manual entry.
+func (s *sqlStore) SaveProducts(products []*models.Product) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range products {
+		_, err := tx.Exec(upsertProduct, p.Barcode, p.Name, p.Brand, p.CarbsPer100g, p.ServingSize,
+			p.ServingGrams, p.PackageGrams, p.Source, p.UpdatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to save product %s: %w", p.Barcode, err)
+		}
+	}
+
+	return tx.Commit()
+}
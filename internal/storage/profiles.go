@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// ErrNotFound is returned when a lookup matches no row in the caller's profile.
+var ErrNotFound = errors.New("not found")
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sqlTx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *sqlStore) ensureProfile(id, name string) error {
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`INSERT INTO profiles (id, name, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, id, name, now); err != nil {
+		return fmt.Errorf("failed to create profile %s: %w", id, err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO settings (profile_id, updated_at) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, now); err != nil {
+		return fmt.Errorf("failed to create settings for profile %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateProfile(profile *models.Profile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO profiles (id, name, created_at) VALUES (?, ?, ?)`,
+		profile.ID, profile.Name, profile.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert profile: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO settings (profile_id, updated_at) VALUES (?, ?)`,
+		profile.ID, profile.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert settings: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) GetProfile(id string) (*models.Profile, error) {
+	profile := &models.Profile{}
+	var createdAtStr string
+	err := s.db.QueryRow(`SELECT id, name, created_at FROM profiles WHERE id = ?`, id).
+		Scan(&profile.ID, &profile.Name, &createdAtStr)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("profile %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profile: %w", err)
+	}
+	if profile.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	return profile, nil
+}
+
+func (s *sqlStore) ListProfiles() ([]*models.Profile, error) {
+	rows, err := s.db.Query(`SELECT id, name, created_at FROM profiles ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []*models.Profile
+	for rows.Next() {
+		profile := &models.Profile{}
+		var createdAtStr string
+		if err := rows.Scan(&profile.ID, &profile.Name, &createdAtStr); err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+		if profile.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
+			return nil, fmt.Errorf("failed to parse created_at: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (s *sqlStore) GetSettings(profileID string) (*models.ProfileSettings, error) {
+	return s.getSettings(s.db, profileID)
+}
+
+func (s *sqlStore) getSettings(q queryer, profileID string) (*models.ProfileSettings, error) {
+	settings := &models.ProfileSettings{ProfileID: profileID}
+	var ratiosJSON, scheduleJSON, updatedAtStr string
+	err := q.QueryRow(`SELECT timezone, carb_ratios, meal_schedule, updated_at FROM settings WHERE profile_id = ?`, profileID).
+		Scan(&settings.Timezone, &ratiosJSON, &scheduleJSON, &updatedAtStr)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("settings for profile %s: %w", profileID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings: %w", err)
+	}
+	if err := json.Unmarshal([]byte(ratiosJSON), &settings.CarbRatios); err != nil {
+		return nil, fmt.Errorf("failed to parse carb ratios: %w", err)
+	}
+	if err := json.Unmarshal([]byte(scheduleJSON), &settings.MealSchedule); err != nil {
+		return nil, fmt.Errorf("failed to parse meal schedule: %w", err)
+	}
+	if settings.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateSettings stores a profile's settings. When the timezone or meal
+// schedule changes the local dates and slots of that profile's meals are
+// recomputed so filters keep following the user's day.
+func (s *sqlStore) UpdateSettings(settings *models.ProfileSettings) error {
+	loc, err := settings.Location()
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", settings.Timezone, err)
+	}
+	ratiosJSON, err := json.Marshal(settings.CarbRatios)
+	if err != nil {
+		return fmt.Errorf("failed to encode carb ratios: %w", err)
+	}
+	if settings.CarbRatios == nil {
+		ratiosJSON = []byte("{}")
+	}
+	scheduleJSON, err := json.Marshal(settings.MealSchedule)
+	if err != nil {
+		return fmt.Errorf("failed to encode meal schedule: %w", err)
+	}
+	if settings.MealSchedule == nil {
+		scheduleJSON = []byte("[]")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := s.getSettings(tx, settings.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE settings SET timezone = ?, carb_ratios = ?, meal_schedule = ?, updated_at = ? WHERE profile_id = ?`,
+		settings.Timezone, string(ratiosJSON), string(scheduleJSON), settings.UpdatedAt.UTC(), settings.ProfileID)
+	if err != nil {
+		return fmt.Errorf("failed to update settings: %w", err)
+	}
+
+	if current.Timezone != settings.Timezone || !slices.Equal(current.SlotWindows(), settings.SlotWindows()) {
+		if err := s.recomputeLocalDates(tx, current, settings, loc); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recomputeLocalDates updates a profile's meals from the before settings
+// to the after ones, loc being the new timezone. A meal keeps its slot when
+// the old schedule would not have given it, as it was then chosen by hand.
+func (s *sqlStore) recomputeLocalDates(tx *sqlTx, before, after *models.ProfileSettings, loc *time.Location) error {
+	oldLoc, err := before.Location()
+	if err != nil {
+		oldLoc = loc
+	}
+	profileID := after.ProfileID
+	rows, err := tx.Query(`SELECT id, timestamp, slot FROM meals WHERE profile_id = ?`, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to query meals: %w", err)
+	}
+	type local struct {
+		date    string
+		minutes int
+		slot    string
+	}
+	meals := map[string]local{}
+	for rows.Next() {
+		var id, timestampStr string
+		var slot sql.NullString
+		if err := rows.Scan(&id, &timestampStr, &slot); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan meal: %w", err)
+		}
+		timestamp, err := time.Parse(time.RFC3339, timestampStr)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		m := local{date: localDate(timestamp, loc), minutes: localMinutes(timestamp, loc), slot: slot.String}
+		if !slot.Valid || slot.String == before.MealSlot(timestamp.In(oldLoc)) {
+			m.slot = after.MealSlot(timestamp.In(loc))
+		}
+		meals[id] = m
+	}
+	rows.Close()
+
+	for id, m := range meals {
+		if _, err := tx.Exec(`UPDATE meals SET local_date = ?, local_minutes = ?, slot = ? WHERE id = ? AND profile_id = ?`,
+			m.date, m.minutes, m.slot, id, profileID); err != nil {
+			return fmt.Errorf("failed to update local date for meal %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) profileLocation(q queryer, profileID string) (*time.Location, error) {
+	_, loc, err := s.profileClock(q, profileID)
+	return loc, err
+}
+
+// profileClock returns a profile's settings with its timezone, which
+// together place a meal in the profile's day and meal slots.
+func (s *sqlStore) profileClock(q queryer, profileID string) (*models.ProfileSettings, *time.Location, error) {
+	settings, err := s.getSettings(q, profileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone for profile %s: %w", profileID, err)
+	}
+	return settings, loc, nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"mcp-meal-log/internal/models"
+)
+
+const metaSearchIndex = "meal_search"
+
+// ErrSearchUnavailable is returned for text queries on an encrypted
+// database.
+var ErrSearchUnavailable = errors.New("text search is not available when the database is encrypted")
+
+// The search index covers meal descriptions and food names. Encrypted
+// databases leave it empty, since indexing would store the plaintext the
+// encryption is meant to protect.
+
+// initSearchIndex builds the index the first time an unencrypted database
+// is opened with search support.
+func (s *sqlStore) initSearchIndex() error {
+	if s.cipher != nil {
+		return nil
+	}
+	built, err := s.getMeta(metaSearchIndex)
+	if err != nil || built != "" {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.rebuildSearchIndex(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebuildSearchIndex indexes every meal from scratch. It must only be
+// called with plaintext columns.
+func (s *sqlStore) rebuildSearchIndex(tx *sqlTx) error {
+	if err := s.dialect.rebuildSearchIndex(tx); err != nil {
+		return fmt.Errorf("failed to build search index: %w", err)
+	}
+	_, err := tx.Exec(`INSERT INTO meta (key, value) VALUES (?, '1')
+        ON CONFLICT(key) DO UPDATE SET value = excluded.value`, metaSearchIndex)
+	if err != nil {
+		return fmt.Errorf("failed to record search index: %w", err)
+	}
+	return nil
+}
+
+// clearSearchIndex empties the index, e.g. when the database is encrypted.
+func (s *sqlStore) clearSearchIndex(tx *sqlTx) error {
+	if err := s.dialect.clearSearchIndex(tx); err != nil {
+		return fmt.Errorf("failed to clear search index: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM meta WHERE key = ?`, metaSearchIndex); err != nil {
+		return fmt.Errorf("failed to record search index: %w", err)
+	}
+	return nil
+}
+
+// indexMeal replaces a meal's entry in the search index.
+func (s *sqlStore) indexMeal(tx *sqlTx, meal *models.Meal) error {
+	if s.cipher != nil {
+		return nil
+	}
+	names := make([]string, len(meal.Foods))
+	for i, food := range meal.Foods {
+		names[i] = food.Name
+	}
+	if err := s.dialect.indexMeal(tx, meal, strings.Join(names, " ")); err != nil {
+		return fmt.Errorf("failed to index meal: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) unindexMeal(tx *sqlTx, profileID, mealID string) error {
+	if err := s.dialect.unindexMeal(tx, profileID, mealID); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+// sqliteSearchSchema is the FTS5 index. FTS5 can only look rows up by
+// rowid, so meal_search_ids assigns each meal the rowid of its index entry.
+// The meals table's own rowids are not used because VACUUM may renumber
+// them.
+const sqliteSearchSchema = `
+    CREATE TABLE IF NOT EXISTS meal_search_ids (
+        rowid INTEGER PRIMARY KEY,
+        profile_id TEXT NOT NULL,
+        meal_id TEXT NOT NULL,
+        UNIQUE (profile_id, meal_id)
+    );
+
+    CREATE VIRTUAL TABLE IF NOT EXISTS meal_search USING fts5(
+        description,
+        foods,
+        tokenize = 'unicode61 remove_diacritics 2'
+    );
+`
+
+func (d sqliteDialect) rebuildSearchIndex(tx *sqlTx) error {
+	if err := d.clearSearchIndex(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO meal_search_ids (profile_id, meal_id) SELECT profile_id, id FROM meals`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+        INSERT INTO meal_search (rowid, description, foods)
+        SELECT i.rowid, m.description,
+               COALESCE((SELECT group_concat(f.name, ' ') FROM foods f
+                         WHERE f.meal_id = m.id AND f.profile_id = m.profile_id), '')
+        FROM meal_search_ids i
+        JOIN meals m ON m.id = i.meal_id AND m.profile_id = i.profile_id
+    `)
+	return err
+}
+
+// clearSearchIndex deletes every row and then rebuilds the now empty index,
+// since FTS5 keeps deleted terms in its index segments until they merge.
+func (sqliteDialect) clearSearchIndex(tx *sqlTx) error {
+	if _, err := tx.Exec(`DELETE FROM meal_search`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO meal_search (meal_search) VALUES ('rebuild')`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM meal_search_ids`)
+	return err
+}
+
+func (d sqliteDialect) indexMeal(tx *sqlTx, meal *models.Meal, foods string) error {
+	if err := d.unindexMeal(tx, meal.ProfileID, meal.ID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`INSERT INTO meal_search_ids (profile_id, meal_id) VALUES (?, ?)`, meal.ProfileID, meal.ID)
+	if err != nil {
+		return err
+	}
+	rowid, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO meal_search (rowid, description, foods) VALUES (?, ?, ?)`,
+		rowid, meal.Description, foods)
+	return err
+}
+
+func (sqliteDialect) unindexMeal(tx *sqlTx, profileID, mealID string) error {
+	_, err := tx.Exec(`
+        DELETE FROM meal_search
+        WHERE rowid = (SELECT rowid FROM meal_search_ids WHERE profile_id = ? AND meal_id = ?)
+    `, profileID, mealID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM meal_search_ids WHERE profile_id = ? AND meal_id = ?`, profileID, mealID)
+	return err
+}
+
+func (sqliteDialect) searchFilter(table string) string {
+	return table + ".id IN (" +
+		"SELECT i.meal_id FROM meal_search JOIN meal_search_ids i ON i.rowid = meal_search.rowid" +
+		" WHERE meal_search MATCH ? AND i.profile_id = ?)"
+}
+
+// searchQuery turns free text into an FTS5 query matching every word as a
+// prefix, so "banan brea" finds "banana bread". Words are quoted so FTS5
+// operators in the input are searched for literally.
+func (sqliteDialect) searchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -1,41 +1,88 @@
-// internal/storage/sqlite.go
 package storage
 
 import (
 	"database/sql"
 	"fmt"
-	"time"
 
 	_ "modernc.org/sqlite"
-
-	"mcp-meal-log/internal/models"
 )
 
+// SQLiteStorage keeps the meal log in a SQLite file.
 type SQLiteStorage struct {
-	db *sql.DB
+	*sqlStore
 }
 
-func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// sqliteDialect runs the shared queries unchanged.
+type sqliteDialect struct{}
+
+func (sqliteDialect) bind(query string) string { return query }
+func (sqliteDialect) lockAudit() string        { return auditTriggers }
+func (sqliteDialect) unlockAudit() string      { return dropAuditTriggers }
+
+// scrub moves everything out of the WAL, rewrites the database without its
+// free pages and then empties the WAL that VACUUM filled.
+func (sqliteDialect) scrub(db *sqlDB) error {
+	for _, query := range []string{`PRAGMA wal_checkpoint(TRUNCATE)`, `VACUUM`, `PRAGMA wal_checkpoint(TRUNCATE)`} {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewSQLiteStorage(dbPath string, opts ...Option) (*SQLiteStorage, error) {
+	storage := &SQLiteStorage{newSQLStore(sqliteDialect{}, opts)}
+
+	db, err := sql.Open("sqlite", storage.dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	storage.db = &sqlDB{DB: db, dialect: storage.dialect}
+	storage.configurePool(dbPath)
+
+	if err := storage.checkIntegrity(); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	storage := &SQLiteStorage{db: db}
 	if err := storage.initSchema(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
+	if err := storage.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	if err := storage.prepare(); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return storage, nil
 }
 
-func (s *SQLiteStorage) Close() error {
-	return s.db.Close()
-}
-
 func (s *SQLiteStorage) initSchema() error {
 	schema := `
+    CREATE TABLE IF NOT EXISTS meta (
+        key TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS profiles (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS settings (
+        profile_id TEXT PRIMARY KEY,
+        timezone TEXT NOT NULL DEFAULT '',
+        carb_ratios TEXT NOT NULL DEFAULT '{}',
+        meal_schedule TEXT NOT NULL DEFAULT '[]',
+        updated_at DATETIME NOT NULL,
+        FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
+    );
+
     CREATE TABLE IF NOT EXISTS meals (
         id TEXT PRIMARY KEY,
         description TEXT NOT NULL,
@@ -58,9 +105,83 @@ func (s *SQLiteStorage) initSchema() error {
         FOREIGN KEY (meal_id) REFERENCES meals(id) ON DELETE CASCADE
     );
 
+    CREATE TABLE IF NOT EXISTS meal_photos (
+        meal_id TEXT PRIMARY KEY,
+        profile_id TEXT NOT NULL,
+        sha256 TEXT NOT NULL,
+        mime_type TEXT NOT NULL,
+        thumbnail TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        FOREIGN KEY (meal_id) REFERENCES meals(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS products (
+        barcode TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        brand TEXT NOT NULL DEFAULT '',
+        carbs_per_100g REAL NOT NULL,
+        serving_size TEXT NOT NULL DEFAULT '',
+        serving_grams REAL NOT NULL DEFAULT 0,
+        package_grams REAL NOT NULL DEFAULT 0,
+        source TEXT NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS custom_foods (
+        id TEXT PRIMARY KEY,
+        profile_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        label TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS tags (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        profile_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_tags (
+        profile_id TEXT NOT NULL,
+        meal_id TEXT NOT NULL,
+        tag_id INTEGER NOT NULL,
+        PRIMARY KEY (profile_id, meal_id, tag_id),
+        FOREIGN KEY (meal_id) REFERENCES meals(id) ON DELETE CASCADE,
+        FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        profile_id TEXT NOT NULL DEFAULT '',
+        token_hash TEXT NOT NULL UNIQUE,
+        created_at DATETIME NOT NULL,
+        last_used_at DATETIME,
+        revoked_at DATETIME
+    );
+
+    CREATE TABLE IF NOT EXISTS meal_audit (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        meal_id TEXT NOT NULL,
+        profile_id TEXT NOT NULL,
+        action TEXT NOT NULL,
+        before_json TEXT,
+        after_json TEXT,
+        tool TEXT NOT NULL,
+        origin TEXT NOT NULL,
+        actor TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+
     CREATE INDEX IF NOT EXISTS idx_meals_timestamp ON meals(timestamp);
     CREATE INDEX IF NOT EXISTS idx_foods_meal_id ON foods(meal_id);
-    `
+    CREATE INDEX IF NOT EXISTS idx_meal_audit_meal ON meal_audit(profile_id, meal_id);
+    CREATE INDEX IF NOT EXISTS idx_custom_foods_profile ON custom_foods(profile_id);
+    CREATE INDEX IF NOT EXISTS idx_tags_profile ON tags(profile_id);
+    CREATE INDEX IF NOT EXISTS idx_meal_tags_tag ON meal_tags(profile_id, tag_id);
+    ` + auditTriggers
 
 	if _, err := s.db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
@@ -69,135 +190,76 @@ func (s *SQLiteStorage) initSchema() error {
 	return nil
 }
 
-func (s *SQLiteStorage) SaveMeal(meal *models.Meal) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Insert meal
-	mealQuery := `
-        INSERT INTO meals (id, description, timestamp, total_carbs, confidence, created_at, updated_at, source)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-    `
-	_, err = tx.Exec(mealQuery,
-		meal.ID, meal.Description, meal.Timestamp, meal.TotalCarbs,
-		string(meal.Confidence), meal.CreatedAt, meal.UpdatedAt, meal.Source)
-	if err != nil {
-		return fmt.Errorf("failed to insert meal: %w", err)
+// migrate brings databases created by earlier versions up to date. Every
+// step is idempotent so it is safe to run on each start.
+func (s *SQLiteStorage) migrate() error {
+	columns := []struct{ table, column, definition string }{
+		{"meals", "profile_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"meals", "local_date", "TEXT"},
+		{"foods", "profile_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"meals", "deleted_at", "DATETIME"},
+		{"meals", "local_minutes", "INTEGER"},
+		{"meals", "photo_sha256", "TEXT"},
+		{"meals", "slot", "TEXT"},
+		{"settings", "meal_schedule", "TEXT NOT NULL DEFAULT '[]'"},
+		{"meals", "notes", "TEXT"},
+		{"meals", "location", "TEXT"},
+		{"meals", "restaurant", "TEXT"},
+		{"meals", "exercise", "BOOLEAN"},
 	}
-
-	// Insert foods
-	foodQuery := `
-        INSERT INTO foods (meal_id, name, quantity, carbs_per_100g, estimated_carbs, confidence)
-        VALUES (?, ?, ?, ?, ?, ?)
-    `
-	for _, food := range meal.Foods {
-		_, err = tx.Exec(foodQuery,
-			meal.ID, food.Name, food.Quantity, food.CarbsPer100g,
-			food.EstimatedCarbs, string(food.Confidence))
-		if err != nil {
-			return fmt.Errorf("failed to insert food: %w", err)
+	for _, c := range columns {
+		if err := s.addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return err
 		}
 	}
 
-	return tx.Commit()
-}
-
-func (s *SQLiteStorage) GetMeals(startDate, endDate string, limit int) ([]*models.Meal, error) {
-	query := `
-        SELECT id, description, timestamp, total_carbs, confidence, created_at, updated_at, source
-        FROM meals
-        WHERE 1=1
-    `
-	args := []interface{}{}
-
-	if startDate != "" {
-		query += " AND DATE(timestamp) >= ?"
-		args = append(args, startDate)
-	}
-	if endDate != "" {
-		query += " AND DATE(timestamp) <= ?"
-		args = append(args, endDate)
+	indexes := `
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_local_date ON meals(profile_id, local_date);
+    DROP INDEX IF EXISTS idx_foods_profile_id;
+    CREATE INDEX IF NOT EXISTS idx_foods_profile_meal ON foods(profile_id, meal_id);
+    CREATE INDEX IF NOT EXISTS idx_meals_deleted_at ON meals(deleted_at);
+
+    -- Listings order by the sort column and then the meal ID, so the
+    -- indexes include the ID to avoid sorting every matching row.
+    DROP INDEX IF EXISTS idx_meals_profile_timestamp;
+    DROP INDEX IF EXISTS idx_meals_profile_carbs;
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_timestamp_id ON meals(profile_id, timestamp, id);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_carbs_id ON meals(profile_id, total_carbs, id);
+    -- Covers the page total of listings without other filters
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_deleted_at ON meals(profile_id, deleted_at);
+    CREATE INDEX IF NOT EXISTS idx_meals_profile_slot ON meals(profile_id, slot);
+    ` + sqliteSearchSchema
+	if _, err := s.db.Exec(indexes); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
 	}
+	return nil
+}
 
-	query += " ORDER BY timestamp DESC LIMIT ?"
-	args = append(args, limit)
-
-	rows, err := s.db.Query(query, args...)
+func (s *SQLiteStorage) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query meals: %w", err)
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
 	}
 	defer rows.Close()
 
-	var meals []*models.Meal
 	for rows.Next() {
-		meal := &models.Meal{}
-		var timestampStr, createdAtStr, updatedAtStr string
-		var confidenceStr string
-
-		err := rows.Scan(
-			&meal.ID, &meal.Description, &timestampStr, &meal.TotalCarbs,
-			&confidenceStr, &createdAtStr, &updatedAtStr, &meal.Source)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan meal: %w", err)
-		}
-
-		// Parse timestamps
-		if meal.Timestamp, err = time.Parse(time.RFC3339, timestampStr); err != nil {
-			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan column info: %w", err)
 		}
-		if meal.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
-			return nil, fmt.Errorf("failed to parse created_at: %w", err)
+		if name == column {
+			return nil
 		}
-		if meal.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr); err != nil {
-			return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-		}
-
-		meal.Confidence = models.ConfidenceLevel(confidenceStr)
-
-		// Load foods for this meal
-		if err := s.loadFoodsForMeal(meal); err != nil {
-			return nil, fmt.Errorf("failed to load foods for meal %s: %w", meal.ID, err)
-		}
-
-		meals = append(meals, meal)
 	}
-
-	return meals, nil
-}
-
-func (s *SQLiteStorage) loadFoodsForMeal(meal *models.Meal) error {
-	query := `
-        SELECT name, quantity, carbs_per_100g, estimated_carbs, confidence
-        FROM foods
-        WHERE meal_id = ?
-        ORDER BY id
-    `
-
-	rows, err := s.db.Query(query, meal.ID)
-	if err != nil {
-		return fmt.Errorf("failed to query foods: %w", err)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
 	}
-	defer rows.Close()
+	rows.Close()
 
-	var foods []models.Food
-	for rows.Next() {
-		food := models.Food{}
-		var confidenceStr string
-
-		err := rows.Scan(
-			&food.Name, &food.Quantity, &food.CarbsPer100g,
-			&food.EstimatedCarbs, &confidenceStr)
-		if err != nil {
-			return fmt.Errorf("failed to scan food: %w", err)
-		}
-
-		food.Confidence = models.ConfidenceLevel(confidenceStr)
-		foods = append(foods, food)
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
 	}
-
-	meal.Foods = foods
 	return nil
 }
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/models"
+)
+
+// Store is everything the server and the command line read and write.
+// SQLiteStorage is the default; PostgresStorage shares one database between
+// several servers.
+type Store interface {
+	SaveMeal(meal *models.Meal, change models.ChangeSource) error
+	SaveMeals(meals []*models.Meal, change models.ChangeSource) error
+	GetMeal(profileID, id string) (*models.Meal, error)
+	GetMealPhoto(profileID, mealID string) (*models.MealPhoto, error)
+	GetMeals(profileID string, q MealQuery) (*MealPage, error)
+	StreamMeals(profileID string, q MealQuery, fn func(*models.Meal) error) error
+	UpdateMeal(meal *models.Meal, change models.ChangeSource) error
+	DeleteMeal(profileID, id string, change models.ChangeSource) error
+	RestoreMeal(profileID, id string, change models.ChangeSource) (*models.Meal, error)
+	PurgeDeletedMeals(cutoff time.Time) (int, error)
+	GetMealHistory(profileID, mealID string) ([]*models.MealRevision, error)
+	RestoreMealRevision(profileID, mealID string, revisionID int64, change models.ChangeSource) (*models.Meal, error)
+	Summarize(profileID, startDate, endDate string, topFoods int) (*models.Summary, error)
+	ListTags(profileID string) ([]models.TagCount, error)
+
+	CreateProfile(profile *models.Profile) error
+	GetProfile(id string) (*models.Profile, error)
+	ListProfiles() ([]*models.Profile, error)
+	GetSettings(profileID string) (*models.ProfileSettings, error)
+	UpdateSettings(settings *models.ProfileSettings) error
+
+	GetProduct(barcode string) (*models.Product, error)
+	SaveProducts(products []*models.Product) error
+	SaveCustomFood(food *models.CustomFood) error
+	ListCustomFoods(profileID string) ([]*models.CustomFood, error)
+	DeleteCustomFood(profileID, id string) error
+
+	CreateToken(token *models.APIToken, hash string) error
+	LookupToken(hash string) (*models.APIToken, error)
+	ListTokens() ([]*models.APIToken, error)
+	RevokeToken(id string) error
+	CountActiveTokens() (int, error)
+
+	Rekey(newCipher *encryption.FieldCipher) error
+	Close() error
+}
+
+// Open opens the PostgreSQL database at databaseURL when it is set, and the
+// SQLite database at dbPath otherwise.
+func Open(dbPath, databaseURL string, opts ...Option) (Store, error) {
+	if databaseURL != "" {
+		return NewPostgresStorage(databaseURL, opts...)
+	}
+	return NewSQLiteStorage(dbPath, opts...)
+}
+
+// sqlStore implements Store with queries that run on both SQLite and
+// PostgreSQL. Queries use ? placeholders; dialect covers the rest of the
+// differences.
+type sqlStore struct {
+	db           *sqlDB
+	dialect      dialect
+	cipher       *encryption.FieldCipher
+	pragmas      []Pragma
+	maxOpenConns int
+}
+
+// Option configures a store.
+type Option func(*sqlStore)
+
+// WithCipher encrypts sensitive text columns (meal descriptions, food names)
+// with the given cipher. Numeric columns stay in the clear so aggregations
+// keep working in SQL.
+func WithCipher(c *encryption.FieldCipher) Option {
+	return func(s *sqlStore) {
+		s.cipher = c
+	}
+}
+
+func newSQLStore(d dialect, opts []Option) *sqlStore {
+	s := &sqlStore{
+		dialect:      d,
+		pragmas:      append([]Pragma(nil), DefaultPragmas...),
+		maxOpenConns: DefaultMaxOpenConns,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// prepare brings the data up to date once the schema is.
+func (s *sqlStore) prepare() error {
+	if err := s.ensureProfile(models.DefaultProfileID, "Default"); err != nil {
+		return err
+	}
+	if err := s.backfillLocalDates(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	if err := s.checkEncryptionKey(); err != nil {
+		return err
+	}
+	if err := s.initSearchIndex(); err != nil {
+		return fmt.Errorf("failed to build search index: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// dialect is what differs between the databases beyond plain SQL.
+type dialect interface {
+	// bind rewrites ? placeholders into the driver's syntax.
+	bind(query string) string
+
+	// Full-text search over meal descriptions and food names.
+	indexMeal(tx *sqlTx, meal *models.Meal, foods string) error
+	unindexMeal(tx *sqlTx, profileID, mealID string) error
+	rebuildSearchIndex(tx *sqlTx) error
+	clearSearchIndex(tx *sqlTx) error
+	// searchFilter matches IDs of the meals table referred to as table
+	// against a searchQuery and a profile ID, in that order.
+	searchFilter(table string) string
+	searchQuery(text string) string
+
+	// lockAudit and unlockAudit create and drop the triggers that make
+	// meal_audit append-only.
+	lockAudit() string
+	unlockAudit() string
+
+	// scrub overwrites the space freed by deleted and updated rows, so
+	// values replaced by Rekey cannot be read back from the files.
+	scrub(db *sqlDB) error
+}
+
+// sqlDB passes every query through the dialect's bind.
+type sqlDB struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (db *sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.bind(query), args...)
+}
+
+func (db *sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.bind(query), args...)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.bind(query), args...)
+}
+
+func (db *sqlDB) Begin() (*sqlTx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx is a transaction of sqlDB.
+type sqlTx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (tx *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.bind(query), args...)
+}
+
+func (tx *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.bind(query), args...)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.bind(query), args...)
+}
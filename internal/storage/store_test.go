@@ -0,0 +1,583 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/encryption"
+	"mcp-meal-log/internal/models"
+)
+
+// postgresTestURL names the environment variable holding a PostgreSQL
+// connection string for the conformance suite. Without it the PostgreSQL
+// runs are skipped.
+const postgresTestURL = "MEAL_LOG_TEST_DATABASE_URL"
+
+// storeBackends opens an empty store of each kind. Every conformance test
+// runs against all of them.
+var storeBackends = []struct {
+	name string
+	open func(tb testing.TB, opts ...Option) Store
+}{
+	{"sqlite", func(tb testing.TB, opts ...Option) Store { return newTestSQLite(tb, opts...) }},
+	{"postgres", newTestPostgres},
+}
+
+// newTestPostgres opens a store in a schema of its own, dropped when the
+// test ends, so tests never see each other's rows.
+func newTestPostgres(tb testing.TB, opts ...Option) Store {
+	tb.Helper()
+	dsn := os.Getenv(postgresTestURL)
+	if dsn == "" {
+		tb.Skipf("set %s to run against PostgreSQL", postgresTestURL)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	schema := fmt.Sprintf("meal_log_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		tb.Fatalf("failed to create test schema: %v", err)
+	}
+	tb.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	s, err := NewPostgresStorage(withSearchPath(dsn, schema), opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// withSearchPath adds a search_path run-time parameter to either form of
+// connection string.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+// sqlStoreOf reaches the shared implementation behind a Store, for checks
+// that need raw SQL.
+func sqlStoreOf(tb testing.TB, s Store) *sqlStore {
+	switch s := s.(type) {
+	case *SQLiteStorage:
+		return s.sqlStore
+	case *PostgresStorage:
+		return s.sqlStore
+	}
+	tb.Fatalf("unknown store %T", s)
+	return nil
+}
+
+func testCipher(tb testing.TB, seed byte) *encryption.FieldCipher {
+	tb.Helper()
+	c, err := encryption.NewFieldCipher(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return c
+}
+
+// newTestProfile creates a profile whose clock is UTC, so local dates and
+// slots do not depend on the machine running the tests.
+func newTestProfile(tb testing.TB, s Store, id string) {
+	tb.Helper()
+	if id != models.DefaultProfileID {
+		if err := s.CreateProfile(&models.Profile{ID: id, Name: id, CreatedAt: time.Now()}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	settings, err := s.GetSettings(id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	settings.Timezone = "UTC"
+	if err := s.UpdateSettings(settings); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func mealIDs(meals []*models.Meal) []string {
+	ids := make([]string, len(meals))
+	for i, meal := range meals {
+		ids[i] = meal.ID
+	}
+	return ids
+}
+
+// conformanceTests pin the behaviour every Store must share, whatever the
+// database. They cover the spots where the dialects differ: placeholder
+// binding, the search index, and the audit trail triggers.
+var conformanceTests = []struct {
+	name string
+	run  func(t *testing.T, open func(tb testing.TB, opts ...Option) Store)
+}{
+	{"SaveAndGetMeal", testSaveAndGetMeal},
+	{"GetMealsFiltersAndPages", testGetMealsFiltersAndPages},
+	{"StreamMeals", testStreamMeals},
+	{"TextSearch", testTextSearch},
+	{"AuditTrailIsAppendOnly", testAuditTrailIsAppendOnly},
+	{"TrashRestoreAndPurge", testTrashRestoreAndPurge},
+	{"Summarize", testSummarize},
+	{"ProfileIsolation", testProfileIsolation},
+}
+
+func TestStoreConformance(t *testing.T) {
+	for _, backend := range storeBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range conformanceTests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, backend.open)
+				})
+			}
+		})
+	}
+}
+
+func testSaveAndGetMeal(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	for _, encrypted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("encrypted=%v", encrypted), func(t *testing.T) {
+			var opts []Option
+			if encrypted {
+				opts = append(opts, WithCipher(testCipher(t, 1)))
+			}
+			s := open(t, opts...)
+			newTestProfile(t, s, models.DefaultProfileID)
+
+			exercise := true
+			meal := testMeals(models.DefaultProfileID, 1, time.Date(2026, 3, 4, 12, 30, 0, 0, time.UTC))[0]
+			meal.Tags = []string{"ate out", "sick day"}
+			meal.Notes = "felt rough"
+			meal.Location = "office"
+			meal.Restaurant = "Café Zoë"
+			meal.Exercise = &exercise
+			meal.Photo = &models.MealPhoto{SHA256: "abc123", MimeType: "image/jpeg", Thumbnail: []byte{0xff, 0xd8, 0x00}}
+			meal.PhotoSHA256 = "abc123"
+			if err := s.SaveMeal(meal, testChange); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.GetMeal(meal.ProfileID, meal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case got.Description != meal.Description:
+				t.Errorf("Description = %q, want %q", got.Description, meal.Description)
+			case !got.Timestamp.Equal(meal.Timestamp):
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, meal.Timestamp)
+			case got.Slot != models.SlotLunch:
+				t.Errorf("Slot = %q, want %q", got.Slot, models.SlotLunch)
+			case len(got.Foods) != 2 || got.Foods[1].Name != "jam":
+				t.Errorf("Foods = %+v", got.Foods)
+			case strings.Join(got.Tags, ",") != "ate out,sick day":
+				t.Errorf("Tags = %v", got.Tags)
+			case got.Notes != meal.Notes || got.Location != meal.Location || got.Restaurant != meal.Restaurant:
+				t.Errorf("context = %q, %q, %q", got.Notes, got.Location, got.Restaurant)
+			case got.Exercise == nil || !*got.Exercise:
+				t.Errorf("Exercise = %v, want true", got.Exercise)
+			case got.PhotoSHA256 != "abc123":
+				t.Errorf("PhotoSHA256 = %q", got.PhotoSHA256)
+			}
+
+			photo, err := s.GetMealPhoto(meal.ProfileID, meal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(photo.Thumbnail, meal.Photo.Thumbnail) {
+				t.Errorf("Thumbnail = %x, want %x", photo.Thumbnail, meal.Photo.Thumbnail)
+			}
+
+			if _, err := s.GetMeal(meal.ProfileID, "missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetMeal(missing) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func testGetMealsFiltersAndPages(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t)
+	newTestProfile(t, s, models.DefaultProfileID)
+
+	// Meals every four hours from 2026-05-01 06:00 to 2026-05-03 02:00
+	var meals []*models.Meal
+	for i, m := range testMeals(models.DefaultProfileID, 12, time.Date(2026, 5, 1, 6, 0, 0, 0, time.UTC)) {
+		m.Timestamp = time.Date(2026, 5, 1, 6, 0, 0, 0, time.UTC).Add(time.Duration(i) * 4 * time.Hour)
+		m.TotalCarbs = float64(10 * (i + 1))
+		if i%3 == 0 {
+			m.Confidence = models.LowConfidence
+			m.Tags = []string{"sick day"}
+		}
+		meals = append(meals, m)
+	}
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	minCarbs, maxCarbs := 30.0, 80.0
+	tests := []struct {
+		name string
+		q    MealQuery
+		want []int
+	}{
+		{"date", MealQuery{StartDate: "2026-05-02", EndDate: "2026-05-02"}, []int{10, 9, 8, 7, 6, 5}},
+		{"carbs", MealQuery{MinCarbs: &minCarbs, MaxCarbs: &maxCarbs}, []int{7, 6, 5, 4, 3, 2}},
+		{"confidence", MealQuery{Confidence: []models.ConfidenceLevel{models.LowConfidence, models.HighConfidence}}, []int{9, 6, 3, 0}},
+		{"tags", MealQuery{Tags: []string{"sick day"}}, []int{9, 6, 3, 0}},
+		{"unknown tag", MealQuery{Tags: []string{"never used"}}, []int{}},
+		{"time of day", MealQuery{TimeFrom: "21:00", TimeTo: "07:00"}, []int{11, 10, 6, 5, 4, 0}},
+		{"slot", MealQuery{Slot: models.SlotBreakfast}, []int{7, 6, 1, 0}},
+		{"ascending by carbs", MealQuery{Sort: SortCarbs, Ascending: true, EndDate: "2026-05-01"}, []int{0, 1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.GetMeals(models.DefaultProfileID, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]string, len(tt.want))
+			for i, n := range tt.want {
+				want[i] = meals[n].ID
+			}
+			if got := mealIDs(page.Meals); strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("meals = %v\nwant    %v", got, want)
+			}
+			if page.Total != len(tt.want) {
+				t.Errorf("Total = %d, want %d", page.Total, len(tt.want))
+			}
+		})
+	}
+
+	t.Run("cursor", func(t *testing.T) {
+		q := MealQuery{Limit: 5}
+		var got []string
+		for pages := 0; ; pages++ {
+			page, err := s.GetMeals(models.DefaultProfileID, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, mealIDs(page.Meals)...)
+			if page.NextCursor == "" {
+				break
+			}
+			if pages > len(meals) {
+				t.Fatal("pagination does not end")
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(got) != len(meals) || got[0] != meals[11].ID || got[len(got)-1] != meals[0].ID {
+			t.Errorf("paged meals = %v", got)
+		}
+	})
+}
+
+func testStreamMeals(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t, WithCipher(testCipher(t, 2)))
+	newTestProfile(t, s, models.DefaultProfileID)
+	meals := testMeals(models.DefaultProfileID, streamBatchSize*2+1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	meals[0].Foods = nil
+	meals[1].Tags = []string{"ate out", "pre-exercise"}
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*models.Meal
+	err := s.StreamMeals(models.DefaultProfileID, MealQuery{}, func(meal *models.Meal) error {
+		got = append(got, meal)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(meals) {
+		t.Fatalf("streamed %d meals, want %d", len(got), len(meals))
+	}
+	for i, meal := range got {
+		if meal.ID != meals[i].ID {
+			t.Fatalf("meal %d = %s, want %s", i, meal.ID, meals[i].ID)
+		}
+	}
+	if len(got[0].Foods) != 0 || len(got[2].Foods) != 2 {
+		t.Errorf("foods = %d and %d, want 0 and 2", len(got[0].Foods), len(got[2].Foods))
+	}
+	if strings.Join(got[1].Tags, ",") != "ate out,pre-exercise" {
+		t.Errorf("Tags = %v", got[1].Tags)
+	}
+}
+
+func testTextSearch(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t)
+	newTestProfile(t, s, models.DefaultProfileID)
+	meals := testMeals(models.DefaultProfileID, 2, time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC))
+	meals[0].Description = "Banana bread"
+	meals[1].Description = "Porridge"
+	meals[1].Foods[0].Name = "oats"
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(text string) ([]string, error) {
+		page, err := s.GetMeals(models.DefaultProfileID, MealQuery{Text: text})
+		if err != nil {
+			return nil, err
+		}
+		return mealIDs(page.Meals), nil
+	}
+	expect := func(text string, want ...string) {
+		t.Helper()
+		got, err := search(text)
+		if err != nil {
+			t.Fatalf("search %q: %v", text, err)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("search %q = %v, want %v", text, got, want)
+		}
+	}
+
+	expect("banan brea", meals[0].ID)
+	expect("OAT", meals[1].ID)
+	expect("banana OR porridge") // operators are searched for literally
+	expect("pizza")
+
+	// Encrypting drops the index; text queries fail instead of finding nothing
+	if err := s.Rekey(testCipher(t, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := search("banana"); !errors.Is(err, ErrSearchUnavailable) {
+		t.Fatalf("search on encrypted store error = %v, want ErrSearchUnavailable", err)
+	}
+
+	// Decrypting rebuilds it
+	if err := s.Rekey(nil); err != nil {
+		t.Fatal(err)
+	}
+	expect("banana", meals[0].ID)
+}
+
+func testAuditTrailIsAppendOnly(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t)
+	newTestProfile(t, s, models.DefaultProfileID)
+	meal := testMeals(models.DefaultProfileID, 1, time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC))[0]
+	if err := s.SaveMeal(meal, testChange); err != nil {
+		t.Fatal(err)
+	}
+	meal.TotalCarbs = 50
+	meal.UpdatedAt = meal.UpdatedAt.Add(time.Minute)
+	if err := s.UpdateMeal(meal, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := sqlStoreOf(t, s)
+	assertLocked := func() {
+		t.Helper()
+		if _, err := raw.db.Exec(`UPDATE meal_audit SET tool = 'tampered'`); err == nil {
+			t.Error("UPDATE on meal_audit succeeded")
+		}
+		if _, err := raw.db.Exec(`DELETE FROM meal_audit`); err == nil {
+			t.Error("DELETE on meal_audit succeeded")
+		}
+	}
+	assertLocked()
+
+	// Rekeying unlocks the trail to re-encrypt it, and must lock it again
+	if err := s.Rekey(testCipher(t, 4)); err != nil {
+		t.Fatal(err)
+	}
+	assertLocked()
+
+	history, err := s.GetMealHistory(meal.ProfileID, meal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Action != models.AuditCreate || history[1].Action != models.AuditUpdate {
+		t.Fatalf("history = %+v", history)
+	}
+	if history[1].Before.TotalCarbs != 43 || history[1].After.TotalCarbs != 50 {
+		t.Errorf("update revision carbs = %v -> %v, want 43 -> 50", history[1].Before.TotalCarbs, history[1].After.TotalCarbs)
+	}
+
+	restored, err := s.RestoreMealRevision(meal.ProfileID, meal.ID, history[0].ID, testChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.TotalCarbs != 43 {
+		t.Errorf("restored TotalCarbs = %v, want 43", restored.TotalCarbs)
+	}
+}
+
+func testTrashRestoreAndPurge(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t)
+	newTestProfile(t, s, models.DefaultProfileID)
+	meals := testMeals(models.DefaultProfileID, 2, time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC))
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, meal := range meals {
+		if err := s.DeleteMeal(meal.ProfileID, meal.ID, testChange); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := s.GetMeals(models.DefaultProfileID, MealQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Meals) != 0 {
+		t.Errorf("GetMeals returned %d meals from the trash", len(page.Meals))
+	}
+
+	if _, err := s.RestoreMeal(meals[0].ProfileID, meals[0].ID, testChange); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.PurgeDeletedMeals(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d meals, want 1", n)
+	}
+	if _, err := s.GetMeal(meals[1].ProfileID, meals[1].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("purged meal: error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetMeal(meals[0].ProfileID, meals[0].ID); err != nil {
+		t.Errorf("restored meal: %v", err)
+	}
+
+	// The purge is recorded, and the trail keeps the last version
+	history, err := s.GetMealHistory(meals[1].ProfileID, meals[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history[len(history)-1]; last.Action != models.AuditPurge || last.Before == nil {
+		t.Errorf("last revision = %+v, want a purge", last)
+	}
+}
+
+func testSummarize(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t, WithCipher(testCipher(t, 5)))
+	newTestProfile(t, s, models.DefaultProfileID)
+	meals := testMeals(models.DefaultProfileID, 3, time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC))
+	meals[2].Timestamp = time.Date(2026, 6, 3, 19, 0, 0, 0, time.UTC)
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := s.Summarize(models.DefaultProfileID, "2026-06-01", "2026-06-03", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.MealCount != 3 || summary.TotalCarbs != 129 {
+		t.Errorf("MealCount, TotalCarbs = %d, %v, want 3, 129", summary.MealCount, summary.TotalCarbs)
+	}
+	if len(summary.Days) != 3 || summary.Days[0].Meals != 2 || summary.Days[1].Meals != 0 || summary.Days[2].Meals != 1 {
+		t.Errorf("Days = %+v", summary.Days)
+	}
+	if len(summary.TopFoods) == 0 || summary.TopFoods[0].Name != "jam" && summary.TopFoods[0].Name != "toast" {
+		t.Errorf("TopFoods = %+v", summary.TopFoods)
+	}
+	if len(summary.Tags) != 1 || summary.Tags[0].Meals != 3 {
+		t.Errorf("Tags = %+v", summary.Tags)
+	}
+}
+
+// testProfileIsolation writes meals under one profile and checks that no
+// read or write made for another profile reaches them.
+func testProfileIsolation(t *testing.T, open func(tb testing.TB, opts ...Option) Store) {
+	s := open(t)
+	newTestProfile(t, s, "alice")
+	newTestProfile(t, s, "bob")
+	meals := testMeals("alice", 3, time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC))
+	if err := s.SaveMeals(meals, testChange); err != nil {
+		t.Fatal(err)
+	}
+	mealID := meals[0].ID
+
+	page, err := s.GetMeals("bob", MealQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Meals) != 0 || page.Total != 0 {
+		t.Errorf("GetMeals(bob) = %v", mealIDs(page.Meals))
+	}
+	page, err = s.GetMeals("bob", MealQuery{Text: "toast", Tags: []string{"breakfast-out"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Meals) != 0 {
+		t.Errorf("search under bob = %v", mealIDs(page.Meals))
+	}
+
+	streamed := 0
+	if err := s.StreamMeals("bob", MealQuery{IncludeDeleted: true}, func(*models.Meal) error {
+		streamed++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if streamed != 0 {
+		t.Errorf("StreamMeals(bob) returned %d meals", streamed)
+	}
+
+	summary, err := s.Summarize("bob", "2026-07-01", "2026-07-01", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.MealCount != 0 || len(summary.TopFoods) != 0 || len(summary.Tags) != 0 {
+		t.Errorf("Summarize(bob) = %+v", summary)
+	}
+	tags, err := s.ListTags("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("ListTags(bob) = %+v", tags)
+	}
+
+	if _, err := s.GetMeal("bob", mealID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMeal(bob) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetMealHistory("bob", mealID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMealHistory(bob) error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteMeal("bob", mealID, testChange); err == nil {
+		t.Error("DeleteMeal(bob) succeeded")
+	}
+	stolen := *meals[1]
+	stolen.ProfileID = "bob"
+	stolen.Description = "overwritten"
+	if err := s.UpdateMeal(&stolen, testChange); err == nil {
+		t.Error("UpdateMeal under bob succeeded")
+	}
+
+	// Alice's meals are untouched
+	page, err = s.GetMeals("alice", MealQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Meals) != 3 {
+		t.Fatalf("GetMeals(alice) = %v", mealIDs(page.Meals))
+	}
+	for _, meal := range page.Meals {
+		if meal.Description == "overwritten" || meal.DeletedAt != nil {
+			t.Errorf("meal %s was changed through bob", meal.ID)
+		}
+	}
+}
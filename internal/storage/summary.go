@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Summarize aggregates a profile's live meals between two local dates,
+// inclusive. Totals are computed in SQL; foods are grouped here because
+// their names may be encrypted.
+func (s *sqlStore) Summarize(profileID, startDate, endDate string, topFoods int) (*models.Summary, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %q", startDate)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %q", endDate)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endDate, startDate)
+	}
+
+	loc, err := s.profileLocation(s.db, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.Summary{
+		ProfileID:   profileID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Timezone:    loc.String(),
+		GeneratedAt: time.Now().In(loc),
+	}
+
+	if err := s.summarizeDays(summary, start, end); err != nil {
+		return nil, err
+	}
+	if err := s.summarizeConfidence(summary); err != nil {
+		return nil, err
+	}
+	if err := s.summarizeSlots(summary); err != nil {
+		return nil, err
+	}
+	if err := s.summarizeFoods(summary, topFoods); err != nil {
+		return nil, err
+	}
+	if err := s.summarizeTags(summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+const summaryFilter = `profile_id = ? AND deleted_at IS NULL AND local_date >= ? AND local_date <= ?`
+
+func (s *sqlStore) summarizeDays(summary *models.Summary, start, end time.Time) error {
+	rows, err := s.db.Query(`
+        SELECT local_date, COUNT(*), SUM(total_carbs)
+        FROM meals
+        WHERE `+summaryFilter+`
+        GROUP BY local_date
+    `, summary.ProfileID, summary.StartDate, summary.EndDate)
+	if err != nil {
+		return fmt.Errorf("failed to summarize days: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[string]models.DailyTotal{}
+	for rows.Next() {
+		var day models.DailyTotal
+		if err := rows.Scan(&day.Date, &day.Meals, &day.Carbs); err != nil {
+			return fmt.Errorf("failed to scan daily total: %w", err)
+		}
+		totals[day.Date] = day
+		summary.MealCount += day.Meals
+		summary.TotalCarbs += day.Carbs
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to summarize days: %w", err)
+	}
+
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		day, ok := totals[date]
+		if !ok {
+			day = models.DailyTotal{Date: date}
+		}
+		summary.Days = append(summary.Days, day)
+	}
+	return nil
+}
+
+func (s *sqlStore) summarizeConfidence(summary *models.Summary) error {
+	rows, err := s.db.Query(`
+        SELECT confidence, COUNT(*)
+        FROM meals
+        WHERE `+summaryFilter+`
+        GROUP BY confidence
+    `, summary.ProfileID, summary.StartDate, summary.EndDate)
+	if err != nil {
+		return fmt.Errorf("failed to summarize confidence: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[models.ConfidenceLevel]int{}
+	for rows.Next() {
+		var confidence string
+		var n int
+		if err := rows.Scan(&confidence, &n); err != nil {
+			return fmt.Errorf("failed to scan confidence: %w", err)
+		}
+		counts[models.ConfidenceLevel(confidence)] += n
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to summarize confidence: %w", err)
+	}
+
+	// Known levels come first, in order, followed by anything unexpected
+	for _, level := range []models.ConfidenceLevel{models.HighConfidence, models.MediumConfidence, models.LowConfidence} {
+		summary.Confidence = append(summary.Confidence, models.ConfidenceCount{Confidence: level, Meals: counts[level]})
+		delete(counts, level)
+	}
+	var others []models.ConfidenceCount
+	for level, n := range counts {
+		others = append(others, models.ConfidenceCount{Confidence: level, Meals: n})
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Confidence < others[j].Confidence })
+	summary.Confidence = append(summary.Confidence, others...)
+	return nil
+}
+
+// summarizeSlots averages carbs per meal slot. The standard slots always
+// appear, in order, followed by the profile's own slots by name.
+func (s *sqlStore) summarizeSlots(summary *models.Summary) error {
+	rows, err := s.db.Query(`
+        SELECT slot, COUNT(*), SUM(total_carbs)
+        FROM meals
+        WHERE `+summaryFilter+`
+        GROUP BY slot
+    `, summary.ProfileID, summary.StartDate, summary.EndDate)
+	if err != nil {
+		return fmt.Errorf("failed to summarize meal slots: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	carbs := map[string]float64{}
+	for rows.Next() {
+		var slot sql.NullString
+		var n int
+		var total float64
+		if err := rows.Scan(&slot, &n, &total); err != nil {
+			return fmt.Errorf("failed to scan meal slot: %w", err)
+		}
+		counts[slot.String] += n
+		carbs[slot.String] += total
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to summarize meal slots: %w", err)
+	}
+
+	slots := append([]string(nil), models.MealSlots...)
+	var others []string
+	for slot := range counts {
+		if slot != "" && !slices.Contains(models.MealSlots, slot) {
+			others = append(others, slot)
+		}
+	}
+	sort.Strings(others)
+	for _, slot := range append(slots, others...) {
+		average := models.SlotAverage{Slot: slot, Meals: counts[slot]}
+		if average.Meals > 0 {
+			average.AverageCarbs = carbs[slot] / float64(average.Meals)
+		}
+		summary.Slots = append(summary.Slots, average)
+	}
+	return nil
+}
+
+// summarizeFoods counts foods by name, ignoring case, and keeps the limit
+// most frequent.
+func (s *sqlStore) summarizeFoods(summary *models.Summary, limit int) error {
+	rows, err := s.db.Query(`
+        SELECT f.name, f.estimated_carbs
+        FROM foods f
+        JOIN meals m ON m.id = f.meal_id AND m.profile_id = f.profile_id
+        WHERE m.profile_id = ? AND m.deleted_at IS NULL AND m.local_date >= ? AND m.local_date <= ?
+    `, summary.ProfileID, summary.StartDate, summary.EndDate)
+	if err != nil {
+		return fmt.Errorf("failed to summarize foods: %w", err)
+	}
+	defer rows.Close()
+
+	foods := map[string]*models.FoodCount{}
+	for rows.Next() {
+		var name string
+		var carbs float64
+		if err := rows.Scan(&name, &carbs); err != nil {
+			return fmt.Errorf("failed to scan food: %w", err)
+		}
+		if name, err = s.open(name); err != nil {
+			return err
+		}
+		key := strings.ToLower(strings.Join(strings.Fields(name), " "))
+		food, ok := foods[key]
+		if !ok {
+			food = &models.FoodCount{Name: strings.TrimSpace(name)}
+			foods[key] = food
+		}
+		food.Count++
+		food.TotalCarbs += carbs
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to summarize foods: %w", err)
+	}
+
+	summary.TopFoods = []models.FoodCount{}
+	for _, food := range foods {
+		summary.TopFoods = append(summary.TopFoods, *food)
+	}
+	sort.Slice(summary.TopFoods, func(i, j int) bool {
+		a, b := summary.TopFoods[i], summary.TopFoods[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	if limit > 0 && len(summary.TopFoods) > limit {
+		summary.TopFoods = summary.TopFoods[:limit]
+	}
+	return nil
+}
+
+// summarizeTags counts the meals carrying each tag, most used first.
+func (s *sqlStore) summarizeTags(summary *models.Summary) error {
+	rows, err := s.db.Query(`
+        SELECT t.name, COUNT(*), SUM(m.total_carbs)
+        FROM meal_tags mt
+        JOIN tags t ON t.id = mt.tag_id
+        JOIN meals m ON m.id = mt.meal_id AND m.profile_id = mt.profile_id
+        WHERE m.profile_id = ? AND m.deleted_at IS NULL AND m.local_date >= ? AND m.local_date <= ?
+        GROUP BY t.id, t.name
+    `, summary.ProfileID, summary.StartDate, summary.EndDate)
+	if err != nil {
+		return fmt.Errorf("failed to summarize tags: %w", err)
+	}
+	summary.Tags, err = s.scanTagCounts(rows)
+	return err
+}
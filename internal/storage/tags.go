@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Tags are kept once per profile in the tags table and linked to meals
+// through meal_tags. Their names may be encrypted, so they are looked up
+// and grouped here rather than in SQL.
+
+// tagIDs maps the names of a profile's tags to their IDs.
+func (s *sqlStore) tagIDs(q queryer, profileID string) (map[string]int64, error) {
+	rows, err := q.Query(`SELECT id, name FROM tags WHERE profile_id = ?`, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if name, err = s.open(name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	return ids, nil
+}
+
+// insertTags links a meal to its tags, creating the ones the profile has
+// not used before.
+func (s *sqlStore) insertTags(tx *sqlTx, meal *models.Meal) error {
+	if len(meal.Tags) == 0 {
+		return nil
+	}
+	ids, err := s.tagIDs(tx, meal.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range meal.Tags {
+		id, ok := ids[tag]
+		if !ok {
+			name, err := s.seal(tag)
+			if err != nil {
+				return err
+			}
+			err = tx.QueryRow(`INSERT INTO tags (profile_id, name, created_at) VALUES (?, ?, ?) RETURNING id`,
+				meal.ProfileID, name, time.Now().UTC()).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("failed to insert tag: %w", err)
+			}
+			ids[tag] = id
+		}
+		_, err := tx.Exec(`INSERT INTO meal_tags (profile_id, meal_id, tag_id) VALUES (?, ?, ?)`,
+			meal.ProfileID, meal.ID, id)
+		if err != nil {
+			return fmt.Errorf("failed to tag meal: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadTags attaches tags to meals of one profile, foodBatchSize meals per
+// query like loadFoods.
+func (s *sqlStore) loadTags(q queryer, profileID string, meals []*models.Meal) error {
+	for start := 0; start < len(meals); start += foodBatchSize {
+		end := start + foodBatchSize
+		if end > len(meals) {
+			end = len(meals)
+		}
+		if err := s.loadTagBatch(q, profileID, meals[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) loadTagBatch(q queryer, profileID string, meals []*models.Meal) error {
+	byID := make(map[string]*models.Meal, len(meals))
+	placeholders := make([]string, len(meals))
+	args := []interface{}{profileID}
+	for i, meal := range meals {
+		byID[meal.ID] = meal
+		placeholders[i] = "?"
+		args = append(args, meal.ID)
+	}
+
+	rows, err := q.Query(`
+        SELECT mt.meal_id, t.name
+        FROM meal_tags mt
+        JOIN tags t ON t.id = mt.tag_id
+        WHERE mt.profile_id = ? AND mt.meal_id IN (`+strings.Join(placeholders, ", ")+`)
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mealID, name string
+		if err := rows.Scan(&mealID, &name); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if name, err = s.open(name); err != nil {
+			return err
+		}
+		if meal, ok := byID[mealID]; ok {
+			meal.Tags = append(meal.Tags, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+
+	for _, meal := range meals {
+		sort.Strings(meal.Tags)
+	}
+	return nil
+}
+
+// ListTags returns every tag the profile has used on a live meal, most used
+// first, with the average carbs of those meals.
+func (s *sqlStore) ListTags(profileID string) ([]models.TagCount, error) {
+	rows, err := s.db.Query(`
+        SELECT t.name, COUNT(*), SUM(m.total_carbs)
+        FROM meal_tags mt
+        JOIN tags t ON t.id = mt.tag_id
+        JOIN meals m ON m.id = mt.meal_id AND m.profile_id = mt.profile_id
+        WHERE mt.profile_id = ? AND m.deleted_at IS NULL
+        GROUP BY t.id, t.name
+    `, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return s.scanTagCounts(rows)
+}
+
+// scanTagCounts reads rows of tag name, meal count and total carbs.
+func (s *sqlStore) scanTagCounts(rows *sql.Rows) ([]models.TagCount, error) {
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var name string
+		var n int
+		var total float64
+		if err := rows.Scan(&name, &n, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		name, err := s.open(name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, models.TagCount{Tag: name, Meals: n, AverageCarbs: total / float64(n)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Meals != tags[j].Meals {
+			return tags[i].Meals > tags[j].Meals
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
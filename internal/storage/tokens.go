@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+func (s *sqlStore) CreateToken(token *models.APIToken, hash string) error {
+	_, err := s.db.Exec(`
+        INSERT INTO api_tokens (id, name, profile_id, token_hash, created_at)
+        VALUES (?, ?, ?, ?, ?)
+    `, token.ID, token.Name, token.ProfileID, hash, token.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert token: %w", err)
+	}
+	return nil
+}
+
+// tokenUseResolution is how often LookupToken records a token's use, so
+// authenticating a request does not write to the database every time.
+const tokenUseResolution = time.Minute
+
+// LookupToken returns the active token with the given secret hash and
+// records that it was used, at most once every tokenUseResolution.
+func (s *sqlStore) LookupToken(hash string) (*models.APIToken, error) {
+	token, err := scanToken(s.db.QueryRow(`
+        SELECT id, name, profile_id, created_at, last_used_at, revoked_at
+        FROM api_tokens
+        WHERE token_hash = ? AND revoked_at IS NULL
+    `, hash))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < tokenUseResolution {
+		return token, nil
+	}
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, token.ID); err != nil {
+		return nil, fmt.Errorf("failed to update token usage: %w", err)
+	}
+	return token, nil
+}
+
+func (s *sqlStore) ListTokens() ([]*models.APIToken, error) {
+	rows, err := s.db.Query(`
+        SELECT id, name, profile_id, created_at, last_used_at, revoked_at
+        FROM api_tokens
+        ORDER BY created_at
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.APIToken
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *sqlStore) RevokeToken(id string) error {
+	result, err := s.db.Exec(`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("token %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// CountActiveTokens is used at startup to warn when token auth is enabled
+// but no client could possibly authenticate.
+func (s *sqlStore) CountActiveTokens() (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE revoked_at IS NULL`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return n, nil
+}
+
+func scanToken(row rowScanner) (*models.APIToken, error) {
+	token := &models.APIToken{}
+	var createdAtStr string
+	var lastUsedAt, revokedAt sql.NullString
+	if err := row.Scan(&token.ID, &token.Name, &token.ProfileID, &createdAtStr, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if token.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr); err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	if token.LastUsedAt, err = parseNullTime(lastUsedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse last_used_at: %w", err)
+	}
+	if token.RevokedAt, err = parseNullTime(revokedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse revoked_at: %w", err)
+	}
+	return token, nil
+}
+
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullBool stores a nil flag as NULL.
+func nullBool(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"mcp-meal-log/internal/models"
+)
+
+// Looking a token up records its use, but at most once a minute.
+func TestLookupTokenRecordsUseOncePerMinute(t *testing.T) {
+	s := newTestSQLite(t)
+	token := &models.APIToken{ID: "tok_1", Name: "phone", CreatedAt: time.Now().Add(-time.Hour)}
+	if err := s.CreateToken(token, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	lastUsed := func() *time.Time {
+		t.Helper()
+		got, err := s.LookupToken("hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.LastUsedAt
+	}
+
+	if used := lastUsed(); used != nil {
+		t.Fatalf("first lookup last_used_at = %v, want none yet", used)
+	}
+	first := lastUsed()
+	if first == nil {
+		t.Fatal("first lookup was not recorded")
+	}
+	if again := lastUsed(); again == nil || !again.Equal(*first) {
+		t.Errorf("lookup within a minute wrote last_used_at again: %v, was %v", again, first)
+	}
+
+	old := time.Now().Add(-2 * time.Minute).UTC()
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, old, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	lastUsed()
+	if later := lastUsed(); later == nil || !later.After(old) {
+		t.Errorf("lookup after a minute did not record it: %v", later)
+	}
+}